@@ -0,0 +1,113 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package benchmarks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mhersson/vectorsigma/benchmarks/classic"
+	"github.com/mhersson/vectorsigma/benchmarks/table"
+)
+
+func BenchmarkClassic_New(b *testing.B) {
+	b.ReportAllocs()
+
+	for b.Loop() {
+		classic.New()
+	}
+}
+
+func BenchmarkTable_New(b *testing.B) {
+	b.ReportAllocs()
+
+	for b.Loop() {
+		table.New()
+	}
+}
+
+func BenchmarkClassic_Step(b *testing.B) {
+	fsm := classic.New()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if result, err := fsm.Step(ctx); err != nil {
+			b.Fatal(err)
+		} else if result.Done {
+			fsm.CurrentState = classic.InitialState
+		}
+	}
+}
+
+func BenchmarkTable_Step(b *testing.B) {
+	fsm := table.New()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if result, err := fsm.Step(ctx); err != nil {
+			b.Fatal(err)
+		} else if result.Done {
+			fsm.CurrentState = table.InitialState
+		}
+	}
+}
+
+// The machines are created in each iteration, the way an operator creates a
+// machine for each reconcile.
+func BenchmarkClassic_NewAndRun(b *testing.B) {
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := classic.New().Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTable_NewAndRun(b *testing.B) {
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := table.New().Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestTable_StepDoesNotAllocate(t *testing.T) {
+	fsm := table.New()
+	ctx := context.Background()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if result, _ := fsm.Step(ctx); result.Done {
+			fsm.CurrentState = table.InitialState
+		}
+	})
+
+	if allocs != 0 {
+		t.Errorf("Step() allocates %v times, want 0", allocs)
+	}
+}
@@ -0,0 +1,241 @@
+package classic_test
+
+import (
+	"testing"
+
+	"github.com/mhersson/vectorsigma/benchmarks/classic"
+)
+
+// +vectorsigma:action:CancelOrder
+func TestOrderProcessor_CancelOrderAction(t *testing.T) {
+	type fields struct {
+		context       *classic.Context
+		currentState  classic.StateName
+		stateConfigs  map[classic.StateName]classic.StateConfig
+		ExtendedState *classic.ExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &classic.OrderProcessor{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				StateConfigs:  tt.fields.stateConfigs,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.CancelOrderAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("OrderProcessor.CancelOrderAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// +vectorsigma:action:CompleteOrder
+func TestOrderProcessor_CompleteOrderAction(t *testing.T) {
+	type fields struct {
+		context       *classic.Context
+		currentState  classic.StateName
+		stateConfigs  map[classic.StateName]classic.StateConfig
+		ExtendedState *classic.ExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &classic.OrderProcessor{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				StateConfigs:  tt.fields.stateConfigs,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.CompleteOrderAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("OrderProcessor.CompleteOrderAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// +vectorsigma:action:HandleError
+func TestOrderProcessor_HandleErrorAction(t *testing.T) {
+	type fields struct {
+		context       *classic.Context
+		currentState  classic.StateName
+		stateConfigs  map[classic.StateName]classic.StateConfig
+		ExtendedState *classic.ExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &classic.OrderProcessor{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				StateConfigs:  tt.fields.stateConfigs,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.HandleErrorAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("OrderProcessor.HandleErrorAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// +vectorsigma:action:InitializeOrder
+func TestOrderProcessor_InitializeOrderAction(t *testing.T) {
+	type fields struct {
+		context       *classic.Context
+		currentState  classic.StateName
+		stateConfigs  map[classic.StateName]classic.StateConfig
+		ExtendedState *classic.ExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &classic.OrderProcessor{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				StateConfigs:  tt.fields.stateConfigs,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.InitializeOrderAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("OrderProcessor.InitializeOrderAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// +vectorsigma:action:ProcessOrder
+func TestOrderProcessor_ProcessOrderAction(t *testing.T) {
+	type fields struct {
+		context       *classic.Context
+		currentState  classic.StateName
+		stateConfigs  map[classic.StateName]classic.StateConfig
+		ExtendedState *classic.ExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &classic.OrderProcessor{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				StateConfigs:  tt.fields.stateConfigs,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.ProcessOrderAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("OrderProcessor.ProcessOrderAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// +vectorsigma:action:ShipOrder
+func TestOrderProcessor_ShipOrderAction(t *testing.T) {
+	type fields struct {
+		context       *classic.Context
+		currentState  classic.StateName
+		stateConfigs  map[classic.StateName]classic.StateConfig
+		ExtendedState *classic.ExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &classic.OrderProcessor{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				StateConfigs:  tt.fields.stateConfigs,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.ShipOrderAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("OrderProcessor.ShipOrderAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
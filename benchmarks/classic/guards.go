@@ -0,0 +1,19 @@
+package classic
+
+// +vectorsigma:guard:HasShippingFailed
+func (fsm *OrderProcessor) HasShippingFailedGuard(_ ...string) bool {
+	// TODO: Implement me!
+	return false
+}
+
+// +vectorsigma:guard:IsError
+func (fsm *OrderProcessor) IsErrorGuard(_ ...string) bool {
+	// TODO: Implement me!
+	return false
+}
+
+// +vectorsigma:guard:IsOutOfStock
+func (fsm *OrderProcessor) IsOutOfStockGuard(_ ...string) bool {
+	// TODO: Implement me!
+	return false
+}
@@ -0,0 +1,37 @@
+package table
+
+// +vectorsigma:action:CancelOrder
+func (fsm *OrderProcessor) CancelOrderAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:CompleteOrder
+func (fsm *OrderProcessor) CompleteOrderAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:HandleError
+func (fsm *OrderProcessor) HandleErrorAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:InitializeOrder
+func (fsm *OrderProcessor) InitializeOrderAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:ProcessOrder
+func (fsm *OrderProcessor) ProcessOrderAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:ShipOrder
+func (fsm *OrderProcessor) ShipOrderAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
@@ -0,0 +1,115 @@
+package table_test
+
+import (
+	"testing"
+
+	"github.com/mhersson/vectorsigma/benchmarks/table"
+)
+
+// +vectorsigma:guard:HasShippingFailed
+func TestOrderProcessor_HasShippingFailedGuard(t *testing.T) {
+	type fields struct {
+		context       *table.Context
+		currentState  table.StateName
+		ExtendedState *table.ExtendedState
+	}
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &table.OrderProcessor{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if got := fsm.HasShippingFailedGuard(tt.args.params...); got != tt.want {
+				t.Errorf("OrderProcessor.HasShippingFailedGuard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// +vectorsigma:guard:IsError
+func TestOrderProcessor_IsErrorGuard(t *testing.T) {
+	type fields struct {
+		context       *table.Context
+		currentState  table.StateName
+		ExtendedState *table.ExtendedState
+	}
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &table.OrderProcessor{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if got := fsm.IsErrorGuard(tt.args.params...); got != tt.want {
+				t.Errorf("OrderProcessor.IsErrorGuard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// +vectorsigma:guard:IsOutOfStock
+func TestOrderProcessor_IsOutOfStockGuard(t *testing.T) {
+	type fields struct {
+		context       *table.Context
+		currentState  table.StateName
+		ExtendedState *table.ExtendedState
+	}
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &table.OrderProcessor{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if got := fsm.IsOutOfStockGuard(tt.args.params...); got != tt.want {
+				t.Errorf("OrderProcessor.IsOutOfStockGuard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mhersson/vectorsigma/pkgs/coverage"
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+	"github.com/spf13/cobra"
+)
+
+const coverDirFlag = "dir"
+
+var CoverageCmd = &cobra.Command{
+	Use:   "coverage",
+	Short: "Report the coverage of a state chart",
+	Long: `Report which states, transitions and guard outcomes of the UML diagram
+are exercised by the tests.
+
+The coverage files are written by the generated state machines when the tests
+are built with the vectorsigma_coverage build tag, to the directory given by the
+VECTORSIGMA_COVERDIR environment variable.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		input, _ := cmd.Flags().GetString(inputFlag)
+		dir, _ := cmd.Flags().GetString(coverDirFlag)
+		plantUML, _ := cmd.Flags().GetBool(plantUMLFlag)
+
+		return reportCoverage(cmd.OutOrStdout(), input, dir, plantUML)
+	},
+}
+
+func init() {
+	CoverageCmd.Flags().StringP(inputFlag, "i", "", "The UML input file")
+	_ = CoverageCmd.MarkFlagRequired(inputFlag)
+	CoverageCmd.Flags().StringP(coverDirFlag, "d", ".", "The directory holding the coverage files")
+	CoverageCmd.Flags().Bool(plantUMLFlag, false, "print the UML diagram with the uncovered states and transitions colored")
+}
+
+func reportCoverage(w io.Writer, input, dir string, plantUML bool) error {
+	chart, err := readChart(input)
+	if err != nil {
+		return err
+	}
+
+	fsm := uml.Parse(chart)
+
+	records, err := coverage.Read(dir, fsm.Title)
+	if err != nil {
+		return err
+	}
+
+	report := coverage.Compute(fsm, records)
+
+	if plantUML {
+		_, err = fmt.Fprintln(w, coverage.Annotate(chart, fsm, report))
+
+		return err
+	}
+
+	return report.Print(w)
+}
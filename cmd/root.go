@@ -38,15 +38,24 @@ var (
 )
 
 const (
-	apiKindFlag    = "api-kind"
-	apiVersionFlag = "api-version"
-	groupFlag      = "group"
-	initFlag       = "init"
-	inputFlag      = "input"
-	moduleFlag     = "module"
-	operatorFlag   = "operator"
-	outputFlag     = "output"
-	packageFlag    = "package"
+	apiKindFlag      = "api-kind"
+	apiVersionFlag   = "api-version"
+	debugHandlerFlag = "debug-handler"
+	groupFlag        = "group"
+	initFlag         = "init"
+	inputFlag        = "input"
+	interfacesFlag   = "interfaces"
+	metricsFlag      = "metrics"
+	moduleFlag       = "module"
+	namingFlag       = "naming"
+	operatorFlag     = "operator"
+	otelFlag         = "otel"
+	outputFlag       = "output"
+	packageFlag      = "package"
+	prefixFlag       = "prefix"
+	runtimeFlag      = "runtime"
+	supervisorFlag   = "supervisor"
+	tableFlag        = "table"
 )
 
 var SM *statemachine.VectorSigma
@@ -71,7 +80,9 @@ VectorSigma takes PlantUML as input and generates a FSM.
 			SM.ExtendedState.Module = getModuleName()
 		}
 
-		return SM.Run()
+		_, err := SM.Run()
+
+		return err
 	},
 }
 
@@ -84,7 +95,9 @@ application generated from your UML diagram.`,
 		SM.ExtendedState.VectorSigmaVersion = getVersionInfo()
 		SM.ExtendedState.Init = true
 
-		return SM.Run()
+		_, err := SM.Run()
+
+		return err
 	},
 }
 
@@ -96,12 +109,14 @@ func Execute() {
 }
 
 func init() {
-	SM = statemachine.New()
+	SM = statemachine.New(statemachine.WithDebugFromEnv())
 
 	addCommonFlags(RootCmd)
 	addCommonFlags(InitCmd)
 
 	RootCmd.AddCommand(InitCmd)
+	RootCmd.AddCommand(TraceCmd)
+	RootCmd.AddCommand(CoverageCmd)
 	RootCmd.SetHelpCommand(&cobra.Command{Hidden: true})
 	RootCmd.Flags().StringVarP(&SM.ExtendedState.APIKind, apiKindFlag, "k", "", "API kind (only used if generating a k8s operator)")
 	RootCmd.Flags().StringVarP(&SM.ExtendedState.APIVersion, apiVersionFlag, "v", "", "API version (only used if generating a k8s operator)")
@@ -119,6 +134,24 @@ func addCommonFlags(cmd *cobra.Command) {
 	_ = cmd.MarkFlagRequired(inputFlag)
 	cmd.Flags().StringVarP(&SM.ExtendedState.Package, packageFlag, "p", "statemachine",
 		"The package name of the generated FSM")
+	cmd.Flags().BoolVar(&SM.ExtendedState.Interfaces, interfacesFlag, false,
+		"generate Actions and Guards interfaces, and fakes implementing them for tests")
+	cmd.Flags().BoolVar(&SM.ExtendedState.Runtime, runtimeFlag, false,
+		"import the engine from github.com/mhersson/vectorsigma/pkgs/runtime instead of generating it")
+	cmd.Flags().BoolVar(&SM.ExtendedState.Table, tableFlag, false,
+		"generate an engine with the states in a static table, for cheap machines and steps without allocations")
+	cmd.Flags().BoolVar(&SM.ExtendedState.DebugHandler, debugHandlerFlag, false,
+		"generate an http.Handler showing the state, steps and extended state of live machines")
+	cmd.Flags().BoolVar(&SM.ExtendedState.Metrics, metricsFlag, false,
+		"generate a Metrics observer with an expvar implementation, and a Prometheus one for operators")
+	cmd.Flags().BoolVar(&SM.ExtendedState.OTel, otelFlag, false,
+		"generate a Tracer starting OpenTelemetry spans for the steps and actions")
+	cmd.Flags().BoolVar(&SM.ExtendedState.Supervisor, supervisorFlag, false,
+		"generate a Supervisor running an instance of the machine with a mailbox for each key")
+	cmd.Flags().StringVar(&SM.ExtendedState.Naming, namingFlag, "plain",
+		"naming of the state, action and guard constants, plain (Loading) or qualified (StateLoading)")
+	cmd.Flags().StringVar(&SM.ExtendedState.Prefix, prefixFlag, "",
+		"prefix for the generated identifiers and files, to have several FSMs in the same package")
 }
 
 func getVersionInfo() string {
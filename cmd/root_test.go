@@ -49,6 +49,15 @@ func Test_IntegrationTest(t *testing.T) {
 		testdatafolder string
 		init           bool
 		operator       bool
+		interfaces     bool
+		runtime        bool
+		table          bool
+		debugHandler   bool
+		metrics        bool
+		otel           bool
+		supervisor     bool
+		prefix         string
+		naming         string
 		apiVersion     string
 		apiKind        string
 		group          string
@@ -65,6 +74,15 @@ func Test_IntegrationTest(t *testing.T) {
 			input:          "../uml/traffic-lights.plantuml",
 			pkg:            "fsm",
 		},
+		{
+			name:           "Initialize module with a prefix",
+			testdatafolder: "new_module_prefix",
+			output:         "output",
+			init:           true,
+			input:          "../uml/traffic-lights.plantuml",
+			pkg:            "fsm",
+			prefix:         "Light",
+		},
 		{
 			name:           "Generate package",
 			testdatafolder: "package",
@@ -73,6 +91,64 @@ func Test_IntegrationTest(t *testing.T) {
 			input:          "../uml/traffic-lights.plantuml",
 			pkg:            "fsm",
 		},
+		{
+			name:           "Generate package with interfaces",
+			testdatafolder: "interfaces",
+			output:         "output",
+			init:           false,
+			interfaces:     true,
+			input:          "../uml/traffic-lights.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "Generate package with metrics",
+			testdatafolder: "metrics",
+			output:         "output",
+			init:           false,
+			metrics:        true,
+			input:          "../uml/traffic-lights.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "Generate package using the runtime",
+			testdatafolder: "runtime",
+			output:         "output",
+			init:           false,
+			runtime:        true,
+			input:          "../uml/traffic-lights.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "Generate package with a prefix",
+			testdatafolder: "prefix",
+			output:         "output",
+			init:           false,
+			prefix:         "Crossing",
+			debugHandler:   true,
+			supervisor:     true,
+			input:          "../uml/traffic-lights.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "Generate package with qualified names",
+			testdatafolder: "qualified",
+			output:         "output",
+			init:           false,
+			otel:           true,
+			naming:         "qualified",
+			input:          "../uml/traffic-lights.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "Generate package with the table engine",
+			testdatafolder: "table",
+			output:         "output",
+			init:           false,
+			table:          true,
+			supervisor:     true,
+			input:          "../uml/traffic-lights.plantuml",
+			pkg:            "fsm",
+		},
 		{
 			name:           "k8s operator",
 			testdatafolder: "operator",
@@ -85,6 +161,116 @@ func Test_IntegrationTest(t *testing.T) {
 			group:          "unit",
 			apiKind:        "TestCRD",
 		},
+		{
+			name:           "k8s operator with metrics",
+			testdatafolder: "operator_metrics",
+			output:         "output",
+			init:           false,
+			metrics:        true,
+			input:          "../uml/operator.md",
+			pkg:            "fsm",
+			operator:       true,
+			apiVersion:     "v1",
+			group:          "unit",
+			apiKind:        "TestCRD",
+		},
+		{
+			name:           "k8s operator using the runtime",
+			testdatafolder: "operator_runtime",
+			output:         "output",
+			init:           false,
+			runtime:        true,
+			debugHandler:   true,
+			input:          "../uml/operator.md",
+			pkg:            "fsm",
+			operator:       true,
+			apiVersion:     "v1",
+			group:          "unit",
+			apiKind:        "TestCRD",
+		},
+		{
+			name:           "k8s operator using the table engine",
+			testdatafolder: "operator_table",
+			output:         "output",
+			init:           false,
+			otel:           true,
+			table:          true,
+			input:          "../uml/operator.md",
+			pkg:            "fsm",
+			operator:       true,
+			apiVersion:     "v1",
+			group:          "unit",
+			apiKind:        "TestCRD",
+		},
+		{
+			name:           "Generate package with retry policies",
+			testdatafolder: "retry",
+			output:         "output",
+			init:           false,
+			input:          "../uml/retry.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "Generate package with concurrent actions",
+			testdatafolder: "groups",
+			output:         "output",
+			init:           false,
+			input:          "../uml/groups.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "Generate package with timeouts",
+			testdatafolder: "timeouts",
+			output:         "output",
+			init:           false,
+			input:          "../uml/timeouts.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "Generate package with time transitions",
+			testdatafolder: "time_transitions",
+			output:         "output",
+			init:           false,
+			input:          "../uml/time-transitions.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "Generate package with compensations",
+			testdatafolder: "compensations",
+			output:         "output",
+			init:           false,
+			input:          "../uml/compensations.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "Generate package with compensations using the runtime",
+			testdatafolder: "compensations_runtime",
+			output:         "output",
+			init:           false,
+			runtime:        true,
+			input:          "../uml/compensations.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "Generate package with end states",
+			testdatafolder: "end_states",
+			output:         "output",
+			init:           false,
+			input:          "../uml/end-states.plantuml",
+			pkg:            "fsm",
+		},
+		{
+			name:           "k8s operator with time transitions",
+			testdatafolder: "operator_time_transitions",
+			output:         "output",
+			init:           false,
+			input:          "../uml/operator-time-transitions.md",
+			pkg:            "fsm",
+			operator:       true,
+			apiVersion:     "v1",
+			group:          "unit",
+			apiKind:        "TestCRD",
+		},
 	}
 
 	rootDir, _ := os.Getwd()
@@ -110,6 +296,15 @@ func Test_IntegrationTest(t *testing.T) {
 			cmd.SM.ExtendedState.Package = tt.pkg
 			cmd.SM.ExtendedState.Output = tt.output
 			cmd.SM.ExtendedState.Operator = tt.operator
+			cmd.SM.ExtendedState.Interfaces = tt.interfaces
+			cmd.SM.ExtendedState.Runtime = tt.runtime
+			cmd.SM.ExtendedState.Table = tt.table
+			cmd.SM.ExtendedState.DebugHandler = tt.debugHandler
+			cmd.SM.ExtendedState.Metrics = tt.metrics
+			cmd.SM.ExtendedState.OTel = tt.otel
+			cmd.SM.ExtendedState.Supervisor = tt.supervisor
+			cmd.SM.ExtendedState.Prefix = tt.prefix
+			cmd.SM.ExtendedState.Naming = tt.naming
 			cmd.SM.ExtendedState.APIVersion = tt.apiVersion
 			cmd.SM.ExtendedState.APIKind = tt.apiKind
 			cmd.SM.ExtendedState.Group = tt.group
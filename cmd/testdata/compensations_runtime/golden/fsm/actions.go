@@ -0,0 +1,31 @@
+package fsm
+
+// +vectorsigma:action:ChargeCard
+func (fsm *Order) ChargeCardAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:RefundCard
+func (fsm *Order) RefundCardAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:ReleaseStock
+func (fsm *Order) ReleaseStockAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:ReserveStock
+func (fsm *Order) ReserveStockAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:Ship
+func (fsm *Order) ShipAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
@@ -0,0 +1,211 @@
+package fsm_test
+
+import (
+	"compensations_runtime/output/fsm"
+	"testing"
+)
+
+// +vectorsigma:action:ChargeCard
+func TestOrder_ChargeCardAction(t *testing.T) {
+	type fields struct {
+		context       *fsm.Context
+		currentState  fsm.StateName
+		stateConfigs  map[fsm.StateName]fsm.StateConfig
+		ExtendedState *fsm.ExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &fsm.Order{
+				Context: tt.fields.context,
+				Machine: fsm.Machine{
+					CurrentState: tt.fields.currentState,
+					StateConfigs: tt.fields.stateConfigs,
+				},
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.ChargeCardAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("Order.ChargeCardAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// +vectorsigma:action:RefundCard
+func TestOrder_RefundCardAction(t *testing.T) {
+	type fields struct {
+		context       *fsm.Context
+		currentState  fsm.StateName
+		stateConfigs  map[fsm.StateName]fsm.StateConfig
+		ExtendedState *fsm.ExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &fsm.Order{
+				Context: tt.fields.context,
+				Machine: fsm.Machine{
+					CurrentState: tt.fields.currentState,
+					StateConfigs: tt.fields.stateConfigs,
+				},
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.RefundCardAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("Order.RefundCardAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// +vectorsigma:action:ReleaseStock
+func TestOrder_ReleaseStockAction(t *testing.T) {
+	type fields struct {
+		context       *fsm.Context
+		currentState  fsm.StateName
+		stateConfigs  map[fsm.StateName]fsm.StateConfig
+		ExtendedState *fsm.ExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &fsm.Order{
+				Context: tt.fields.context,
+				Machine: fsm.Machine{
+					CurrentState: tt.fields.currentState,
+					StateConfigs: tt.fields.stateConfigs,
+				},
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.ReleaseStockAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("Order.ReleaseStockAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// +vectorsigma:action:ReserveStock
+func TestOrder_ReserveStockAction(t *testing.T) {
+	type fields struct {
+		context       *fsm.Context
+		currentState  fsm.StateName
+		stateConfigs  map[fsm.StateName]fsm.StateConfig
+		ExtendedState *fsm.ExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &fsm.Order{
+				Context: tt.fields.context,
+				Machine: fsm.Machine{
+					CurrentState: tt.fields.currentState,
+					StateConfigs: tt.fields.stateConfigs,
+				},
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.ReserveStockAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("Order.ReserveStockAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// +vectorsigma:action:Ship
+func TestOrder_ShipAction(t *testing.T) {
+	type fields struct {
+		context       *fsm.Context
+		currentState  fsm.StateName
+		stateConfigs  map[fsm.StateName]fsm.StateConfig
+		ExtendedState *fsm.ExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &fsm.Order{
+				Context: tt.fields.context,
+				Machine: fsm.Machine{
+					CurrentState: tt.fields.currentState,
+					StateConfigs: tt.fields.stateConfigs,
+				},
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.ShipAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("Order.ShipAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package fsm
+
+// +vectorsigma:action:Review
+func (fsm *Codereview) ReviewAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
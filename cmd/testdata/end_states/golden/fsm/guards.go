@@ -0,0 +1,13 @@
+package fsm
+
+// +vectorsigma:guard:IsApproved
+func (fsm *Codereview) IsApprovedGuard(_ ...string) bool {
+	// TODO: Implement me!
+	return false
+}
+
+// +vectorsigma:guard:IsRejected
+func (fsm *Codereview) IsRejectedGuard(_ ...string) bool {
+	// TODO: Implement me!
+	return false
+}
@@ -0,0 +1,82 @@
+package fsm_test
+
+import (
+	"end_states/output/fsm"
+	"testing"
+)
+
+// +vectorsigma:guard:IsApproved
+func TestCodereview_IsApprovedGuard(t *testing.T) {
+	type fields struct {
+		context       *fsm.Context
+		currentState  fsm.StateName
+		stateConfigs  map[fsm.StateName]fsm.StateConfig
+		ExtendedState *fsm.ExtendedState
+	}
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &fsm.Codereview{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				StateConfigs:  tt.fields.stateConfigs,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if got := fsm.IsApprovedGuard(tt.args.params...); got != tt.want {
+				t.Errorf("Codereview.IsApprovedGuard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// +vectorsigma:guard:IsRejected
+func TestCodereview_IsRejectedGuard(t *testing.T) {
+	type fields struct {
+		context       *fsm.Context
+		currentState  fsm.StateName
+		stateConfigs  map[fsm.StateName]fsm.StateConfig
+		ExtendedState *fsm.ExtendedState
+	}
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &fsm.Codereview{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				StateConfigs:  tt.fields.stateConfigs,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if got := fsm.IsRejectedGuard(tt.args.params...); got != tt.want {
+				t.Errorf("Codereview.IsRejectedGuard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package fsm
+
+// +vectorsigma:action:FetchInvoices
+func (fsm *Aggregator) FetchInvoicesAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:FetchOrders
+func (fsm *Aggregator) FetchOrdersAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:FetchUsers
+func (fsm *Aggregator) FetchUsersAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:Prepare
+func (fsm *Aggregator) PrepareAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:Store
+func (fsm *Aggregator) StoreAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
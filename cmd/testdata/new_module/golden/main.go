@@ -7,8 +7,8 @@ import (
 )
 
 func main() {
-	SM := fsm.New()
-	err := SM.Run()
+	SM := fsm.New(fsm.WithDebugFromEnv())
+	_, err := SM.Run()
 	if err != nil {
 		fmt.Printf("State machine run ended with error: %v\n", err)
 	}
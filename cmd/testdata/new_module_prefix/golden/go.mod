@@ -0,0 +1,3 @@
+module new_module_prefix
+
+go 1.23
@@ -0,0 +1,233 @@
+// This file is generated by VectorSigma (devel). DO NOT EDIT.
+package fsm_test
+
+import (
+	"context"
+	"errors"
+	"new_module_prefix/internal/fsm"
+	"slices"
+	"testing"
+	"time"
+)
+
+// lightMaxPathLength stops a test when the machine does not follow the expected path.
+const lightMaxPathLength = 1000
+
+// lightErrPath fails the actions on the error transitions accepting all errors.
+var lightErrPath = errors.New("path error")
+
+// TestLightTrafficLight_Paths runs the paths from the InitialState to the
+// FinalState of the chart, with the actions replaced by no-ops and the guards
+// replaced by stubs taking the transitions of the path. The first action of a
+// state fails with an error matching the error transition of the path, and the
+// delays of the time transitions pass at once.
+func TestLightTrafficLight_Paths(t *testing.T) {
+	tests := []struct {
+		name    string
+		choices map[fsm.LightStateName][]int   // The transition taken at each visit of a state with guards
+		errors  map[fsm.LightStateName][]error // The error of the actions at each visit of a state with error transitions
+		want    []fsm.LightStateName
+	}{
+		{
+			name: "Red -> FinalState",
+			choices: map[fsm.LightStateName][]int{
+				fsm.LightRed: {0},
+			},
+			want: []fsm.LightStateName{
+				fsm.LightInitialState,
+				fsm.LightRed,
+				fsm.LightFinalState,
+			},
+		},
+		{
+			name: "Red -> Yellow -> FinalState",
+			choices: map[fsm.LightStateName][]int{
+				fsm.LightRed:    {1},
+				fsm.LightYellow: {0},
+			},
+			want: []fsm.LightStateName{
+				fsm.LightInitialState,
+				fsm.LightRed,
+				fsm.LightYellow,
+				fsm.LightFinalState,
+			},
+		},
+		{
+			name: "Red -> Yellow -> Green -> FinalState",
+			choices: map[fsm.LightStateName][]int{
+				fsm.LightGreen:  {0},
+				fsm.LightRed:    {1},
+				fsm.LightYellow: {1},
+			},
+			want: []fsm.LightStateName{
+				fsm.LightInitialState,
+				fsm.LightRed,
+				fsm.LightYellow,
+				fsm.LightGreen,
+				fsm.LightFinalState,
+			},
+		},
+		{
+			name: "Red -> Yellow -> Green -> FlashingYellow -> FinalState",
+			choices: map[fsm.LightStateName][]int{
+				fsm.LightFlashingYellow: {0},
+				fsm.LightGreen:          {1},
+				fsm.LightRed:            {1},
+				fsm.LightYellow:         {1},
+			},
+			want: []fsm.LightStateName{
+				fsm.LightInitialState,
+				fsm.LightRed,
+				fsm.LightYellow,
+				fsm.LightGreen,
+				fsm.LightFlashingYellow,
+				fsm.LightFinalState,
+			},
+		},
+		{
+			name: "Red -> Yellow -> Green -> FlashingYellow -> Red -> FinalState",
+			choices: map[fsm.LightStateName][]int{
+				fsm.LightFlashingYellow: {1},
+				fsm.LightGreen:          {1},
+				fsm.LightRed:            {1, 0},
+				fsm.LightYellow:         {1},
+			},
+			want: []fsm.LightStateName{
+				fsm.LightInitialState,
+				fsm.LightRed,
+				fsm.LightYellow,
+				fsm.LightGreen,
+				fsm.LightFlashingYellow,
+				fsm.LightRed,
+				fsm.LightFinalState,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := fsm.NewLight(fsm.WithLightClock(lightPathClock{}))
+			lightStubStateConfigs(machine.StateConfigs, &lightPathStub{
+				choices:      tt.choices,
+				errors:       tt.errors,
+				guardRounds:  make(map[fsm.LightStateName]int),
+				actionRounds: make(map[fsm.LightStateName]int),
+			})
+
+			got := []fsm.LightStateName{machine.CurrentState}
+
+			for len(got) < lightMaxPathLength {
+				result, err := machine.Step(context.Background())
+				if err != nil {
+					t.Fatalf("step failed after %v: %v", got, err)
+				}
+
+				got = append(got, result.NextState)
+
+				if result.Done {
+					break
+				}
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("visited %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// lightPathStub holds the transitions of a path, and counts the visits of each
+// state running its actions and evaluating its guards.
+type lightPathStub struct {
+	choices      map[fsm.LightStateName][]int
+	errors       map[fsm.LightStateName][]error
+	guardRounds  map[fsm.LightStateName]int
+	actionRounds map[fsm.LightStateName]int
+}
+
+// lightStubStateConfigs replaces the actions with no-ops, failing the first action
+// of a state on the error transitions of the path, and the guards with stubs
+// taking the transitions of the path. The timeouts are removed, as the delays
+// pass at once on the clock of the paths.
+func lightStubStateConfigs(configs map[fsm.LightStateName]fsm.LightStateConfig, stub *lightPathStub) {
+	for state, config := range configs {
+		for i := range config.Actions {
+			config.Actions[i].Execute = lightNoAction
+			config.Actions[i].Retry = nil
+			config.Actions[i].Timeout = 0
+		}
+
+		if len(config.Actions) > 0 {
+			config.Actions[0].Execute = stub.action(state)
+		}
+
+		for i := range config.Guards {
+			config.Guards[i].Check = stub.guard(state, i)
+
+			if config.Guards[i].Action != nil {
+				config.Guards[i].Action.Execute = lightNoAction
+				config.Guards[i].Action.Retry = nil
+			}
+		}
+
+		if config.Compensation != nil {
+			config.Compensation.Execute = lightNoAction
+		}
+
+		config.Timeout = 0
+		configs[state] = config
+
+		lightStubStateConfigs(config.Composite.StateConfigs, stub)
+	}
+}
+
+// guard returns a guard passing when the transition chosen for the current
+// round of guard evaluations of the state is the transition of the guard.
+func (s *lightPathStub) guard(state fsm.LightStateName, index int) func(...string) bool {
+	return func(...string) bool {
+		// The guards are always evaluated from the first one
+		if index == 0 {
+			s.guardRounds[state]++
+		}
+
+		round := s.guardRounds[state] - 1
+		if round >= len(s.choices[state]) {
+			return false
+		}
+
+		return s.choices[state][round] == index
+	}
+}
+
+// action returns the first action of the state, failing with the error chosen
+// for the current round of action executions of the state.
+func (s *lightPathStub) action(state fsm.LightStateName) func(...string) error {
+	return func(...string) error {
+		s.actionRounds[state]++
+
+		round := s.actionRounds[state] - 1
+		if round >= len(s.errors[state]) {
+			return nil
+		}
+
+		return s.errors[state][round]
+	}
+}
+
+func lightNoAction(...string) error {
+	return nil
+}
+
+// lightPathClock is a clock showing midnight, on which all delays pass at once.
+type lightPathClock struct{}
+
+func (lightPathClock) Now() time.Time {
+	return time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (c lightPathClock) After(time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+
+	return ch
+}
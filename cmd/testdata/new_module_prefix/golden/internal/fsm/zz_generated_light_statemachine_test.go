@@ -0,0 +1,28 @@
+// This file is generated by VectorSigma (devel). DO NOT EDIT.
+package fsm_test
+
+import (
+	"new_module_prefix/internal/fsm"
+	"testing"
+)
+
+func TestLightTrafficLight_Run(t *testing.T) {
+	type fields struct {
+		Context       *fsm.LightContext
+		CurrentState  fsm.LightStateName
+		ExtendedState *fsm.LightExtendedState
+		StateConfigs  map[fsm.LightStateName]fsm.LightStateConfig
+	}
+	tests := []struct {
+		name   string
+		fields fields
+	}{
+		{name: "Run the machine"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := fsm.NewLight()
+			fsm.Run()
+		})
+	}
+}
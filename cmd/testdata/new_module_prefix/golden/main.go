@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+
+	"new_module_prefix/internal/fsm"
+)
+
+func main() {
+	SM := fsm.NewLight(fsm.WithLightDebugFromEnv())
+	_, err := SM.Run()
+	if err != nil {
+		fmt.Printf("State machine run ended with error: %v\n", err)
+	}
+}
@@ -0,0 +1,25 @@
+package fsm
+
+// +vectorsigma:action:InitializeContext
+func (fsm *Testreconcileloop) InitializeContextAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:LoadObjects
+func (fsm *Testreconcileloop) LoadObjectsAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:SetReady
+func (fsm *Testreconcileloop) SetReadyAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:UpdateStatus
+func (fsm *Testreconcileloop) UpdateStatusAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
@@ -0,0 +1,45 @@
+package fsm_test
+
+import (
+	"prefix/output/fsm"
+	"testing"
+)
+
+// +vectorsigma:action:SwitchIn
+func TestCrossingTrafficLight_SwitchInAction(t *testing.T) {
+	type fields struct {
+		context       *fsm.CrossingContext
+		currentState  fsm.CrossingStateName
+		stateConfigs  map[fsm.CrossingStateName]fsm.CrossingStateConfig
+		ExtendedState *fsm.CrossingExtendedState
+	}
+
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &fsm.CrossingTrafficLight{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				StateConfigs:  tt.fields.stateConfigs,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if err := fsm.SwitchInAction(tt.args.params...); (err != nil) != tt.wantErr {
+				t.Errorf("TrafficLight.SwitchInAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
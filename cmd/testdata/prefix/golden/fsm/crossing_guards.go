@@ -0,0 +1,7 @@
+package fsm
+
+// +vectorsigma:guard:IsError
+func (fsm *CrossingTrafficLight) IsErrorGuard(_ ...string) bool {
+	// TODO: Implement me!
+	return false
+}
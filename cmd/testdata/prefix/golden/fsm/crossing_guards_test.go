@@ -0,0 +1,44 @@
+package fsm_test
+
+import (
+	"prefix/output/fsm"
+	"testing"
+)
+
+// +vectorsigma:guard:IsError
+func TestCrossingTrafficLight_IsErrorGuard(t *testing.T) {
+	type fields struct {
+		context       *fsm.CrossingContext
+		currentState  fsm.CrossingStateName
+		stateConfigs  map[fsm.CrossingStateName]fsm.CrossingStateConfig
+		ExtendedState *fsm.CrossingExtendedState
+	}
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &fsm.CrossingTrafficLight{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				StateConfigs:  tt.fields.stateConfigs,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if got := fsm.IsErrorGuard(tt.args.params...); got != tt.want {
+				t.Errorf("TrafficLight.IsErrorGuard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
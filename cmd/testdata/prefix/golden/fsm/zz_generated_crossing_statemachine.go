@@ -0,0 +1,1669 @@
+// This file is generated by VectorSigma (devel). DO NOT EDIT.
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	CrossingStateName  string
+	CrossingActionName string
+	CrossingGuardName  string
+)
+
+const (
+	CrossingFinalState     CrossingStateName = "FinalState"
+	CrossingFlashingYellow CrossingStateName = "FlashingYellow"
+	CrossingGreen          CrossingStateName = "Green"
+	CrossingInitialState   CrossingStateName = "InitialState"
+	CrossingRed            CrossingStateName = "Red"
+	CrossingYellow         CrossingStateName = "Yellow"
+)
+
+const (
+	CrossingSwitchIn CrossingActionName = "SwitchIn"
+)
+
+const (
+	CrossingIsError CrossingGuardName = "IsError"
+)
+
+// CrossingChart is the PlantUML source of the state machine, to show the machine on its
+// original diagram without access to the chart.
+const CrossingChart = `@startuml
+
+title Traffic Light
+[*] --> Red
+Red: SwitchIn(5)
+Red -[dotted]-> [*]: IsError
+Red --> Yellow
+
+Yellow: do / SwitchIn(1)
+Yellow -[dotted]-> [*]: [IsError
+Yellow --> Green
+
+FlashingYellow: do / SwitchIn(3)
+FlashingYellow -[dotted]-> [*]: [ IsError ]
+FlashingYellow -[bold]-> Red
+
+Green: SwitchIn(5)
+Green -[dotted]-> [*]:      IsError   ]
+Green --> FlashingYellow
+
+@enduml
+`
+
+const crossingMaxStateDepth = 5
+
+// ErrCrossingNoTransition is matched by errors returned when the machine gets stuck in a
+// state without any transition that can be taken.
+var ErrCrossingNoTransition = errors.New("no transition")
+
+// ErrCrossingMaxStepsExceeded is matched by errors returned when a run exceeds MaxSteps.
+var ErrCrossingMaxStepsExceeded = errors.New("max steps exceeded")
+
+// CrossingBackoff is the strategy used to compute the delay between retries.
+type CrossingBackoff string
+
+const (
+	CrossingBackoffConstant    CrossingBackoff = "constant"
+	CrossingBackoffLinear      CrossingBackoff = "linear"
+	CrossingBackoffExponential CrossingBackoff = "exponential"
+)
+
+// CrossingRetryPolicy describes how a failing action is retried before the error is
+// handled by the state machine.
+type CrossingRetryPolicy struct {
+	Max     int // The number of retries after the first attempt
+	Backoff CrossingBackoff
+	Base    time.Duration // The delay before the first retry
+}
+
+// Delay returns the delay before the given retry, counting from 1. A delay too
+// long for a time.Duration is cut to the longest one.
+func (p *CrossingRetryPolicy) Delay(retry int) time.Duration {
+	const maxDelay = time.Duration(1<<63 - 1)
+
+	switch p.Backoff {
+	case CrossingBackoffLinear:
+		if p.Base > 0 && time.Duration(retry) > maxDelay/p.Base {
+			return maxDelay
+		}
+
+		return p.Base * time.Duration(retry)
+	case CrossingBackoffExponential:
+		shift := min(retry-1, 30)
+		if p.Base > maxDelay>>shift {
+			return maxDelay
+		}
+
+		return p.Base << shift
+	default:
+		return p.Base
+	}
+}
+
+// CrossingClock provides the time to the state machine. Replace it to control the
+// delays between retries, and the timeouts, in tests.
+type CrossingClock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type crossingRealClock struct{}
+
+func (crossingRealClock) Now() time.Time { return time.Now() }
+
+func (crossingRealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// CrossingObserver is notified as the state machine makes progress.
+type CrossingObserver interface {
+	// ActionAttempted is called after each attempt to execute an action.
+	ActionAttempted(attempt CrossingActionAttempt)
+	// Stepped is called after each call to Step.
+	Stepped(result CrossingStepResult, err error)
+}
+
+// CrossingRunObserver is an Observer that is also notified when a run starts, to drop
+// what it kept of an earlier run.
+type CrossingRunObserver interface {
+	CrossingObserver
+	// RunStarted is called when RunContext starts a run.
+	RunStarted()
+}
+
+// CrossingTracer starts the spans of a state machine, like the OpenTelemetry adapter
+// generated with --otel. The span of a step is a child of the span in the
+// context passed to Step or RunContext, and the spans of the attempts to
+// execute the actions of the step are its children. A Tracer must be safe for
+// concurrent use, as the actions of a state can run concurrently.
+type CrossingTracer interface {
+	// StartState starts the span of a step in state, and returns ctx with the span.
+	StartState(ctx context.Context, state CrossingStateName) context.Context
+	// EndState ends the span in ctx with the result of the step. The step
+	// leaving a composite state starts in the end state inside it, and its
+	// result names the composite state as the PreviousState.
+	EndState(ctx context.Context, result CrossingStepResult, err error)
+	// StartAction starts the span of an attempt to execute an action, and
+	// returns ctx with the span.
+	StartAction(ctx context.Context, attempt CrossingActionAttempt) context.Context
+	// EndAction ends the span in ctx, with the duration and error of the attempt.
+	EndAction(ctx context.Context, attempt CrossingActionAttempt)
+}
+
+// CrossingActionAttempt describes a single attempt to execute an action.
+type CrossingActionAttempt struct {
+	State    CrossingStateName
+	Action   CrossingActionName
+	Attempt  int // Counting from 1
+	Duration time.Duration
+	Err      error
+}
+
+// CrossingDescription describes the structure of the state machine and the state it is
+// in. It holds no functions, and can be serialized to show the machine in
+// other tools.
+type CrossingDescription struct {
+	Title   string            `json:"title"`
+	Current CrossingStateName `json:"current"`
+	// Composites holds the composite states the machine is in, outermost first
+	Composites []CrossingStateName        `json:"composites,omitempty"`
+	States     []CrossingStateDescription `json:"states"`
+}
+
+// CrossingStateDescription describes a state, and the states inside it when it is a
+// composite state.
+type CrossingStateDescription struct {
+	Name         CrossingStateName               `json:"name"`
+	Actions      []CrossingActionDescription     `json:"actions,omitempty"`
+	Transitions  []CrossingTransitionDescription `json:"transitions,omitempty"`
+	Timeout      time.Duration                   `json:"timeout,omitempty"`
+	Compensation *CrossingActionDescription      `json:"compensation,omitempty"`
+	End          bool                            `json:"end,omitempty"`
+	States       []CrossingStateDescription      `json:"states,omitempty"`
+}
+
+// CrossingActionDescription describes an action and its parameters.
+type CrossingActionDescription struct {
+	Name   CrossingActionName `json:"name"`
+	Params []string           `json:"params,omitempty"`
+}
+
+// CrossingTransitionDescription describes a transition, in the order the transitions
+// of a state are tried. Event is the label of the transitions that are not
+// guarded, like "on error(ErrTimeout)", "on timeout" or "after(10s)".
+type CrossingTransitionDescription struct {
+	Target      CrossingStateName          `json:"target"`
+	Guard       CrossingGuardName          `json:"guard,omitempty"`
+	GuardParams []string                   `json:"guardParams,omitempty"`
+	Action      *CrossingActionDescription `json:"action,omitempty"`
+	Event       string                     `json:"event,omitempty"`
+}
+
+// CrossingGuardResult holds the outcome of a single guard evaluation.
+type CrossingGuardResult struct {
+	Name   CrossingGuardName `json:"name"`
+	Params []string          `json:"params,omitempty"`
+	Passed bool              `json:"passed"`
+}
+
+// CrossingStepResult describes what happened during a single call to Step.
+type CrossingStepResult struct {
+	Parent        CrossingStateName // The composite state the step was taken in, empty at the top level
+	PreviousState CrossingStateName
+	NextState     CrossingStateName
+	Guard         CrossingGuardName // The guard that fired, empty for unguarded transitions
+	Guards        []CrossingGuardResult
+	ActionErrors  []error
+	OnError       bool          // True when an error transition was taken
+	ErrorMatch    string        // The error accepted by the error transition taken, empty for all errors
+	TimedOut      bool          // True when the timeout transition was taken
+	Time          string        // The time event of the time transition taken, like after(10s)
+	Delay         time.Duration // The delay of the time transition taken
+	Done          bool          // True when the machine has reached the FinalState or an end state
+	// Compensations holds the outcome of the compensations run when the
+	// machine reached the FinalState on an error path
+	Compensations []CrossingCompensation
+}
+
+// CrossingOutcome tells how the machine ended. State is the FinalState when the
+// machine ended in [*], and the end state it entered otherwise.
+type CrossingOutcome struct {
+	State CrossingStateName
+}
+
+// CrossingCompensation is the outcome of the compensating action of a completed state.
+type CrossingCompensation struct {
+	State  CrossingStateName
+	Action CrossingActionName
+	Err    error
+}
+
+// CrossingNoTransitionError is returned when none of the guards of a state fired, and
+// the state has no unguarded transition to fall back on.
+type CrossingNoTransitionError struct {
+	State  CrossingStateName
+	Guards []CrossingGuardResult
+}
+
+func (e *CrossingNoTransitionError) Error() string {
+	results := make([]string, 0, len(e.Guards))
+	for _, guard := range e.Guards {
+		results = append(results, fmt.Sprintf("%s=%t", guard.Name, guard.Passed))
+	}
+
+	return fmt.Sprintf("no transition from state %s [%s]", e.State, strings.Join(results, ", "))
+}
+
+func (e *CrossingNoTransitionError) Is(target error) bool {
+	return target == ErrCrossingNoTransition
+}
+
+// CrossingMaxStepsExceededError is returned when a run takes more than MaxSteps steps
+// without reaching the FinalState. Trace holds the states that were visited.
+type CrossingMaxStepsExceededError struct {
+	MaxSteps int
+	Trace    []CrossingStateName
+}
+
+func (e *CrossingMaxStepsExceededError) Error() string {
+	const maxTraceLength = 10
+
+	trace := e.Trace
+	prefix := ""
+
+	if len(trace) > maxTraceLength {
+		trace = trace[len(trace)-maxTraceLength:]
+		prefix = "... -> "
+	}
+
+	states := make([]string, 0, len(trace))
+	for _, state := range trace {
+		states = append(states, string(state))
+	}
+
+	return fmt.Sprintf("max steps (%d) exceeded: %s%s", e.MaxSteps, prefix, strings.Join(states, " -> "))
+}
+
+func (e *CrossingMaxStepsExceededError) Is(target error) bool {
+	return target == ErrCrossingMaxStepsExceeded
+}
+
+// CrossingCompensationError is added to ExtendedState.Error when a compensating action
+// fails.
+type CrossingCompensationError struct {
+	State  CrossingStateName
+	Action CrossingActionName
+	Err    error
+}
+
+func (e *CrossingCompensationError) Error() string {
+	return fmt.Sprintf("compensation %s of state %s failed: %v", e.Action, e.State, e.Err)
+}
+
+func (e *CrossingCompensationError) Unwrap() error {
+	return e.Err
+}
+
+// CrossingDeadlineError is returned when an action, or the state running the action,
+// times out. It matches context.DeadlineExceeded.
+type CrossingDeadlineError struct {
+	State   CrossingStateName
+	Action  CrossingActionName // Empty when the state timed out
+	Timeout time.Duration
+	Err     error // The error returned by the action after its context was canceled
+}
+
+func (e *CrossingDeadlineError) Error() string {
+	if e.Action == "" {
+		return fmt.Sprintf("state %s timed out after %s", e.State, e.Timeout)
+	}
+
+	return fmt.Sprintf("action %s in state %s timed out after %s", e.Action, e.State, e.Timeout)
+}
+
+func (e *CrossingDeadlineError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CrossingDeadlineError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// CrossingTraceRecord describes a single step in an execution trace.
+type CrossingTraceRecord struct {
+	Step          int                         `json:"step"`
+	Parent        CrossingStateName           `json:"parent,omitempty"`
+	State         CrossingStateName           `json:"state"`
+	Actions       []CrossingTraceAction       `json:"actions,omitempty"`
+	Guards        []CrossingGuardResult       `json:"guards,omitempty"`
+	Guard         CrossingGuardName           `json:"guard,omitempty"`
+	Next          CrossingStateName           `json:"next"`
+	OnError       bool                        `json:"onError,omitempty"`
+	ErrorMatch    string                      `json:"errorMatch,omitempty"`
+	TimedOut      bool                        `json:"timedOut,omitempty"`
+	Time          string                      `json:"time,omitempty"`
+	Error         string                      `json:"error,omitempty"`
+	Done          bool                        `json:"done,omitempty"`
+	Compensations []CrossingTraceCompensation `json:"compensations,omitempty"`
+}
+
+// CrossingTraceAction describes a single attempt to execute an action in a trace.
+type CrossingTraceAction struct {
+	Name     CrossingActionName `json:"name"`
+	Attempt  int                `json:"attempt"`
+	Duration time.Duration      `json:"duration"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// CrossingTraceCompensation describes a compensation run at the end of a trace.
+type CrossingTraceCompensation struct {
+	State CrossingStateName  `json:"state"`
+	Name  CrossingActionName `json:"name"`
+	Error string             `json:"error,omitempty"`
+}
+
+// CrossingTraceRecorder is an Observer writing each step to a trace, as a line of JSON.
+type CrossingTraceRecorder struct {
+	encoder *json.Encoder
+	steps   int
+	actions []CrossingTraceAction
+	err     error
+}
+
+// NewCrossingTraceRecorder returns a TraceRecorder writing to w.
+func NewCrossingTraceRecorder(w io.Writer) *CrossingTraceRecorder {
+	return &CrossingTraceRecorder{encoder: json.NewEncoder(w)}
+}
+
+func (r *CrossingTraceRecorder) ActionAttempted(attempt CrossingActionAttempt) {
+	action := CrossingTraceAction{Name: attempt.Action, Attempt: attempt.Attempt, Duration: attempt.Duration}
+	if attempt.Err != nil {
+		action.Error = attempt.Err.Error()
+	}
+
+	r.actions = append(r.actions, action)
+}
+
+func (r *CrossingTraceRecorder) Stepped(result CrossingStepResult, err error) {
+	r.steps++
+
+	record := CrossingTraceRecord{
+		Step:       r.steps,
+		Parent:     result.Parent,
+		State:      result.PreviousState,
+		Actions:    r.actions,
+		Guards:     result.Guards,
+		Guard:      result.Guard,
+		Next:       result.NextState,
+		OnError:    result.OnError,
+		ErrorMatch: result.ErrorMatch,
+		TimedOut:   result.TimedOut,
+		Time:       result.Time,
+		Done:       result.Done,
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	for _, compensation := range result.Compensations {
+		traced := CrossingTraceCompensation{State: compensation.State, Name: compensation.Action}
+		if compensation.Err != nil {
+			traced.Error = compensation.Err.Error()
+		}
+
+		record.Compensations = append(record.Compensations, traced)
+	}
+
+	r.actions = nil
+
+	if err := r.encoder.Encode(record); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// Err returns the first error that occurred while writing the trace.
+func (r *CrossingTraceRecorder) Err() error {
+	return r.err
+}
+
+// crossingCoverageObserver is added to every state machine when built with the
+// vectorsigma_coverage build tag.
+var crossingCoverageObserver CrossingObserver
+
+// CrossingAction represents a function that can be executed in a state and may return an error.
+type CrossingAction struct {
+	Name    CrossingActionName
+	Params  []string
+	Execute func(...string) error
+	Retry   *CrossingRetryPolicy // Retries the action when it fails, nil means no retries
+	Group   int                  // Actions with the same non-zero group run concurrently
+	Timeout time.Duration        // Cancels the action context of each attempt after the duration, 0 means no limit
+}
+
+// CrossingGuard represents a function that returns a boolean indicating if a transition should occur.
+type CrossingGuard struct {
+	Name   CrossingGuardName
+	Params []string
+	Check  func(...string) bool
+	Action *CrossingAction
+}
+
+// CrossingStateConfig holds the actions and guards for a state.
+type CrossingStateConfig struct {
+	Actions          []CrossingAction
+	Guards           []CrossingGuard
+	Transitions      map[int]CrossingStateName // Maps guard index to the next state
+	ErrorTransitions []CrossingErrorTransition
+	Composite        CrossingCompositeState
+	Timeout          time.Duration     // The time the machine may stay in the state, 0 means no limit
+	TimeoutTarget    CrossingStateName // The state entered when the state or one of its actions times out
+	TimeTransitions  []CrossingTimeTransition
+	Compensation     *CrossingAction // Undoes the actions of the state when the machine ends on an error path
+	End              bool            // The machine ends when it enters the state, like in the FinalState
+}
+
+// CrossingErrorTransition moves the machine to Target when an action of the state fails
+// with an error accepted by Match.
+type CrossingErrorTransition struct {
+	Error  string // The declared error, empty if all errors are accepted
+	Match  func(error) bool
+	Target CrossingStateName
+}
+
+// CrossingTimeTransition moves the machine to Target when no other transition of the
+// state can be taken, a delay after the state was entered, or at a time of day.
+type CrossingTimeTransition struct {
+	After  time.Duration
+	At     string // The time of day, like "07:30", empty for a delay
+	Target CrossingStateName
+}
+
+// Delay returns the time left before the transition is taken, in a state that
+// was entered at entered.
+func (t CrossingTimeTransition) Delay(entered, now time.Time) time.Duration {
+	if t.At == "" {
+		return max(entered.Add(t.After).Sub(now), 0)
+	}
+
+	layout := "15:04"
+	if len(t.At) > len(layout) {
+		layout = "15:04:05"
+	}
+
+	at, err := time.Parse(layout, t.At)
+	if err != nil {
+		return 0
+	}
+
+	// The next time the clock shows the time of day
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), at.Second(), 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next.Sub(now)
+}
+
+// Event returns the time event of the transition as written in the chart, like
+// after(10s) or at(07:30).
+func (t CrossingTimeTransition) Event() string {
+	if t.At != "" {
+		return "at(" + t.At + ")"
+	}
+
+	return "after(" + t.After.String() + ")"
+}
+
+type CrossingCompositeState struct {
+	InitialState CrossingStateName
+	StateConfigs map[CrossingStateName]CrossingStateConfig
+}
+
+// crossingCompositeFrame holds the composite state being executed while the machine
+// runs its nested states.
+type crossingCompositeFrame struct {
+	state    CrossingStateName
+	config   CrossingStateConfig
+	deadline time.Time // The deadline of the composite state, zero if it has no timeout
+}
+
+// crossingCompletedState is a state with a compensation, that completed in the
+// current run.
+type crossingCompletedState struct {
+	state        CrossingStateName
+	compensation *CrossingAction
+}
+
+// VectorSigma represents the Finite State Machine (fsm) for VectorSigma.
+type CrossingTrafficLight struct {
+	Context       *CrossingContext
+	CurrentState  CrossingStateName
+	ExtendedState *CrossingExtendedState
+	StateConfigs  map[CrossingStateName]CrossingStateConfig
+	MaxSteps      int   // The maximum number of steps in a single run, 0 means no limit
+	HandledError  error // The last error handled by an error transition
+	Clock         CrossingClock
+	Observers     []CrossingObserver
+	Tracer        CrossingTracer
+	stack         []crossingCompositeFrame
+	actionCtx     context.Context
+	mu            sync.Mutex        // Guards the extended state and the observers while actions run concurrently
+	deadline      time.Time         // The deadline of the state with a timeout the machine is in
+	timedState    CrossingStateName // The state the deadline belongs to, empty if none
+	entered       time.Time         // The time the state with time transitions was entered
+	completed     []crossingCompletedState
+	outcome       CrossingOutcome
+}
+
+// CrossingOption configures the state machine created by New.
+type CrossingOption func(*CrossingTrafficLight)
+
+// crossingDefaultLogger is the logger of the state machines created without WithLogger.
+var crossingDefaultLogger = newCrossingLogger(slog.LevelInfo)
+
+// WithCrossingLogger sets the logger used by the state machine and its actions.
+func WithCrossingLogger(logger *slog.Logger) CrossingOption {
+	return func(fsm *CrossingTrafficLight) {
+		fsm.Context.Logger = logger
+	}
+}
+
+// WithCrossingDebugFromEnv enables debug logging to stdout when the
+// TRAFFICLIGHT_DEBUG environment variable is set.
+func WithCrossingDebugFromEnv() CrossingOption {
+	return func(fsm *CrossingTrafficLight) {
+		if os.Getenv("TRAFFICLIGHT_DEBUG") != "" {
+			fsm.Context.Logger = newCrossingLogger(slog.LevelDebug)
+		}
+	}
+}
+
+func newCrossingLogger(level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+}
+
+// WithCrossingContext replaces the context holding the items needed by the actions.
+func WithCrossingContext(context *CrossingContext) CrossingOption {
+	return func(fsm *CrossingTrafficLight) {
+		fsm.Context = context
+	}
+}
+
+// WithCrossingExtendedState replaces the extended state of the state machine.
+func WithCrossingExtendedState(state *CrossingExtendedState) CrossingOption {
+	return func(fsm *CrossingTrafficLight) {
+		fsm.ExtendedState = state
+	}
+}
+
+// WithCrossingObserver adds an observer to the state machine.
+func WithCrossingObserver(observer CrossingObserver) CrossingOption {
+	return func(fsm *CrossingTrafficLight) {
+		fsm.Observers = append(fsm.Observers, observer)
+	}
+}
+
+// WithCrossingTracer sets the tracer starting the spans of the state machine.
+func WithCrossingTracer(tracer CrossingTracer) CrossingOption {
+	return func(fsm *CrossingTrafficLight) {
+		fsm.Tracer = tracer
+	}
+}
+
+// WithCrossingInitialState sets the state the first run starts in, e.g. to resume a
+// machine where it stopped. Later runs start in the InitialState.
+func WithCrossingInitialState(state CrossingStateName) CrossingOption {
+	return func(fsm *CrossingTrafficLight) {
+		fsm.CurrentState = state
+	}
+}
+
+// WithCrossingTrace records a trace of each step to w, as lines of JSON.
+func WithCrossingTrace(w io.Writer) CrossingOption {
+	return WithCrossingObserver(NewCrossingTraceRecorder(w))
+}
+
+// WithCrossingMaxSteps limits the number of steps in a single run.
+func WithCrossingMaxSteps(maxSteps int) CrossingOption {
+	return func(fsm *CrossingTrafficLight) {
+		fsm.MaxSteps = maxSteps
+	}
+}
+
+// WithCrossingClock sets the clock used to wait between retries, and for the timeouts
+// and the time transitions.
+func WithCrossingClock(clock CrossingClock) CrossingOption {
+	return func(fsm *CrossingTrafficLight) {
+		fsm.Clock = clock
+	}
+}
+
+// NewCrossing initializes a new FSM. The options are applied in the given order,
+// before the states are configured.
+func NewCrossing(opts ...CrossingOption) *CrossingTrafficLight {
+	fsm := &CrossingTrafficLight{
+		Context:       &CrossingContext{Logger: crossingDefaultLogger},
+		CurrentState:  CrossingInitialState,
+		ExtendedState: &CrossingExtendedState{},
+		StateConfigs:  make(map[CrossingStateName]CrossingStateConfig),
+		Clock:         crossingRealClock{},
+	}
+
+	if crossingCoverageObserver != nil {
+		fsm.Observers = append(fsm.Observers, crossingCoverageObserver)
+	}
+
+	for _, opt := range opts {
+		opt(fsm)
+	}
+	fsm.StateConfigs[CrossingFlashingYellow] = CrossingStateConfig{
+		Actions: []CrossingAction{
+			{Name: CrossingSwitchIn, Execute: fsm.SwitchInAction, Params: []string{"3"}},
+		},
+		Guards: []CrossingGuard{
+			{Name: CrossingIsError, Params: []string{}, Check: fsm.IsErrorGuard},
+		},
+		Transitions: map[int]CrossingStateName{
+			0: CrossingFinalState,
+			1: CrossingRed,
+		},
+	}
+	fsm.StateConfigs[CrossingGreen] = CrossingStateConfig{
+		Actions: []CrossingAction{
+			{Name: CrossingSwitchIn, Execute: fsm.SwitchInAction, Params: []string{"5"}},
+		},
+		Guards: []CrossingGuard{
+			{Name: CrossingIsError, Params: []string{}, Check: fsm.IsErrorGuard},
+		},
+		Transitions: map[int]CrossingStateName{
+			0: CrossingFinalState,
+			1: CrossingFlashingYellow,
+		},
+	}
+	fsm.StateConfigs[CrossingInitialState] = CrossingStateConfig{
+		Actions: []CrossingAction{},
+		Guards:  []CrossingGuard{},
+		Transitions: map[int]CrossingStateName{
+			0: CrossingRed,
+		},
+	}
+	fsm.StateConfigs[CrossingRed] = CrossingStateConfig{
+		Actions: []CrossingAction{
+			{Name: CrossingSwitchIn, Execute: fsm.SwitchInAction, Params: []string{"5"}},
+		},
+		Guards: []CrossingGuard{
+			{Name: CrossingIsError, Params: []string{}, Check: fsm.IsErrorGuard},
+		},
+		Transitions: map[int]CrossingStateName{
+			0: CrossingFinalState,
+			1: CrossingYellow,
+		},
+	}
+	fsm.StateConfigs[CrossingYellow] = CrossingStateConfig{
+		Actions: []CrossingAction{
+			{Name: CrossingSwitchIn, Execute: fsm.SwitchInAction, Params: []string{"1"}},
+		},
+		Guards: []CrossingGuard{
+			{Name: CrossingIsError, Params: []string{}, Check: fsm.IsErrorGuard},
+		},
+		Transitions: map[int]CrossingStateName{
+			0: CrossingFinalState,
+			1: CrossingGreen,
+		},
+	}
+
+	return fsm
+}
+
+// RunContext is Run with a context, which is passed to the actions through
+// ActionContext, and carries the parent span of the steps.
+func (fsm *CrossingTrafficLight) RunContext(ctx context.Context) (CrossingOutcome, error) {
+	fsm.outcome = CrossingOutcome{}
+
+	for _, observer := range fsm.Observers {
+		if observer, ok := observer.(CrossingRunObserver); ok {
+			observer.RunStarted()
+		}
+	}
+
+	var trace []CrossingStateName
+	if fsm.MaxSteps > 0 {
+		trace = append(trace, fsm.CurrentState)
+	}
+
+	for steps := 0; ; steps++ {
+		if fsm.MaxSteps > 0 && steps >= fsm.MaxSteps {
+			err := &CrossingMaxStepsExceededError{MaxSteps: fsm.MaxSteps, Trace: trace}
+			fsm.logError(err, "max steps exceeded", "state", fsm.CurrentState)
+
+			fsm.fail(ctx, err)
+			fsm.reset()
+
+			return CrossingOutcome{}, err
+		}
+
+		result, err := fsm.Step(ctx)
+		if err != nil {
+			fsm.reset()
+
+			return CrossingOutcome{}, err
+		}
+
+		if fsm.MaxSteps > 0 {
+			trace = append(trace, result.NextState)
+		}
+
+		if result.Done {
+			// Reset to the Initial State in case the FSM is run in a loop
+			fsm.reset()
+
+			return fsm.outcome, fsm.ExtendedState.Error
+		}
+	}
+}
+
+// reset moves the machine back to the InitialState when a run ends, also when
+// it fails inside a composite state, so the next run starts from the beginning.
+func (fsm *CrossingTrafficLight) reset() {
+	fsm.CurrentState = CrossingInitialState
+	fsm.stack = nil
+	fsm.completed = nil
+	fsm.deadline = time.Time{}
+	fsm.timedState = ""
+}
+
+// Describe returns the structure of the state machine, and the state it is in.
+// It must not be called while the machine is stepping, but can be called from
+// an Observer.
+func (fsm *CrossingTrafficLight) Describe() CrossingDescription {
+	description := CrossingDescription{Title: "TrafficLight", Current: fsm.CurrentState, States: crossingDescribeStates(fsm.StateConfigs)}
+
+	for _, frame := range fsm.stack {
+		description.Composites = append(description.Composites, frame.state)
+	}
+
+	return description
+}
+
+// crossingDescribeStates describes the states of configs, ordered by name.
+func crossingDescribeStates(configs map[CrossingStateName]CrossingStateConfig) []CrossingStateDescription {
+	if len(configs) == 0 {
+		return nil
+	}
+
+	names := make([]CrossingStateName, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	states := make([]CrossingStateDescription, 0, len(names))
+
+	for _, name := range names {
+		config := configs[name]
+		state := CrossingStateDescription{
+			Name:         name,
+			Timeout:      config.Timeout,
+			Compensation: crossingDescribeAction(config.Compensation),
+			End:          config.End,
+			States:       crossingDescribeStates(config.Composite.StateConfigs),
+		}
+
+		for i := range config.Actions {
+			state.Actions = append(state.Actions, *crossingDescribeAction(&config.Actions[i]))
+		}
+
+		state.Transitions = crossingDescribeTransitions(config)
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// crossingDescribeTransitions describes the transitions of a state, guarded first.
+func crossingDescribeTransitions(config CrossingStateConfig) []CrossingTransitionDescription {
+	var transitions []CrossingTransitionDescription
+
+	for i, guard := range config.Guards {
+		transitions = append(transitions, CrossingTransitionDescription{
+			Target:      config.Transitions[i],
+			Guard:       guard.Name,
+			GuardParams: guard.Params,
+			Action:      crossingDescribeAction(guard.Action),
+		})
+	}
+
+	if target, ok := config.Transitions[len(config.Guards)]; ok {
+		transitions = append(transitions, CrossingTransitionDescription{Target: target})
+	}
+
+	for _, transition := range config.ErrorTransitions {
+		event := "on error"
+		if transition.Error != "" {
+			event += "(" + transition.Error + ")"
+		}
+
+		transitions = append(transitions, CrossingTransitionDescription{Target: transition.Target, Event: event})
+	}
+
+	if config.TimeoutTarget != "" {
+		transitions = append(transitions, CrossingTransitionDescription{Target: config.TimeoutTarget, Event: "on timeout"})
+	}
+
+	for _, transition := range config.TimeTransitions {
+		transitions = append(transitions, CrossingTransitionDescription{Target: transition.Target, Event: transition.Event()})
+	}
+
+	return transitions
+}
+
+// crossingDescribeAction describes an action, and returns nil for a nil action.
+func crossingDescribeAction(action *CrossingAction) *CrossingActionDescription {
+	if action == nil {
+		return nil
+	}
+
+	return &CrossingActionDescription{Name: action.Name, Params: action.Params}
+}
+
+// Outcome returns how the last run of the machine ended, or the zero Outcome
+// while the machine has not ended.
+func (fsm *CrossingTrafficLight) Outcome() CrossingOutcome {
+	return fsm.outcome
+}
+
+// Step executes the actions of the current state, evaluates its guards and
+// moves the machine to the next state. Entering and leaving a composite state
+// are steps of their own.
+func (fsm *CrossingTrafficLight) Step(ctx context.Context) (CrossingStepResult, error) {
+	if fsm.Tracer != nil {
+		ctx = fsm.Tracer.StartState(ctx, fsm.CurrentState)
+	}
+
+	result, err := fsm.step(ctx)
+
+	if result.Done {
+		fsm.outcome = CrossingOutcome{State: fsm.CurrentState}
+	}
+
+	if result.Done || err != nil {
+		fsm.compensate(ctx, &result, err)
+	}
+
+	if fsm.CurrentState != fsm.timedState {
+		// The deadline is kept while the machine stays in the state
+		fsm.timedState = ""
+	}
+
+	if fsm.Tracer != nil {
+		fsm.Tracer.EndState(ctx, result, err)
+	}
+
+	for _, observer := range fsm.Observers {
+		observer.Stepped(result, err)
+	}
+
+	return result, err
+}
+
+// fail ends the run with err in the current state without taking a step. It is
+// reported like a failing step: the compensations are run, and the Tracer and
+// the Observers are notified.
+func (fsm *CrossingTrafficLight) fail(ctx context.Context, err error) {
+	if fsm.Tracer != nil {
+		ctx = fsm.Tracer.StartState(ctx, fsm.CurrentState)
+	}
+
+	result := CrossingStepResult{Parent: fsm.parent(), PreviousState: fsm.CurrentState, NextState: fsm.CurrentState}
+	fsm.compensate(ctx, &result, err)
+
+	if fsm.Tracer != nil {
+		fsm.Tracer.EndState(ctx, result, err)
+	}
+
+	for _, observer := range fsm.Observers {
+		observer.Stepped(result, err)
+	}
+}
+
+func (fsm *CrossingTrafficLight) step(ctx context.Context) (CrossingStepResult, error) {
+	result := CrossingStepResult{Parent: fsm.parent(), PreviousState: fsm.CurrentState, NextState: fsm.CurrentState}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	fsm.actionCtx = ctx
+
+	if i := fsm.expiredComposite(); i >= 0 {
+		// The composite state times out, leaving the states inside it
+		frame := fsm.stack[i]
+		fsm.stack = fsm.stack[:i]
+		fsm.CurrentState = frame.state
+		fsm.timedState = frame.state
+		fsm.deadline = frame.deadline
+		result.Parent = fsm.parent()
+		result.PreviousState = frame.state
+
+		return fsm.expire(result, frame.config, &CrossingDeadlineError{State: frame.state, Timeout: frame.config.Timeout})
+	}
+
+	if fsm.ended(fsm.CurrentState) {
+		if len(fsm.stack) == 0 {
+			result.Done = true
+
+			return result, nil
+		}
+
+		return fsm.exitComposite(ctx, result)
+	}
+
+	config, exists := fsm.stateConfigs()[fsm.CurrentState]
+	if !exists {
+		err := fmt.Errorf("missing config for state: %s", fsm.CurrentState)
+		fsm.logError(err, "missing config", "state", fsm.CurrentState)
+
+		if len(fsm.stack) == 0 {
+			return result, err
+		}
+
+		// A broken composite state is handled by the guards of its parent
+		fsm.ExtendedState.Error = err
+
+		return fsm.exitComposite(ctx, result)
+	}
+
+	var err error
+
+	if config.Composite.StateConfigs != nil {
+		frame := crossingCompositeFrame{state: fsm.CurrentState, config: config}
+
+		if config.Timeout > 0 {
+			if fsm.startDeadline(fsm.clock().Now(), config) <= 0 {
+				return fsm.expire(result, config, &CrossingDeadlineError{State: fsm.CurrentState, Timeout: config.Timeout})
+			}
+
+			frame.deadline = fsm.deadline
+		}
+
+		if len(fsm.stack) < crossingMaxStateDepth {
+			fsm.stack = append(fsm.stack, frame)
+			fsm.CurrentState = config.Composite.InitialState
+			fsm.logDebug("entering composite state", "state", result.PreviousState, "initial", fsm.CurrentState)
+			result.NextState = fsm.CurrentState
+
+			return result, nil
+		}
+
+		err = errors.New("max state depth exceeded")
+		fsm.logError(err, "composite state machine failed", "state", fsm.CurrentState)
+	} else {
+		if len(config.TimeTransitions) > 0 {
+			fsm.entered = fsm.clock().Now()
+		}
+
+		// Execute all actions for the current state, before its deadline
+		err = fsm.runStateActions(ctx, config)
+
+		if config.TimeoutTarget != "" && crossingAsError[*CrossingDeadlineError](err) {
+			result.ActionErrors = append(result.ActionErrors, err)
+
+			return fsm.timeout(result, config.TimeoutTarget, err), nil
+		}
+
+		if err != nil {
+			fsm.logError(err, "action failed", "state", fsm.CurrentState)
+			result.ActionErrors = append(result.ActionErrors, err)
+		} else if config.Compensation != nil {
+			fsm.completed = append(fsm.completed, crossingCompletedState{state: fsm.CurrentState, compensation: config.Compensation})
+		}
+	}
+
+	if err != nil {
+		fsm.ExtendedState.Error = err
+
+		if routed, ok := fsm.routeError(result, config, err); ok {
+			return routed, nil
+		}
+	}
+
+	return fsm.transition(ctx, result, config)
+}
+
+// ActionContext returns the context of the running actions. It is canceled
+// with the context of the step, when the action or its state times out, and
+// when an action running concurrently with the action fails.
+func (fsm *CrossingTrafficLight) ActionContext() context.Context {
+	if fsm.actionCtx == nil {
+		return context.Background()
+	}
+
+	return fsm.actionCtx
+}
+
+// UpdateExtendedState calls update with the extended state, while holding the
+// lock of the state machine. Actions running concurrently must read and write
+// the extended state through it.
+func (fsm *CrossingTrafficLight) UpdateExtendedState(update func(state *CrossingExtendedState)) {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	update(fsm.ExtendedState)
+}
+
+// stateConfigs returns the state configs of the innermost running state machine.
+func (fsm *CrossingTrafficLight) stateConfigs() map[CrossingStateName]CrossingStateConfig {
+	if len(fsm.stack) == 0 {
+		return fsm.StateConfigs
+	}
+
+	return fsm.stack[len(fsm.stack)-1].config.Composite.StateConfigs
+}
+
+// parent returns the innermost composite state being executed, or an empty
+// name at the top level.
+func (fsm *CrossingTrafficLight) parent() CrossingStateName {
+	if len(fsm.stack) == 0 {
+		return ""
+	}
+
+	return fsm.stack[len(fsm.stack)-1].state
+}
+
+// ended reports whether the state ends the state machine it belongs to, being
+// the FinalState or an end state.
+func (fsm *CrossingTrafficLight) ended(state CrossingStateName) bool {
+	return state == CrossingFinalState || fsm.stateConfigs()[state].End
+}
+
+// exitComposite leaves the innermost composite state and continues with the
+// guards of the composite state itself.
+func (fsm *CrossingTrafficLight) exitComposite(ctx context.Context, result CrossingStepResult) (CrossingStepResult, error) {
+	frame := fsm.stack[len(fsm.stack)-1]
+	fsm.stack = fsm.stack[:len(fsm.stack)-1]
+
+	fsm.logDebug("exiting composite state", "state", frame.state)
+	fsm.CurrentState = frame.state
+	result.Parent = fsm.parent()
+	result.PreviousState = frame.state
+
+	if !frame.deadline.IsZero() {
+		// The deadline is kept while the machine stays in the composite state
+		fsm.timedState = frame.state
+		fsm.deadline = frame.deadline
+	}
+
+	if len(frame.config.TimeTransitions) > 0 {
+		// The delays of a composite state count from the end of its state machine
+		fsm.entered = fsm.clock().Now()
+	}
+
+	if err := fsm.ExtendedState.Error; err != nil {
+		fsm.logError(err, "composite state machine failed", "state", frame.state)
+
+		if routed, ok := fsm.routeError(result, frame.config, err); ok {
+			return routed, nil
+		}
+	} else if frame.config.Compensation != nil {
+		fsm.completed = append(fsm.completed, crossingCompletedState{state: frame.state, compensation: frame.config.Compensation})
+	}
+
+	return fsm.transition(ctx, result, frame.config)
+}
+
+// transition checks the guards of the current state and moves to the next
+// state. A NoTransitionError is returned if no transition can be taken.
+func (fsm *CrossingTrafficLight) transition(ctx context.Context, result CrossingStepResult, config CrossingStateConfig) (CrossingStepResult, error) {
+	nextState, err := fsm.runAllGuards(ctx, config, &result)
+	if err != nil {
+		fsm.ExtendedState.Error = err
+		result.ActionErrors = append(result.ActionErrors, err)
+
+		if routed, ok := fsm.routeError(result, config, err); ok {
+			return routed, nil
+		}
+
+		// Guarded actions without a matching error transition end in the FinalState
+		nextState = CrossingFinalState
+	}
+
+	if nextState == "" {
+		// Check for unguarded transition
+		if next, exists := config.Transitions[len(config.Guards)]; exists {
+			fsm.logDebug("unguarded transition", "current", fsm.CurrentState, "next", next)
+			nextState = next
+		}
+	}
+
+	if nextState == "" && len(config.TimeTransitions) > 0 {
+		if nextState, err = fsm.takeTimeTransition(ctx, config, &result); err != nil {
+			if crossingAsError[*CrossingDeadlineError](err) {
+				return fsm.expire(result, config, err)
+			}
+
+			return result, err
+		}
+	}
+
+	if nextState == "" {
+		err := &CrossingNoTransitionError{State: fsm.CurrentState, Guards: result.Guards}
+		fsm.logError(err, "no transition", "state", fsm.CurrentState)
+
+		return result, err
+	}
+
+	fsm.CurrentState = nextState
+	result.NextState = nextState
+	result.Done = fsm.ended(nextState) && len(fsm.stack) == 0
+
+	return result, nil
+}
+
+// takeTimeTransition takes the earliest of the time transitions of the state
+// after its delay, and returns its target. A DeadlineError is returned if the
+// deadline of the state passes first.
+func (fsm *CrossingTrafficLight) takeTimeTransition(ctx context.Context, config CrossingStateConfig, result *CrossingStepResult) (CrossingStateName, error) {
+	now := fsm.clock().Now()
+
+	next := config.TimeTransitions[0]
+	delay := next.Delay(fsm.entered, now)
+
+	for _, transition := range config.TimeTransitions[1:] {
+		if d := transition.Delay(fsm.entered, now); d < delay {
+			next, delay = transition, d
+		}
+	}
+
+	timed := config.Timeout > 0 && fsm.timedState == fsm.CurrentState
+	if timed {
+		delay = min(delay, max(fsm.deadline.Sub(now), 0))
+	}
+
+	if err := fsm.wait(ctx, delay); err != nil {
+		return "", err
+	}
+
+	if timed && !fsm.clock().Now().Before(fsm.deadline) {
+		return "", &CrossingDeadlineError{State: fsm.CurrentState, Timeout: config.Timeout}
+	}
+
+	fsm.logDebug("time transition", "current", fsm.CurrentState, "next", next.Target, "delay", delay)
+	result.Time = next.Event()
+	result.Delay = delay
+
+	return next.Target, nil
+}
+
+// timeout takes the timeout transition of the state, after the state or one of
+// its actions timed out.
+func (fsm *CrossingTrafficLight) timeout(result CrossingStepResult, target CrossingStateName, err error) CrossingStepResult {
+	fsm.logWarn("timeout transition", "current", fsm.CurrentState, "next", target, "error", err)
+	fsm.CurrentState = target
+	result.NextState = target
+	result.TimedOut = true
+	result.Done = fsm.ended(target) && len(fsm.stack) == 0
+
+	return result
+}
+
+// expire handles the deadline of the current state passing outside of its
+// actions, while the machine waits for a time transition or runs the state
+// machine of a composite state. The machine takes the timeout transition of the
+// state, or an error transition matching the DeadlineError, and the step fails
+// with the DeadlineError otherwise.
+func (fsm *CrossingTrafficLight) expire(result CrossingStepResult, config CrossingStateConfig, err error) (CrossingStepResult, error) {
+	result.ActionErrors = append(result.ActionErrors, err)
+
+	if config.TimeoutTarget != "" {
+		return fsm.timeout(result, config.TimeoutTarget, err), nil
+	}
+
+	fsm.logError(err, "state timed out", "state", fsm.CurrentState)
+	fsm.ExtendedState.Error = err
+
+	if routed, ok := fsm.routeError(result, config, err); ok {
+		return routed, nil
+	}
+
+	return result, err
+}
+
+// expiredComposite returns the position in the stack of the outermost
+// composite state the machine is in whose deadline has passed, or -1.
+func (fsm *CrossingTrafficLight) expiredComposite() int {
+	var now time.Time
+
+	for i := range fsm.stack {
+		if fsm.stack[i].deadline.IsZero() {
+			continue
+		}
+
+		if now.IsZero() {
+			now = fsm.clock().Now()
+		}
+
+		if !now.Before(fsm.stack[i].deadline) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// compensate runs the compensations of the completed states in reverse order,
+// when the machine reached the FinalState on an error path. That is with
+// ExtendedState.Error set, or through an error or timeout transition. They are
+// also run when the run fails with stepErr, like a NoTransitionError, a
+// MaxStepsExceededError or the error of a canceled context. The errors of the
+// compensations are added to ExtendedState.Error.
+func (fsm *CrossingTrafficLight) compensate(ctx context.Context, result *CrossingStepResult, stepErr error) {
+	completed := fsm.completed
+	fsm.completed = nil
+
+	if stepErr == nil && fsm.ExtendedState.Error == nil && !result.OnError && !result.TimedOut {
+		return
+	}
+
+	// The compensations also run when the run was canceled
+	fsm.actionCtx = context.WithoutCancel(ctx)
+
+	for i := len(completed) - 1; i >= 0; i-- {
+		state, action := completed[i].state, completed[i].compensation
+
+		fsm.logDebug("compensating", "state", state, "action", action.Name)
+
+		compensation := CrossingCompensation{State: state, Action: action.Name, Err: action.Execute(action.Params...)}
+		if compensation.Err != nil {
+			err := &CrossingCompensationError{State: state, Action: action.Name, Err: compensation.Err}
+			fsm.logError(err, "compensation failed", "state", state, "action", action.Name)
+			fsm.ExtendedState.Error = errors.Join(fsm.ExtendedState.Error, err)
+		}
+
+		result.Compensations = append(result.Compensations, compensation)
+	}
+}
+
+// routeError takes the first error transition of config accepting err. The
+// error is then handled, and is moved from ExtendedState.Error to HandledError.
+func (fsm *CrossingTrafficLight) routeError(result CrossingStepResult, config CrossingStateConfig, err error) (CrossingStepResult, bool) {
+	for _, transition := range config.ErrorTransitions {
+		if !transition.Match(err) {
+			continue
+		}
+
+		fsm.logDebug("error transition", "current", fsm.CurrentState, "next", transition.Target,
+			"match", transition.Error, "error", err)
+		fsm.ExtendedState.Error = nil
+		fsm.HandledError = err
+		fsm.CurrentState = transition.Target
+		result.NextState = transition.Target
+		result.OnError = true
+		result.ErrorMatch = transition.Error
+		result.Done = fsm.ended(transition.Target) && len(fsm.stack) == 0
+
+		return result, true
+	}
+
+	return result, false
+}
+
+// crossingAnyError accepts all errors.
+func crossingAnyError(error) bool {
+	return true
+}
+
+// crossingIsError returns a matcher accepting errors that wrap the target error.
+func crossingIsError(target error) func(error) bool {
+	return func(err error) bool {
+		return errors.Is(err, target)
+	}
+}
+
+// crossingAsError accepts errors that wrap an error of type T.
+func crossingAsError[T error](err error) bool {
+	var target T
+
+	return errors.As(err, &target)
+}
+
+// startDeadline sets the deadline of the current state, which has a timeout,
+// when the machine enters it. The deadline is kept while the machine stays in
+// the state. It returns the time left before the deadline.
+func (fsm *CrossingTrafficLight) startDeadline(now time.Time, config CrossingStateConfig) time.Duration {
+	if fsm.timedState != fsm.CurrentState {
+		fsm.timedState = fsm.CurrentState
+		fsm.deadline = now.Add(config.Timeout)
+	}
+
+	return fsm.deadline.Sub(now)
+}
+
+// runStateActions executes the actions of the state. When the state has a
+// timeout, a DeadlineError is returned if the deadline has passed before the
+// actions are run, or expires while they run, so the guards of the state are
+// not checked after the deadline.
+func (fsm *CrossingTrafficLight) runStateActions(ctx context.Context, config CrossingStateConfig) error {
+	if config.Timeout <= 0 {
+		return fsm.runAllActions(ctx, config.Actions)
+	}
+
+	clock := fsm.clock()
+	timedOut := &CrossingDeadlineError{State: fsm.CurrentState, Timeout: config.Timeout}
+
+	remaining := fsm.startDeadline(clock.Now(), config)
+	if remaining <= 0 {
+		return timedOut
+	}
+
+	stateCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fsm.actionCtx = stateCtx
+	timer := crossingStartTimer(clock, remaining, cancel)
+	err := fsm.runAllActions(stateCtx, config.Actions)
+	fsm.actionCtx = ctx
+
+	if timer.stop() {
+		timedOut.Err = err
+
+		return timedOut
+	}
+
+	return err
+}
+
+func (fsm *CrossingTrafficLight) runAllActions(ctx context.Context, actions []CrossingAction) error {
+	for i := 0; i < len(actions); {
+		// The actions of a group are run together
+		end := i + 1
+		for actions[i].Group != 0 && end < len(actions) && actions[end].Group == actions[i].Group {
+			end++
+		}
+
+		var err error
+		if end-i == 1 {
+			err = fsm.runAction(ctx, actions[i], nil)
+		} else {
+			err = fsm.runConcurrently(ctx, actions[i:end])
+		}
+
+		if err != nil {
+			return err
+		}
+
+		i = end
+	}
+
+	return nil
+}
+
+// runConcurrently executes the actions concurrently. The first error cancels
+// the context of the other actions, and the errors of all the actions that
+// failed are joined. The errors caused by the cancellation are left out.
+func (fsm *CrossingTrafficLight) runConcurrently(ctx context.Context, actions []CrossingAction) error {
+	groupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fsm.actionCtx = groupCtx
+	errs := make([]error, len(actions))
+	first := -1
+
+	var (
+		once sync.Once
+		wg   sync.WaitGroup
+	)
+
+	for i, action := range actions {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			fail := func() {
+				once.Do(func() {
+					first = i
+
+					cancel()
+				})
+			}
+
+			if errs[i] = fsm.runAction(groupCtx, action, fail); errs[i] != nil {
+				fail()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	fsm.actionCtx = ctx
+
+	if ctx.Err() == nil {
+		for i, err := range errs {
+			if i != first && errors.Is(err, context.Canceled) {
+				errs[i] = nil
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// runAction executes an action, and retries it according to its retry policy.
+// The error of the last attempt is returned when all retries are exhausted.
+// Actions running concurrently share their context, so the timeout of such an
+// action calls cancel instead of canceling a context of its own.
+func (fsm *CrossingTrafficLight) runAction(ctx context.Context, action CrossingAction, cancel func()) error {
+	clock := fsm.clock()
+
+	for attempt := 1; ; attempt++ {
+		fsm.logDebug("executing", "action", action.Name, "state", fsm.CurrentState, "attempt", attempt)
+
+		attempted := CrossingActionAttempt{State: fsm.CurrentState, Action: action.Name, Attempt: attempt}
+		attemptCtx := ctx
+
+		if fsm.Tracer != nil {
+			attemptCtx = fsm.Tracer.StartAction(ctx, attempted)
+			if cancel == nil {
+				// Actions running one at a time get their span from ActionContext
+				fsm.actionCtx = attemptCtx
+			}
+		}
+
+		start := clock.Now()
+		err := fsm.execute(attemptCtx, clock, action, cancel)
+		attempted.Duration = clock.Now().Sub(start)
+		attempted.Err = err
+
+		if fsm.Tracer != nil {
+			fsm.Tracer.EndAction(attemptCtx, attempted)
+			if cancel == nil {
+				fsm.actionCtx = ctx
+			}
+		}
+
+		fsm.mu.Lock()
+		for _, observer := range fsm.Observers {
+			observer.ActionAttempted(attempted)
+		}
+		fsm.mu.Unlock()
+
+		if err == nil {
+			return nil
+		}
+
+		if action.Retry == nil {
+			return err
+		}
+
+		if attempt > action.Retry.Max {
+			return fmt.Errorf("%s failed after %d attempts: %w", action.Name, attempt, err)
+		}
+
+		delay := action.Retry.Delay(attempt)
+		fsm.logWarn("action failed, retrying", "action", action.Name, "state", fsm.CurrentState,
+			"attempt", attempt, "delay", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-clock.After(delay):
+		}
+	}
+}
+
+// execute runs a single attempt of the action. When the action has a timeout,
+// its context is canceled when the timeout expires, and the error of the action
+// is returned as a DeadlineError.
+func (fsm *CrossingTrafficLight) execute(ctx context.Context, clock CrossingClock, action CrossingAction, cancel func()) error {
+	if action.Timeout <= 0 {
+		return action.Execute(action.Params...)
+	}
+
+	if cancel == nil {
+		attemptCtx, cancelAttempt := context.WithCancel(ctx)
+		defer cancelAttempt()
+
+		fsm.actionCtx = attemptCtx
+		defer func() { fsm.actionCtx = ctx }()
+
+		cancel = cancelAttempt
+	}
+
+	timer := crossingStartTimer(clock, action.Timeout, cancel)
+	err := action.Execute(action.Params...)
+
+	if timer.stop() && err != nil {
+		return &CrossingDeadlineError{State: fsm.CurrentState, Action: action.Name, Timeout: action.Timeout, Err: err}
+	}
+
+	return err
+}
+
+func (fsm *CrossingTrafficLight) clock() CrossingClock {
+	if fsm.Clock == nil {
+		return crossingRealClock{}
+	}
+
+	return fsm.Clock
+}
+
+// crossingTimer calls a function when a duration has passed on a clock, unless it is
+// stopped first.
+type crossingTimer struct {
+	mu      sync.Mutex
+	stopped chan struct{}
+	expired bool
+}
+
+func crossingStartTimer(clock CrossingClock, d time.Duration, expire func()) *crossingTimer {
+	t := &crossingTimer{stopped: make(chan struct{})}
+	after := clock.After(d)
+
+	go func() {
+		select {
+		case <-after:
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			select {
+			case <-t.stopped:
+			default:
+				t.expired = true
+
+				expire()
+			}
+		case <-t.stopped:
+		}
+	}()
+
+	return t
+}
+
+// stop stops the timer, and reports whether it had expired.
+func (t *crossingTimer) stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	close(t.stopped)
+
+	return t.expired
+}
+
+func (fsm *CrossingTrafficLight) runAllGuards(ctx context.Context, config CrossingStateConfig, result *CrossingStepResult) (CrossingStateName, error) {
+	for guardIndex, guard := range config.Guards {
+		passed := guard.Check(guard.Params...)
+		result.Guards = append(result.Guards, CrossingGuardResult{Name: guard.Name, Params: guard.Params, Passed: passed})
+
+		if passed {
+			if guard.Action != nil {
+				action := guard.Action
+				if err := fsm.runAction(ctx, *action, nil); err != nil {
+					fsm.logDebug("guarded action failed", "state", fsm.CurrentState,
+						"guard", guard.Name, "action", action.Name, "error", err)
+
+					result.Guard = guard.Name
+
+					return "", err
+				}
+			}
+
+			// Transition to the state mapped to this guard index
+			if nextState, exists := config.Transitions[guardIndex]; exists {
+				fsm.logDebug("guarded transition", "guard", guard.Name, "current", fsm.CurrentState, "next", nextState)
+
+				result.Guard = guard.Name
+
+				return nextState, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// Run handles the state transitions based on the current state, and returns
+// the outcome naming the final state the machine ended in.
+func (fsm *CrossingTrafficLight) Run() (CrossingOutcome, error) {
+	return fsm.RunContext(context.Background())
+}
+
+// wait waits for the delay of a time transition on the clock.
+func (fsm *CrossingTrafficLight) wait(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-fsm.clock().After(delay):
+		return nil
+	}
+}
+
+// logDebug, logWarn and logError write the logs of the state machine to the
+// logger of its context.
+func (fsm *CrossingTrafficLight) logDebug(msg string, args ...any) {
+	fsm.Context.Logger.Debug(msg, args...)
+}
+
+func (fsm *CrossingTrafficLight) logWarn(msg string, args ...any) {
+	fsm.Context.Logger.Warn(msg, args...)
+}
+
+func (fsm *CrossingTrafficLight) logError(err error, msg string, args ...any) {
+	fsm.Context.Logger.Error(msg, append(args, "error", err)...)
+}
@@ -0,0 +1,70 @@
+//go:build vectorsigma_coverage
+
+// This file is generated by VectorSigma (devel). DO NOT EDIT.
+package fsm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+func init() {
+	crossingCoverageObserver = &crossingCoverageRecorder{}
+}
+
+// crossingCoverageRecorder writes the steps taken by all state machines in the process
+// to a coverage file in the directory given by VECTORSIGMA_COVERDIR, or the
+// working directory if it is not set.
+type crossingCoverageRecorder struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	failed  bool
+}
+
+func (r *crossingCoverageRecorder) ActionAttempted(CrossingActionAttempt) {}
+
+func (r *crossingCoverageRecorder) Stepped(result CrossingStepResult, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.encoder == nil && !r.failed {
+		name := fmt.Sprintf("TrafficLight-%d.jsonl", os.Getpid())
+
+		file, err := os.Create(filepath.Join(os.Getenv("VECTORSIGMA_COVERDIR"), name))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to create coverage file:", err)
+
+			r.failed = true
+
+			return
+		}
+
+		r.encoder = json.NewEncoder(file)
+	}
+
+	if r.encoder == nil {
+		return
+	}
+
+	record := CrossingTraceRecord{
+		Parent:     result.Parent,
+		State:      result.PreviousState,
+		Guards:     result.Guards,
+		Guard:      result.Guard,
+		Next:       result.NextState,
+		OnError:    result.OnError,
+		ErrorMatch: result.ErrorMatch,
+		TimedOut:   result.TimedOut,
+		Time:       result.Time,
+		Done:       result.Done,
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	_ = r.encoder.Encode(record)
+}
@@ -0,0 +1,460 @@
+// This file is generated by VectorSigma (devel). DO NOT EDIT.
+package fsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// crossingDebugHistory is the number of steps kept for each instance.
+const crossingDebugHistory = 50
+
+// CrossingDebugHandler is an http.Handler showing live instances of the state machine,
+// with the state they are in, their recent steps and their extended state.
+// Mount it with its prefix stripped, e.g.
+//
+//	mux.Handle("/debug/fsm/", http.StripPrefix("/debug/fsm", handler))
+//
+// It serves
+//
+//	GET /               the registered instances
+//	GET /{name}         the state, recent steps and extended state of an instance
+//	GET /{name}/chart   the PlantUML chart with the current state highlighted
+//	GET /{name}/dot     the machine as a Graphviz graph with the current state highlighted
+//	GET /{name}/events  the steps of an instance as server-sent events
+type CrossingDebugHandler struct {
+	mu        sync.Mutex
+	instances map[string]*crossingDebugInstance
+	mux       *http.ServeMux
+}
+
+// NewCrossingDebugHandler returns a handler without instances.
+func NewCrossingDebugHandler() *CrossingDebugHandler {
+	h := &CrossingDebugHandler{instances: make(map[string]*crossingDebugInstance), mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /{$}", h.list)
+	h.mux.HandleFunc("GET /{name}", h.show)
+	h.mux.HandleFunc("GET /{name}/chart", h.chart)
+	h.mux.HandleFunc("GET /{name}/dot", h.dot)
+	h.mux.HandleFunc("GET /{name}/events", h.events)
+
+	return h
+}
+
+// Register adds the state machine to the handler under name, replacing the
+// instance registered under the same name. The handler observes the machine,
+// so it must be registered before the machine runs, and the replaced machine
+// must not be running.
+func (h *CrossingDebugHandler) Register(name string, fsm *CrossingTrafficLight) {
+	instance := &crossingDebugInstance{fsm: fsm, subscribers: make(map[chan json.RawMessage]struct{})}
+	instance.recorder = NewCrossingTraceRecorder(instance)
+	instance.snapshot()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if previous, ok := h.instances[name]; ok {
+		previous.close()
+	}
+
+	fsm.Observers = append(fsm.Observers, instance)
+	h.instances[name] = instance
+}
+
+// Unregister removes the instance registered under name, stops observing its
+// machine and ends its event streams. The machine must not be running.
+func (h *CrossingDebugHandler) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if instance, ok := h.instances[name]; ok {
+		instance.close()
+		delete(h.instances, name)
+	}
+}
+
+func (h *CrossingDebugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// crossingDebugSummary describes an instance in the list of instances.
+type crossingDebugSummary struct {
+	Name  string            `json:"name"`
+	State CrossingStateName `json:"state"`
+}
+
+// crossingDebugView describes an instance and its recent steps, as trace records.
+type crossingDebugView struct {
+	Name          string              `json:"name"`
+	State         CrossingStateName   `json:"state"`
+	Composites    []CrossingStateName `json:"composites,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	ExtendedState json.RawMessage     `json:"extendedState"`
+	Steps         []json.RawMessage   `json:"steps"`
+}
+
+func (h *CrossingDebugHandler) list(w http.ResponseWriter, _ *http.Request) {
+	h.mu.Lock()
+
+	summaries := make([]crossingDebugSummary, 0, len(h.instances))
+	for name, instance := range h.instances {
+		summaries = append(summaries, crossingDebugSummary{Name: name, State: instance.view().State})
+	}
+
+	h.mu.Unlock()
+
+	slices.SortFunc(summaries, func(a, b crossingDebugSummary) int { return strings.Compare(a.Name, b.Name) })
+
+	crossingWriteDebugJSON(w, summaries)
+}
+
+func (h *CrossingDebugHandler) show(w http.ResponseWriter, r *http.Request) {
+	if instance := h.instance(w, r); instance != nil {
+		view := instance.view()
+		view.Name = r.PathValue("name")
+
+		crossingWriteDebugJSON(w, view)
+	}
+}
+
+func (h *CrossingDebugHandler) chart(w http.ResponseWriter, r *http.Request) {
+	if instance := h.instance(w, r); instance != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = fmt.Fprint(w, crossingHighlightChart(CrossingChart, instance.describe()))
+	}
+}
+
+func (h *CrossingDebugHandler) dot(w http.ResponseWriter, r *http.Request) {
+	if instance := h.instance(w, r); instance != nil {
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		_, _ = fmt.Fprint(w, crossingDebugGraph(instance.describe()))
+	}
+}
+
+func (h *CrossingDebugHandler) events(w http.ResponseWriter, r *http.Request) {
+	instance := h.instance(w, r)
+	if instance == nil {
+		return
+	}
+
+	records, ok := instance.subscribe()
+	if !ok {
+		http.Error(w, "instance unregistered", http.StatusGone)
+
+		return
+	}
+	defer instance.unsubscribe(records)
+
+	controller := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case record, ok := <-records:
+			if !ok {
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", record); err != nil {
+				return
+			}
+
+			if err := controller.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// instance returns the instance named in the request, or writes a not found
+// response and returns nil.
+func (h *CrossingDebugHandler) instance(w http.ResponseWriter, r *http.Request) *crossingDebugInstance {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	instance, ok := h.instances[r.PathValue("name")]
+	if !ok {
+		http.Error(w, "instance not found", http.StatusNotFound)
+
+		return nil
+	}
+
+	return instance
+}
+
+func crossingWriteDebugJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+// crossingDebugInstance is an Observer keeping the recent steps of a state machine,
+// and a snapshot of it taken after each step. The snapshot is taken on the
+// goroutine running the machine, so the handler never reads the machine while
+// it steps.
+type crossingDebugInstance struct {
+	fsm         *CrossingTrafficLight
+	recorder    *CrossingTraceRecorder
+	mu          sync.Mutex
+	description CrossingDescription
+	extended    json.RawMessage
+	err         string
+	steps       []json.RawMessage
+	subscribers map[chan json.RawMessage]struct{}
+	closed      bool
+}
+
+func (i *crossingDebugInstance) ActionAttempted(attempt CrossingActionAttempt) {
+	i.recorder.ActionAttempted(attempt)
+}
+
+func (i *crossingDebugInstance) Stepped(result CrossingStepResult, err error) {
+	i.snapshot()
+	i.recorder.Stepped(result, err)
+}
+
+// Write receives the steps of the machine from the trace recorder, a line of
+// JSON at a time.
+func (i *crossingDebugInstance) Write(p []byte) (int, error) {
+	record := json.RawMessage(bytes.Clone(bytes.TrimSpace(p)))
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.steps = append(i.steps, record)
+	if len(i.steps) > crossingDebugHistory {
+		i.steps = slices.Delete(i.steps, 0, len(i.steps)-crossingDebugHistory)
+	}
+
+	for subscriber := range i.subscribers {
+		select {
+		case subscriber <- record:
+		default:
+			// Slow clients miss steps rather than holding up the machine
+		}
+	}
+
+	return len(p), nil
+}
+
+func (i *crossingDebugInstance) snapshot() {
+	description := i.fsm.Describe()
+
+	extended, err := json.Marshal(i.fsm.ExtendedState)
+	if err != nil {
+		extended, _ = json.Marshal(map[string]string{"marshalError": err.Error()})
+	}
+
+	var stateErr string
+	if i.fsm.ExtendedState.Error != nil {
+		stateErr = i.fsm.ExtendedState.Error.Error()
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.description = description
+	i.extended = extended
+	i.err = stateErr
+}
+
+func (i *crossingDebugInstance) describe() CrossingDescription {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.description
+}
+
+func (i *crossingDebugInstance) view() crossingDebugView {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return crossingDebugView{
+		State:         i.description.Current,
+		Composites:    i.description.Composites,
+		Error:         i.err,
+		ExtendedState: i.extended,
+		Steps:         slices.Clone(i.steps),
+	}
+}
+
+func (i *crossingDebugInstance) subscribe() (chan json.RawMessage, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		return nil, false
+	}
+
+	records := make(chan json.RawMessage, crossingDebugHistory)
+	i.subscribers[records] = struct{}{}
+
+	return records, true
+}
+
+func (i *crossingDebugInstance) unsubscribe(records chan json.RawMessage) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.subscribers[records]; ok {
+		delete(i.subscribers, records)
+		close(records)
+	}
+}
+
+// close stops observing the machine, and ends the event streams of the
+// instance.
+func (i *crossingDebugInstance) close() {
+	i.fsm.Observers = slices.DeleteFunc(i.fsm.Observers, func(observer CrossingObserver) bool { return observer == i })
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for records := range i.subscribers {
+		close(records)
+	}
+
+	i.subscribers = nil
+	i.closed = true
+}
+
+// crossingHighlightChart adds the current state, and the composite states the machine
+// is in, to the end of the PlantUML chart with a background color.
+func crossingHighlightChart(chart string, description CrossingDescription) string {
+	end := strings.LastIndex(chart, "@enduml")
+	if end < 0 {
+		return chart
+	}
+
+	var highlights strings.Builder
+
+	for _, composite := range description.Composites {
+		fmt.Fprintf(&highlights, "state %s #LightBlue\n", composite)
+	}
+
+	if description.Current != CrossingInitialState && description.Current != CrossingFinalState {
+		fmt.Fprintf(&highlights, "state %s #Gold\n", description.Current)
+	}
+
+	return chart[:end] + highlights.String() + chart[end:]
+}
+
+// crossingDebugGraph returns the machine as a Graphviz graph. Composite states are
+// clusters, and the IDs of the nodes are the paths of the states, as the
+// initial and final states are repeated in every composite state.
+func crossingDebugGraph(description CrossingDescription) string {
+	active := make(map[string]bool)
+	path := ""
+
+	for _, composite := range description.Composites {
+		path += string(composite)
+		active[path] = true
+		path += "/"
+	}
+
+	active[path+string(description.Current)] = true
+
+	var graph strings.Builder
+
+	fmt.Fprintf(&graph, "digraph %q {\n\tcompound=true\n\tnode [shape=box style=rounded]\n", description.Title)
+	crossingWriteDebugStates(&graph, description.States, "", "\t", active)
+	graph.WriteString("}\n")
+
+	return graph.String()
+}
+
+func crossingWriteDebugStates(graph *strings.Builder, states []CrossingStateDescription, prefix, indent string, active map[string]bool) {
+	composites := make(map[CrossingStateName]bool)
+
+	for _, state := range states {
+		if len(state.States) > 0 {
+			composites[state.Name] = true
+		}
+	}
+
+	// Edges to and from a composite state are drawn to the initial state
+	// inside it, and clipped at the border of its cluster
+	node := func(name CrossingStateName) string {
+		if composites[name] {
+			return prefix + string(name) + "/" + string(CrossingInitialState)
+		}
+
+		return prefix + string(name)
+	}
+
+	for _, state := range states {
+		id := prefix + string(state.Name)
+
+		switch {
+		case composites[state.Name]:
+			fmt.Fprintf(graph, "%ssubgraph %q {\n%s\tlabel=%q\n", indent, "cluster_"+id, indent, state.Name)
+
+			if active[id] {
+				fmt.Fprintf(graph, "%s\tstyle=filled\n%s\tfillcolor=lightblue\n", indent, indent)
+			}
+
+			crossingWriteDebugStates(graph, state.States, id+"/", indent+"\t", active)
+			fmt.Fprintf(graph, "%s}\n", indent)
+		case state.Name == CrossingInitialState:
+			fmt.Fprintf(graph, "%s%q [label=\"\" shape=point width=0.2]\n", indent, id)
+		case active[id]:
+			fmt.Fprintf(graph, "%s%q [label=%q style=\"rounded,filled\" fillcolor=gold]\n", indent, id, state.Name)
+		default:
+			fmt.Fprintf(graph, "%s%q [label=%q]\n", indent, id, state.Name)
+		}
+
+		for _, transition := range state.Transitions {
+			if transition.Target == CrossingFinalState {
+				fmt.Fprintf(graph, "%s%q [label=\"\" shape=doublecircle width=0.15 style=filled fillcolor=black]\n",
+					indent, node(CrossingFinalState))
+			}
+
+			attributes := []string{fmt.Sprintf("label=%q", crossingDebugLabel(transition))}
+
+			if composites[state.Name] {
+				attributes = append(attributes, fmt.Sprintf("ltail=%q", "cluster_"+id))
+			}
+
+			if composites[transition.Target] {
+				attributes = append(attributes, fmt.Sprintf("lhead=%q", "cluster_"+prefix+string(transition.Target)))
+			}
+
+			if strings.HasPrefix(transition.Event, "on error") {
+				attributes = append(attributes, "style=dashed")
+			}
+
+			fmt.Fprintf(graph, "%s%q -> %q [%s]\n", indent, node(state.Name), node(transition.Target),
+				strings.Join(attributes, " "))
+		}
+	}
+}
+
+// crossingDebugLabel returns the label of a transition in the graph.
+func crossingDebugLabel(transition CrossingTransitionDescription) string {
+	label := transition.Event
+	if transition.Guard != "" {
+		label = "[" + string(transition.Guard) + "]"
+	}
+
+	if transition.Action != nil {
+		label += " / " + string(transition.Action.Name)
+	}
+
+	return strings.TrimSpace(label)
+}
@@ -0,0 +1,36 @@
+// This file is generated by VectorSigma (devel). DO NOT EDIT.
+package fsm_test
+
+import (
+	"prefix/output/fsm"
+	"testing"
+)
+
+// TestCrossingTrafficLight_DebugHandler checks that the handler observes the
+// machine while it is registered, and stops when it is unregistered or
+// replaced.
+func TestCrossingTrafficLight_DebugHandler(t *testing.T) {
+	handler := fsm.NewCrossingDebugHandler()
+	first := fsm.NewCrossing()
+	second := fsm.NewCrossing()
+
+	handler.Register("machine", first)
+	handler.Register("machine", first)
+
+	if len(first.Observers) != 1 {
+		t.Fatalf("registered the machine twice: got %d observers, want 1", len(first.Observers))
+	}
+
+	handler.Register("machine", second)
+
+	if len(first.Observers) != 0 || len(second.Observers) != 1 {
+		t.Fatalf("replaced the machine: got %d and %d observers, want 0 and 1",
+			len(first.Observers), len(second.Observers))
+	}
+
+	handler.Unregister("machine")
+
+	if len(second.Observers) != 0 {
+		t.Fatalf("unregistered the machine: got %d observers, want 0", len(second.Observers))
+	}
+}
@@ -0,0 +1,233 @@
+// This file is generated by VectorSigma (devel). DO NOT EDIT.
+package fsm_test
+
+import (
+	"context"
+	"errors"
+	"prefix/output/fsm"
+	"slices"
+	"testing"
+	"time"
+)
+
+// crossingMaxPathLength stops a test when the machine does not follow the expected path.
+const crossingMaxPathLength = 1000
+
+// crossingErrPath fails the actions on the error transitions accepting all errors.
+var crossingErrPath = errors.New("path error")
+
+// TestCrossingTrafficLight_Paths runs the paths from the InitialState to the
+// FinalState of the chart, with the actions replaced by no-ops and the guards
+// replaced by stubs taking the transitions of the path. The first action of a
+// state fails with an error matching the error transition of the path, and the
+// delays of the time transitions pass at once.
+func TestCrossingTrafficLight_Paths(t *testing.T) {
+	tests := []struct {
+		name    string
+		choices map[fsm.CrossingStateName][]int   // The transition taken at each visit of a state with guards
+		errors  map[fsm.CrossingStateName][]error // The error of the actions at each visit of a state with error transitions
+		want    []fsm.CrossingStateName
+	}{
+		{
+			name: "Red -> FinalState",
+			choices: map[fsm.CrossingStateName][]int{
+				fsm.CrossingRed: {0},
+			},
+			want: []fsm.CrossingStateName{
+				fsm.CrossingInitialState,
+				fsm.CrossingRed,
+				fsm.CrossingFinalState,
+			},
+		},
+		{
+			name: "Red -> Yellow -> FinalState",
+			choices: map[fsm.CrossingStateName][]int{
+				fsm.CrossingRed:    {1},
+				fsm.CrossingYellow: {0},
+			},
+			want: []fsm.CrossingStateName{
+				fsm.CrossingInitialState,
+				fsm.CrossingRed,
+				fsm.CrossingYellow,
+				fsm.CrossingFinalState,
+			},
+		},
+		{
+			name: "Red -> Yellow -> Green -> FinalState",
+			choices: map[fsm.CrossingStateName][]int{
+				fsm.CrossingGreen:  {0},
+				fsm.CrossingRed:    {1},
+				fsm.CrossingYellow: {1},
+			},
+			want: []fsm.CrossingStateName{
+				fsm.CrossingInitialState,
+				fsm.CrossingRed,
+				fsm.CrossingYellow,
+				fsm.CrossingGreen,
+				fsm.CrossingFinalState,
+			},
+		},
+		{
+			name: "Red -> Yellow -> Green -> FlashingYellow -> FinalState",
+			choices: map[fsm.CrossingStateName][]int{
+				fsm.CrossingFlashingYellow: {0},
+				fsm.CrossingGreen:          {1},
+				fsm.CrossingRed:            {1},
+				fsm.CrossingYellow:         {1},
+			},
+			want: []fsm.CrossingStateName{
+				fsm.CrossingInitialState,
+				fsm.CrossingRed,
+				fsm.CrossingYellow,
+				fsm.CrossingGreen,
+				fsm.CrossingFlashingYellow,
+				fsm.CrossingFinalState,
+			},
+		},
+		{
+			name: "Red -> Yellow -> Green -> FlashingYellow -> Red -> FinalState",
+			choices: map[fsm.CrossingStateName][]int{
+				fsm.CrossingFlashingYellow: {1},
+				fsm.CrossingGreen:          {1},
+				fsm.CrossingRed:            {1, 0},
+				fsm.CrossingYellow:         {1},
+			},
+			want: []fsm.CrossingStateName{
+				fsm.CrossingInitialState,
+				fsm.CrossingRed,
+				fsm.CrossingYellow,
+				fsm.CrossingGreen,
+				fsm.CrossingFlashingYellow,
+				fsm.CrossingRed,
+				fsm.CrossingFinalState,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := fsm.NewCrossing(fsm.WithCrossingClock(crossingPathClock{}))
+			crossingStubStateConfigs(machine.StateConfigs, &crossingPathStub{
+				choices:      tt.choices,
+				errors:       tt.errors,
+				guardRounds:  make(map[fsm.CrossingStateName]int),
+				actionRounds: make(map[fsm.CrossingStateName]int),
+			})
+
+			got := []fsm.CrossingStateName{machine.CurrentState}
+
+			for len(got) < crossingMaxPathLength {
+				result, err := machine.Step(context.Background())
+				if err != nil {
+					t.Fatalf("step failed after %v: %v", got, err)
+				}
+
+				got = append(got, result.NextState)
+
+				if result.Done {
+					break
+				}
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("visited %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// crossingPathStub holds the transitions of a path, and counts the visits of each
+// state running its actions and evaluating its guards.
+type crossingPathStub struct {
+	choices      map[fsm.CrossingStateName][]int
+	errors       map[fsm.CrossingStateName][]error
+	guardRounds  map[fsm.CrossingStateName]int
+	actionRounds map[fsm.CrossingStateName]int
+}
+
+// crossingStubStateConfigs replaces the actions with no-ops, failing the first action
+// of a state on the error transitions of the path, and the guards with stubs
+// taking the transitions of the path. The timeouts are removed, as the delays
+// pass at once on the clock of the paths.
+func crossingStubStateConfigs(configs map[fsm.CrossingStateName]fsm.CrossingStateConfig, stub *crossingPathStub) {
+	for state, config := range configs {
+		for i := range config.Actions {
+			config.Actions[i].Execute = crossingNoAction
+			config.Actions[i].Retry = nil
+			config.Actions[i].Timeout = 0
+		}
+
+		if len(config.Actions) > 0 {
+			config.Actions[0].Execute = stub.action(state)
+		}
+
+		for i := range config.Guards {
+			config.Guards[i].Check = stub.guard(state, i)
+
+			if config.Guards[i].Action != nil {
+				config.Guards[i].Action.Execute = crossingNoAction
+				config.Guards[i].Action.Retry = nil
+			}
+		}
+
+		if config.Compensation != nil {
+			config.Compensation.Execute = crossingNoAction
+		}
+
+		config.Timeout = 0
+		configs[state] = config
+
+		crossingStubStateConfigs(config.Composite.StateConfigs, stub)
+	}
+}
+
+// guard returns a guard passing when the transition chosen for the current
+// round of guard evaluations of the state is the transition of the guard.
+func (s *crossingPathStub) guard(state fsm.CrossingStateName, index int) func(...string) bool {
+	return func(...string) bool {
+		// The guards are always evaluated from the first one
+		if index == 0 {
+			s.guardRounds[state]++
+		}
+
+		round := s.guardRounds[state] - 1
+		if round >= len(s.choices[state]) {
+			return false
+		}
+
+		return s.choices[state][round] == index
+	}
+}
+
+// action returns the first action of the state, failing with the error chosen
+// for the current round of action executions of the state.
+func (s *crossingPathStub) action(state fsm.CrossingStateName) func(...string) error {
+	return func(...string) error {
+		s.actionRounds[state]++
+
+		round := s.actionRounds[state] - 1
+		if round >= len(s.errors[state]) {
+			return nil
+		}
+
+		return s.errors[state][round]
+	}
+}
+
+func crossingNoAction(...string) error {
+	return nil
+}
+
+// crossingPathClock is a clock showing midnight, on which all delays pass at once.
+type crossingPathClock struct{}
+
+func (crossingPathClock) Now() time.Time {
+	return time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (c crossingPathClock) After(time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+
+	return ch
+}
@@ -0,0 +1,281 @@
+// This file is generated by VectorSigma (devel). DO NOT EDIT.
+package fsm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCrossingSupervisorClosed is returned when sending to a supervisor that is shut down.
+var ErrCrossingSupervisorClosed = errors.New("supervisor closed")
+
+// CrossingMessageHandler handles a message with the instance of the state machine its
+// key belongs to, typically by updating the extended state with the message and
+// running the machine. The messages of a key are handled one at a time, in the
+// order they were sent. The context is canceled when the supervisor stops
+// without waiting for the handlers.
+type CrossingMessageHandler[K comparable, M any] func(ctx context.Context, key K, fsm *CrossingTrafficLight, msg M)
+
+// CrossingSupervisorOption configures the supervisor created by NewSupervisor.
+type CrossingSupervisorOption func(*crossingSupervisorConfig)
+
+type crossingSupervisorConfig struct {
+	mailboxSize int
+	idleTimeout time.Duration
+	maxActive   int
+	options     []CrossingOption
+}
+
+// WithCrossingMailboxSize sets the number of messages buffered for each instance, 16 by
+// default. Send blocks while the mailbox of the instance is full.
+func WithCrossingMailboxSize(size int) CrossingSupervisorOption {
+	return func(c *crossingSupervisorConfig) {
+		c.mailboxSize = size
+	}
+}
+
+// WithCrossingIdleTimeout stops the instances that have not received a message for the
+// duration. The next message for the key starts a new instance, with a new
+// extended state. 0 keeps the instances until the supervisor stops. The
+// duration is measured on the Clock of the instances.
+func WithCrossingIdleTimeout(timeout time.Duration) CrossingSupervisorOption {
+	return func(c *crossingSupervisorConfig) {
+		c.idleTimeout = timeout
+	}
+}
+
+// WithCrossingMaxActive limits the number of instances handling a message at the same
+// time, 0 means no limit.
+func WithCrossingMaxActive(maxActive int) CrossingSupervisorOption {
+	return func(c *crossingSupervisorConfig) {
+		c.maxActive = maxActive
+	}
+}
+
+// WithCrossingInstanceOptions sets the options the instances are created with.
+func WithCrossingInstanceOptions(opts ...CrossingOption) CrossingSupervisorOption {
+	return func(c *crossingSupervisorConfig) {
+		c.options = opts
+	}
+}
+
+// CrossingSupervisor runs an instance of the state machine for each key, like a device
+// or a customer. Each instance has a mailbox, and handles its messages one at a
+// time on a goroutine of its own.
+type CrossingSupervisor[K comparable, M any] struct {
+	handle    CrossingMessageHandler[K, M]
+	config    crossingSupervisorConfig
+	ctx       context.Context // Canceled when the supervisor stops without waiting for the handlers
+	cancel    context.CancelFunc
+	active    chan struct{} // Holds a token for each instance handling a message, nil without limit
+	stopping  chan struct{} // Closed when the supervisor is shut down
+	instances sync.WaitGroup
+
+	mu        sync.Mutex // Guards the mailboxes, and the senders of each
+	mailboxes map[K]*crossingMailbox[M]
+	closed    bool
+}
+
+// crossingMailbox holds the messages of an instance.
+type crossingMailbox[M any] struct {
+	messages chan M
+	senders  int           // The number of Send calls delivering to the mailbox
+	left     chan struct{} // Signaled when the last of the senders leaves
+}
+
+// NewCrossingSupervisor returns a supervisor handling the messages with handle. The
+// supervisor stops when ctx is done, without waiting for the messages in the
+// mailboxes, or when it is shut down.
+func NewCrossingSupervisor[K comparable, M any](ctx context.Context, handle CrossingMessageHandler[K, M],
+	opts ...CrossingSupervisorOption,
+) *CrossingSupervisor[K, M] {
+	s := &CrossingSupervisor[K, M]{
+		handle:    handle,
+		config:    crossingSupervisorConfig{mailboxSize: 16},
+		stopping:  make(chan struct{}),
+		mailboxes: make(map[K]*crossingMailbox[M]),
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, opt := range opts {
+		opt(&s.config)
+	}
+
+	if s.config.maxActive > 0 {
+		s.active = make(chan struct{}, s.config.maxActive)
+	}
+
+	return s
+}
+
+// Send delivers the message to the mailbox of the instance of key, starting the
+// instance if it is not running. It blocks while the mailbox is full, until ctx
+// is done.
+func (s *CrossingSupervisor[K, M]) Send(ctx context.Context, key K, msg M) error {
+	s.mu.Lock()
+
+	if s.closed || s.ctx.Err() != nil {
+		s.mu.Unlock()
+
+		return ErrCrossingSupervisorClosed
+	}
+
+	box, ok := s.mailboxes[key]
+	if !ok {
+		box = &crossingMailbox[M]{messages: make(chan M, s.config.mailboxSize), left: make(chan struct{}, 1)}
+		s.mailboxes[key] = box
+
+		s.instances.Add(1)
+
+		go s.supervise(key, box)
+	}
+
+	box.senders++
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if box.senders--; box.senders == 0 {
+			select {
+			case box.left <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	select {
+	case box.messages <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return ErrCrossingSupervisorClosed
+	}
+}
+
+// Len returns the number of running instances.
+func (s *CrossingSupervisor[K, M]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.mailboxes)
+}
+
+// Shutdown stops the supervisor from accepting messages, and waits for the
+// instances to handle the messages in their mailboxes. If ctx is done first,
+// the context of the handlers is canceled, and the error of ctx is returned
+// without waiting for the handlers to return.
+func (s *CrossingSupervisor[K, M]) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.stopping)
+	}
+
+	s.mu.Unlock()
+
+	stopped := make(chan struct{})
+
+	go func() {
+		s.instances.Wait()
+		close(stopped)
+	}()
+
+	defer s.cancel()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// supervise runs the instance of key, handling the messages of its mailbox
+// until it is idle or the supervisor stops. The idle timeout is measured on the
+// Clock of the instance.
+func (s *CrossingSupervisor[K, M]) supervise(key K, box *crossingMailbox[M]) {
+	defer s.instances.Done()
+
+	fsm := NewCrossing(s.config.options...)
+	clock := fsm.Clock
+	stopping := s.stopping
+	last := clock.Now()
+
+	// The idle timer is not restarted by the messages. When it fires, it is
+	// started again for what is left of the timeout since the last message.
+	var idle <-chan time.Time
+	if s.config.idleTimeout > 0 {
+		idle = clock.After(s.config.idleTimeout)
+	}
+
+	for {
+		select {
+		case msg := <-box.messages:
+			s.deliver(key, fsm, msg)
+
+			last = clock.Now()
+		case <-idle:
+			idle = nil
+		case <-stopping:
+			stopping = nil
+		case <-box.left:
+		case <-s.ctx.Done():
+			return
+		}
+
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		if stopping != nil {
+			if idle != nil || s.config.idleTimeout == 0 {
+				continue
+			}
+
+			if remaining := s.config.idleTimeout - clock.Now().Sub(last); remaining > 0 {
+				idle = clock.After(remaining)
+
+				continue
+			}
+		}
+
+		// The instance stops when no message is on its way to the mailbox.
+		// Otherwise the message, or the sender giving up, wakes it again.
+		s.mu.Lock()
+
+		if len(box.messages) == 0 && box.senders == 0 {
+			delete(s.mailboxes, key)
+			s.mu.Unlock()
+
+			return
+		}
+
+		s.mu.Unlock()
+	}
+}
+
+// deliver handles the message, waiting for a token while the number of active
+// instances is limited.
+func (s *CrossingSupervisor[K, M]) deliver(key K, fsm *CrossingTrafficLight, msg M) {
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	if s.active != nil {
+		select {
+		case s.active <- struct{}{}:
+		case <-s.ctx.Done():
+			return
+		}
+
+		defer func() { <-s.active }()
+	}
+
+	s.handle(s.ctx, key, fsm, msg)
+}
@@ -0,0 +1,28 @@
+// This file is generated by VectorSigma (devel). DO NOT EDIT.
+package fsm_test
+
+import (
+	"prefix/output/fsm"
+	"testing"
+)
+
+func TestCrossingTrafficLight_Run(t *testing.T) {
+	type fields struct {
+		Context       *fsm.CrossingContext
+		CurrentState  fsm.CrossingStateName
+		ExtendedState *fsm.CrossingExtendedState
+		StateConfigs  map[fsm.CrossingStateName]fsm.CrossingStateConfig
+	}
+	tests := []struct {
+		name   string
+		fields fields
+	}{
+		{name: "Run the machine"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := fsm.NewCrossing()
+			fsm.Run()
+		})
+	}
+}
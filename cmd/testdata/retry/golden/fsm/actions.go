@@ -0,0 +1,13 @@
+package fsm
+
+// +vectorsigma:action:Fetch
+func (fsm *Fetcher) FetchAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:Store
+func (fsm *Fetcher) StoreAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
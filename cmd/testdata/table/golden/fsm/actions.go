@@ -0,0 +1,7 @@
+package fsm
+
+// +vectorsigma:action:SwitchIn
+func (fsm *TrafficLight) SwitchInAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
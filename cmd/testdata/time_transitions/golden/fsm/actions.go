@@ -0,0 +1,13 @@
+package fsm
+
+// +vectorsigma:action:Poll
+func (fsm *Reporter) PollAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:Report
+func (fsm *Reporter) ReportAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
@@ -0,0 +1,7 @@
+package fsm
+
+// +vectorsigma:guard:IsDone
+func (fsm *Reporter) IsDoneGuard(_ ...string) bool {
+	// TODO: Implement me!
+	return false
+}
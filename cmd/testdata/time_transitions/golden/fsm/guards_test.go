@@ -0,0 +1,44 @@
+package fsm_test
+
+import (
+	"testing"
+	"time_transitions/output/fsm"
+)
+
+// +vectorsigma:guard:IsDone
+func TestReporter_IsDoneGuard(t *testing.T) {
+	type fields struct {
+		context       *fsm.Context
+		currentState  fsm.StateName
+		stateConfigs  map[fsm.StateName]fsm.StateConfig
+		ExtendedState *fsm.ExtendedState
+	}
+	type args struct {
+		params []string
+	}
+
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   bool
+	}{
+		// TODO: Add test cases.
+	}
+
+	t.Parallel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fsm := &fsm.Reporter{
+				Context:       tt.fields.context,
+				CurrentState:  tt.fields.currentState,
+				StateConfigs:  tt.fields.stateConfigs,
+				ExtendedState: tt.fields.ExtendedState,
+			}
+			if got := fsm.IsDoneGuard(tt.args.params...); got != tt.want {
+				t.Errorf("Reporter.IsDoneGuard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
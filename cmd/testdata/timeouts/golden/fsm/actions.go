@@ -0,0 +1,25 @@
+package fsm
+
+// +vectorsigma:action:Fetch
+func (fsm *Poller) FetchAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:Poll
+func (fsm *Poller) PollAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:Report
+func (fsm *Poller) ReportAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
+
+// +vectorsigma:action:RollOut
+func (fsm *Poller) RollOutAction(_ ...string) error {
+	// TODO: Implement me!
+	return nil
+}
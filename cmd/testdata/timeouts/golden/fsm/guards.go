@@ -0,0 +1,13 @@
+package fsm
+
+// +vectorsigma:guard:IsReady
+func (fsm *Poller) IsReadyGuard(_ ...string) bool {
+	// TODO: Implement me!
+	return false
+}
+
+// +vectorsigma:guard:IsRolledOut
+func (fsm *Poller) IsRolledOutGuard(_ ...string) bool {
+	// TODO: Implement me!
+	return false
+}
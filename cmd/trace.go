@@ -0,0 +1,116 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mhersson/vectorsigma/pkgs/trace"
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+	"github.com/spf13/cobra"
+)
+
+const (
+	plantUMLFlag = "plantuml"
+	traceFlag    = "trace"
+)
+
+var TraceCmd = &cobra.Command{
+	Use:   "trace",
+	Short: "Work with execution traces",
+	Long: `Work with the execution traces recorded by generated state machines
+using the TraceRecorder.`,
+}
+
+var TraceReplayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay an execution trace",
+	Long: `Replay an execution trace against the UML diagram it was recorded with.
+
+Prints the path taken through the state machine, or the UML diagram with the
+visited states and transitions highlighted.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		input, _ := cmd.Flags().GetString(inputFlag)
+		traceFile, _ := cmd.Flags().GetString(traceFlag)
+		plantUML, _ := cmd.Flags().GetBool(plantUMLFlag)
+
+		return replayTrace(cmd.OutOrStdout(), input, traceFile, plantUML)
+	},
+}
+
+func init() {
+	TraceCmd.AddCommand(TraceReplayCmd)
+	TraceReplayCmd.Flags().StringP(inputFlag, "i", "", "The UML input file")
+	_ = TraceReplayCmd.MarkFlagRequired(inputFlag)
+	TraceReplayCmd.Flags().StringP(traceFlag, "t", "", "The trace file")
+	_ = TraceReplayCmd.MarkFlagRequired(traceFlag)
+	TraceReplayCmd.Flags().Bool(plantUMLFlag, false, "print the UML diagram with the trace highlighted")
+}
+
+func replayTrace(w io.Writer, input, traceFile string, plantUML bool) error {
+	chart, err := readChart(input)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(traceFile)
+	if err != nil {
+		return fmt.Errorf("failed to open trace file: %w", err)
+	}
+	defer file.Close()
+
+	records, err := trace.Read(file)
+	if err != nil {
+		return err
+	}
+
+	fsm := uml.Parse(chart)
+
+	if plantUML {
+		_, err = fmt.Fprintln(w, trace.Highlight(chart, fsm, records))
+
+		return err
+	}
+
+	return trace.Print(w, fsm, records)
+}
+
+// readChart reads the UML diagram from input, which can also be a markdown
+// file containing a plantuml code block, with the included charts inlined.
+func readChart(input string) (string, error) {
+	content, err := os.ReadFile(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	chart := string(content)
+	if filepath.Ext(input) == ".md" {
+		if chart, err = uml.ExtractFromMarkdown(chart); err != nil {
+			return "", err
+		}
+	}
+
+	return uml.ResolveIncludes(chart, input, os.ReadFile)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
 
@@ -15,6 +16,10 @@ import (
 	"github.com/spf13/afero"
 )
 
+// validPrefix matches the prefixes that keep the generated identifiers valid
+// and exported.
+var validPrefix = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
 // +vectorsigma:action:Initialize
 func (fsm *VectorSigma) InitializeAction(_ ...string) error {
 	dir, err := os.Getwd()
@@ -39,6 +44,22 @@ func (fsm *VectorSigma) InitializeAction(_ ...string) error {
 		}
 	}
 
+	if fsm.ExtendedState.Prefix != "" && !validPrefix.MatchString(fsm.ExtendedState.Prefix) {
+		return errors.New("invalid prefix - prefix must be an exported Go identifier, e.g. Session")
+	}
+
+	if fsm.ExtendedState.Runtime && fsm.ExtendedState.Table {
+		return errors.New("invalid engine - runtime and table can not be used together")
+	}
+
+	if fsm.ExtendedState.Naming == "" {
+		fsm.ExtendedState.Naming = generator.NamingPlain
+	}
+
+	if fsm.ExtendedState.Naming != generator.NamingPlain && fsm.ExtendedState.Naming != generator.NamingQualified {
+		return fmt.Errorf("invalid naming - naming must be %s or %s", generator.NamingPlain, generator.NamingQualified)
+	}
+
 	if fsm.ExtendedState.Group == "" {
 		fsm.ExtendedState.Group = fsm.ExtendedState.APIKind
 	}
@@ -52,6 +73,16 @@ func (fsm *VectorSigma) InitializeAction(_ ...string) error {
 		Module:       fsm.ExtendedState.Module,
 		Package:      fsm.ExtendedState.Package,
 		Init:         fsm.ExtendedState.Init,
+		Operator:     fsm.ExtendedState.Operator,
+		Interfaces:   fsm.ExtendedState.Interfaces,
+		Runtime:      fsm.ExtendedState.Runtime,
+		Table:        fsm.ExtendedState.Table,
+		DebugHandler: fsm.ExtendedState.DebugHandler,
+		Metrics:      fsm.ExtendedState.Metrics,
+		OTel:         fsm.ExtendedState.OTel,
+		Supervisor:   fsm.ExtendedState.Supervisor,
+		Prefix:       fsm.ExtendedState.Prefix,
+		Naming:       fsm.ExtendedState.Naming,
 		RelativePath: relativePath,
 		Version:      fsm.ExtendedState.VectorSigmaVersion,
 	}
@@ -75,33 +106,37 @@ func (fsm *VectorSigma) LoadInputAction(_ ...string) error {
 
 // +vectorsigma:action:ExtractUML
 func (fsm *VectorSigma) ExtractUMLAction(_ ...string) error {
-	const startDelimiter = "```plantuml"
-
-	const endDelimiter = "```"
-
-	lenStartDelimiter := len(startDelimiter)
-	markdown := fsm.ExtendedState.InputData
-
-	startIndex := strings.Index(markdown, startDelimiter)
-	if startIndex == -1 {
-		return errors.New("no plantuml found in markdown")
-	}
-
-	endIndex := strings.Index(markdown[startIndex+lenStartDelimiter:], endDelimiter)
-	if endIndex == -1 {
-		return errors.New("missing end of plantuml code block in markdown")
+	data, err := uml.ExtractFromMarkdown(fsm.ExtendedState.InputData)
+	if err != nil {
+		return err
 	}
 
-	fsm.ExtendedState.InputData = markdown[startIndex+lenStartDelimiter : endIndex+startIndex+lenStartDelimiter]
+	fsm.ExtendedState.InputData = data
 
 	return nil
 }
 
 // +vectorsigma:action:ParseUML
 func (fsm *VectorSigma) ParseUMLAction(_ ...string) error {
-	fsm.Context.Generator.FSM = uml.Parse(fsm.ExtendedState.InputData)
+	data, err := uml.ResolveIncludes(fsm.ExtendedState.InputData, fsm.ExtendedState.Input, func(path string) ([]byte, error) {
+		return afero.ReadFile(fsm.Context.Generator.FS, path)
+	})
+	if err != nil {
+		return err
+	}
 
-	return nil
+	fsm.Context.Generator.FSM = uml.Parse(data)
+	fsm.Context.Generator.Chart = strings.TrimSpace(data) + "\n"
+
+	if err := fsm.Context.Generator.Validate(); err != nil {
+		if errors.Is(err, generator.ErrInvalidNames) && fsm.ExtendedState.Naming == generator.NamingPlain {
+			return fmt.Errorf("%w - rename them, or use the %s naming", err, generator.NamingQualified)
+		}
+
+		return err
+	}
+
+	return fsm.Context.Generator.Check()
 }
 
 // +vectorsigma:action:GenerateStateMachine
@@ -113,9 +148,35 @@ func (fsm *VectorSigma) GenerateStateMachineAction(_ ...string) error {
 		"guards_test.go",
 		"statemachine.go",
 		"statemachine_test.go",
+		"statemachine_paths_test.go",
+		"statemachine_coverage.go",
 		"extendedstate.go",
 	}
 
+	if len(fsm.Context.Generator.FSM.ErrorNames) > 0 {
+		files = append(files, "errors.go")
+	}
+
+	if fsm.ExtendedState.Interfaces {
+		files = append(files, "statemachine_fakes.go")
+	}
+
+	if fsm.ExtendedState.DebugHandler {
+		files = append(files, "statemachine_debug.go", "statemachine_debug_test.go")
+	}
+
+	if fsm.ExtendedState.Metrics {
+		files = append(files, "statemachine_metrics.go")
+	}
+
+	if fsm.ExtendedState.OTel {
+		files = append(files, "statemachine_otel.go")
+	}
+
+	if fsm.ExtendedState.Supervisor {
+		files = append(files, "statemachine_supervisor.go")
+	}
+
 	templatePath := "templates/application"
 	if fsm.ExtendedState.Operator {
 		templatePath = "templates/operator"
@@ -123,18 +184,64 @@ func (fsm *VectorSigma) GenerateStateMachineAction(_ ...string) error {
 		files = append(files, "statemachine_integration_test.go", "common_test.go")
 	}
 
+	generated := make(map[string][]byte)
+	shared := make(map[string][]byte)
+
 	for _, filename := range files {
-		code, err := fsm.Context.Generator.ExecuteTemplate(filepath.Join(templatePath, filename+".tmpl"))
+		tmpl := filename
+		if filename == "statemachine.go" && fsm.ExtendedState.Runtime {
+			// The engine is imported from the runtime package instead of generated
+			tmpl = "statemachine_runtime.go"
+		}
+
+		if filename == "statemachine.go" && fsm.ExtendedState.Table {
+			tmpl = "statemachine_table.go"
+		}
+
+		if filename == "statemachine_paths_test.go" && fsm.ExtendedState.Table {
+			// The table is shared by the machines, so it is stubbed from inside the package
+			tmpl = "statemachine_paths_table_test.go"
+		}
+
+		code, err := fsm.Context.Generator.ExecuteTemplate(filepath.Join(templatePath, tmpl+".tmpl"))
 		if err != nil {
 			return fmt.Errorf("code generation failed: %w", err)
 		}
 
+		if fsm.ExtendedState.Operator && (filename == "statemachine_test.go" || filename == "common_test.go") {
+			// The test environment of an operator is shared by all state machines in the package
+			shared[filename] = code
+		} else {
+			generated[filename] = code
+		}
+	}
+
+	if err := fsm.Context.Generator.ApplyPrefix(generated); err != nil {
+		return fmt.Errorf("code generation failed: %w", err)
+	}
+
+	for filename, code := range shared {
+		generated[filename] = code
+	}
+
+	for filename, code := range generated {
+		name := filename
+		if _, ok := shared[filename]; !ok {
+			name = fsm.filePrefix() + filename
+		}
+
 		if strings.HasPrefix(filename, "statemachine") && filename != "statemachine_integration_test.go" {
 			// Make it very clear that this is a generated file that should not be modified
-			filename = "zz_generated_" + filename
+			name = "zz_generated_" + name
 		}
 
-		fsm.ExtendedState.GeneratedFiles[filepath.Join(fsm.ExtendedState.Package, filename)] = GeneratedFile{Content: code, IncrementalChange: false}
+		dir := fsm.ExtendedState.Package
+		if filename == "statemachine_fakes.go" {
+			// The fakes are a package of their own, to be imported by any test
+			dir = filepath.Join(dir, "fakes")
+		}
+
+		fsm.ExtendedState.GeneratedFiles[filepath.Join(dir, name)] = GeneratedFile{Content: code, IncrementalChange: false}
 	}
 
 	return nil
@@ -205,16 +312,16 @@ func (fsm *VectorSigma) FilterGeneratedFilesAction(_ ...string) error {
 	// exists so does probably extendtendstate.go too, but anyways..
 	files := []string{"extendedstate.go", "statemachine_integration_test.go", "common_test.go", "main.go", "go.mod"}
 
-	actionsAndguards := []string{"actions.go", "actions_test.go", "guards.go", "guards_test.go"}
+	actionsAndguards := []string{"actions.go", "actions_test.go", "guards.go", "guards_test.go", "errors.go"}
 
 	for filename, gf := range fsm.ExtendedState.GeneratedFiles {
 		if exists, _ := fsm.Context.Generator.Exists(filepath.Join(fsm.ExtendedState.Output, filename)); exists {
-			if slices.Contains(files, filepath.Base(filename)) {
+			if slices.Contains(files, fsm.baseName(filename)) {
 				// extendedstate.go and statemachine_integration_test.go should never be overwritten
 				delete(fsm.ExtendedState.GeneratedFiles, filename)
 			}
 
-			if slices.Contains(actionsAndguards, filepath.Base(filename)) && !gf.IncrementalChange {
+			if slices.Contains(actionsAndguards, fsm.baseName(filename)) && !gf.IncrementalChange {
 				// don't write actions and guards unless they have changed
 				delete(fsm.ExtendedState.GeneratedFiles, filename)
 			}
@@ -226,10 +333,10 @@ func (fsm *VectorSigma) FilterGeneratedFilesAction(_ ...string) error {
 
 // +vectorsigma:action:MakeIncrementalUpdates
 func (fsm *VectorSigma) MakeIncrementalUpdatesAction(_ ...string) error {
-	files := []string{"actions.go", "actions_test.go", "guards.go", "guards_test.go"}
+	files := []string{"actions.go", "actions_test.go", "guards.go", "guards_test.go", "errors.go"}
 
 	for f, c := range fsm.ExtendedState.GeneratedFiles {
-		if slices.Contains(files, filepath.Base(f)) {
+		if slices.Contains(files, fsm.baseName(f)) {
 			fullpath := filepath.Join(fsm.ExtendedState.Output, f)
 			if exists, err := fsm.Context.Generator.Exists(fullpath); exists && err == nil {
 				fsm.Context.Logger.Debug("Running incremental update", "file", f)
@@ -275,3 +382,20 @@ func (fsm *VectorSigma) FormatCodeAction(_ ...string) error {
 
 	return nil
 }
+
+// filePrefix returns the prefix of the files generated for the state machine,
+// e.g. session_ for the prefix Session.
+func (fsm *VectorSigma) filePrefix() string {
+	if fsm.ExtendedState.Prefix == "" {
+		return ""
+	}
+
+	return strings.ToLower(fsm.ExtendedState.Prefix) + "_"
+}
+
+// baseName returns the name of a generated file without the directory and the
+// prefix of the state machine, so the files are handled the same way
+// regardless of the prefix.
+func (fsm *VectorSigma) baseName(filename string) string {
+	return strings.TrimPrefix(filepath.Base(filename), fsm.filePrefix())
+}
@@ -42,6 +42,34 @@ func TestVectorSigma_InitializeAction(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "OK with prefix", fields: fields{
+				context:       &statemachine.Context{},
+				ExtendedState: &statemachine.ExtendedState{Prefix: "Session"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid prefix", fields: fields{
+				context:       &statemachine.Context{},
+				ExtendedState: &statemachine.ExtendedState{Prefix: "session"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid naming", fields: fields{
+				context:       &statemachine.Context{},
+				ExtendedState: &statemachine.ExtendedState{Naming: "camel"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Runtime and table", fields: fields{
+				context:       &statemachine.Context{},
+				ExtendedState: &statemachine.ExtendedState{Runtime: true, Table: true},
+			},
+			wantErr: true,
+		},
 	}
 
 	t.Parallel()
@@ -63,6 +91,7 @@ func TestVectorSigma_InitializeAction(t *testing.T) {
 				assert.NotEmpty(t, fsm.ExtendedState.Module)
 				assert.Equal(t, fsm.Context.Generator.Module, fsm.ExtendedState.Module)
 				assert.Equal(t, fsm.Context.Generator.Package, fsm.ExtendedState.Package)
+				assert.Equal(t, fsm.Context.Generator.Prefix, fsm.ExtendedState.Prefix)
 			}
 		})
 	}
@@ -225,6 +254,50 @@ func TestVectorSigma_ParseUMLAction(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Colliding names",
+			fields: fields{
+				context: &statemachine.Context{Generator: &generator.Generator{}},
+				ExtendedState: &statemachine.ExtendedState{
+					InputData: "@startuml\ntitle test title\n[*] --> Loading\nLoading: do / Loading\nLoading --> [*]\n@enduml",
+					Naming:    generator.NamingPlain,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Qualified names",
+			fields: fields{
+				context: &statemachine.Context{Generator: &generator.Generator{Naming: generator.NamingQualified}},
+				ExtendedState: &statemachine.ExtendedState{
+					InputData: "@startuml\ntitle test title\n[*] --> Loading\nLoading: do / Loading\nLoading --> [*]\n@enduml",
+					Naming:    generator.NamingQualified,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Included chart",
+			fields: fields{
+				context: &statemachine.Context{Generator: &generator.Generator{FS: includeFS(t)}},
+				ExtendedState: &statemachine.ExtendedState{
+					Input:     "charts/main.puml",
+					InputData: "@startuml\ntitle test title\n[*] --> Login\nstate Login : include auth.puml\nLogin --> [*]\n@enduml",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Missing included chart",
+			fields: fields{
+				context: &statemachine.Context{Generator: &generator.Generator{FS: includeFS(t)}},
+				ExtendedState: &statemachine.ExtendedState{
+					Input:     "charts/main.puml",
+					InputData: "@startuml\ntitle test title\n[*] --> Login\nstate Login : include missing.puml\n@enduml",
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	t.Parallel()
@@ -248,6 +321,17 @@ func TestVectorSigma_ParseUMLAction(t *testing.T) {
 	}
 }
 
+// includeFS returns a file system with a chart to include in charts/main.puml.
+func includeFS(t *testing.T) afero.Fs {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	err := afero.WriteFile(fs, "charts/auth.puml", []byte("@startuml\n[*] --> Checking\nChecking: do / Check\nChecking --> [*]\n@enduml\n"), 0o664)
+	require.NoError(t, err)
+
+	return fs
+}
+
 // +vectorsigma:action:GenerateStateMachine
 func TestVectorSigma_GenerateStateMachineAction(t *testing.T) {
 	type fields struct {
@@ -262,10 +346,11 @@ func TestVectorSigma_GenerateStateMachineAction(t *testing.T) {
 	}
 
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
+		name      string
+		fields    fields
+		args      args
+		wantErr   bool
+		wantFiles int
 	}{
 		{
 			name: "OK",
@@ -276,7 +361,82 @@ func TestVectorSigma_GenerateStateMachineAction(t *testing.T) {
 					GeneratedFiles: make(map[string]statemachine.GeneratedFile),
 				},
 			},
-			wantErr: false,
+			wantErr:   false,
+			wantFiles: 9,
+		},
+		{
+			name: "OK with error transitions",
+			fields: fields{
+				context: &statemachine.Context{Generator: &generator.Generator{
+					FSM: &uml.FSM{ErrorNames: []string{"ErrTimeout"}}, Package: "unittest",
+				}},
+				ExtendedState: &statemachine.ExtendedState{
+					Package:        "unittest",
+					GeneratedFiles: make(map[string]statemachine.GeneratedFile),
+				},
+			},
+			wantErr:   false,
+			wantFiles: 10,
+		},
+		{
+			name: "OK with interfaces",
+			fields: fields{
+				context: &statemachine.Context{Generator: &generator.Generator{FSM: &uml.FSM{}, Package: "unittest"}},
+				ExtendedState: &statemachine.ExtendedState{
+					Package:        "unittest",
+					Interfaces:     true,
+					GeneratedFiles: make(map[string]statemachine.GeneratedFile),
+				},
+			},
+			wantErr:   false,
+			wantFiles: 10,
+		},
+		{
+			name: "OK with runtime",
+			fields: fields{
+				context: &statemachine.Context{Generator: &generator.Generator{FSM: &uml.FSM{}, Package: "unittest", Runtime: true}},
+				ExtendedState: &statemachine.ExtendedState{
+					Package:        "unittest",
+					Runtime:        true,
+					GeneratedFiles: make(map[string]statemachine.GeneratedFile),
+				},
+			},
+			wantErr:   false,
+			wantFiles: 9,
+		},
+		{
+			name: "OK with prefix",
+			fields: fields{
+				context: &statemachine.Context{Generator: &generator.Generator{
+					FSM:     uml.Parse("@startuml\ntitle Session\n[*] --> Idle\nIdle --> [*]\n@enduml"),
+					Package: "unittest",
+					Prefix:  "Session",
+				}},
+				ExtendedState: &statemachine.ExtendedState{
+					Package:        "unittest",
+					Prefix:         "Session",
+					GeneratedFiles: make(map[string]statemachine.GeneratedFile),
+				},
+			},
+			wantErr:   false,
+			wantFiles: 9,
+		},
+		{
+			name: "OK with table",
+			fields: fields{
+				context: &statemachine.Context{Generator: &generator.Generator{
+					FSM:     uml.Parse("@startuml\ntitle Table\n[*] --> Idle\nIdle --> [*]\n@enduml"),
+					Package: "unittest",
+					Table:   true,
+				}},
+				ExtendedState: &statemachine.ExtendedState{
+					Package:        "unittest",
+					Table:          true,
+					GeneratedFiles: make(map[string]statemachine.GeneratedFile),
+				},
+			},
+			wantErr:   false,
+			wantFiles: 9,
 		},
 	}
 
@@ -295,8 +455,23 @@ func TestVectorSigma_GenerateStateMachineAction(t *testing.T) {
 			if err := fsm.GenerateStateMachineAction(tt.args.params...); (err != nil) != tt.wantErr {
 				t.Errorf("VectorSigma.GenerateStateMachineAction() error = %v, wantErr %v", err, tt.wantErr)
 			} else if !tt.wantErr {
+				assert.Len(t, fsm.ExtendedState.GeneratedFiles, tt.wantFiles)
+
 				for k, v := range fsm.ExtendedState.GeneratedFiles {
-					assert.Contains(t, string(v.Content), "package unittest", k)
+					if filepath.Dir(k) == "unittest/fakes" {
+						assert.Contains(t, string(v.Content), "package fakes", k)
+					} else {
+						assert.Contains(t, string(v.Content), "package unittest", k)
+					}
+
+					if tt.fields.ExtendedState.Prefix != "" {
+						assert.Contains(t, k, "session_", k)
+					}
+
+					if tt.fields.ExtendedState.Table && filepath.Base(k) == "zz_generated_statemachine_paths_test.go" {
+						// The path tests stub the state table from inside the package
+						assert.NotContains(t, string(v.Content), "package unittest_test", k)
+					}
 				}
 			}
 		})
@@ -394,12 +569,16 @@ func TestVectorSigma_FilterGeneratedFilesAction(t *testing.T) {
 	_ = fs.Mkdir("outputfolder/statemachine", 0o755)
 	_ = afero.WriteFile(fs, "outputfolder/statemachine/extendedstate.go", []byte("1"), 0o644)
 	_ = afero.WriteFile(fs, "outputfolder/statemachine/action.go", []byte("1"), 0o644)
+	_ = afero.WriteFile(fs, "outputfolder/statemachine/session_extendedstate.go", []byte("1"), 0o644)
+	_ = afero.WriteFile(fs, "outputfolder/statemachine/session_actions.go", []byte("1"), 0o644)
 
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
+		name        string
+		fields      fields
+		args        args
+		wantErr     bool
+		wantRemoved string
+		wantKept    string
 	}{
 		{
 			name: "File exists",
@@ -414,7 +593,27 @@ func TestVectorSigma_FilterGeneratedFilesAction(t *testing.T) {
 					Package: "statemachine",
 				},
 			},
-			wantErr: false,
+			wantErr:     false,
+			wantRemoved: "statemachine/extendedstate.go",
+			wantKept:    "statemachine/action.go",
+		},
+		{
+			name: "Prefixed file exists",
+			fields: fields{
+				context: &statemachine.Context{Generator: &generator.Generator{FS: fs}},
+				ExtendedState: &statemachine.ExtendedState{
+					GeneratedFiles: map[string]statemachine.GeneratedFile{
+						"statemachine/session_extendedstate.go": {Content: []byte("1")},
+						"statemachine/session_actions.go":       {Content: []byte("1"), IncrementalChange: true},
+					},
+					Output:  "outputfolder",
+					Package: "statemachine",
+					Prefix:  "Session",
+				},
+			},
+			wantErr:     false,
+			wantRemoved: "statemachine/session_extendedstate.go",
+			wantKept:    "statemachine/session_actions.go",
 		},
 	}
 
@@ -433,8 +632,8 @@ func TestVectorSigma_FilterGeneratedFilesAction(t *testing.T) {
 			if err := fsm.FilterGeneratedFilesAction(tt.args.params...); (err != nil) != tt.wantErr {
 				t.Errorf("VectorSigma.FilterExistingFilesAction() error = %v, wantErr %v", err, tt.wantErr)
 			} else if !tt.wantErr {
-				assert.NotContains(t, tt.fields.ExtendedState.GeneratedFiles, "statemachine/extendedstate.go")
-				assert.Contains(t, tt.fields.ExtendedState.GeneratedFiles, "statemachine/action.go")
+				assert.NotContains(t, tt.fields.ExtendedState.GeneratedFiles, tt.wantRemoved)
+				assert.Contains(t, tt.fields.ExtendedState.GeneratedFiles, tt.wantKept)
 			}
 		})
 	}
@@ -19,6 +19,13 @@ type Context struct {
 type ExtendedState struct {
 	GeneratedFiles     map[string]GeneratedFile
 	Init               bool
+	Interfaces         bool
+	Runtime            bool
+	Table              bool
+	DebugHandler       bool
+	Metrics            bool
+	OTel               bool
+	Supervisor         bool
 	PackageExists      bool
 	Operator           bool
 	APIVersion         string
@@ -29,6 +36,8 @@ type ExtendedState struct {
 	Module             string
 	Output             string
 	Package            string
+	Prefix             string
+	Naming             string
 	Error              error
 	VectorSigmaVersion string
 }
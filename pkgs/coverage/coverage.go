@@ -0,0 +1,280 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package coverage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mhersson/vectorsigma/pkgs/trace"
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+)
+
+const (
+	uncoveredState      = "#pink"
+	uncoveredTransition = "[#red,bold]"
+)
+
+// Report holds the coverage of the states, transitions and guard outcomes of a
+// chart.
+type Report struct {
+	States      []State
+	Transitions []Transition
+	Guards      []Guard
+}
+
+// State is the coverage of a single state.
+type State struct {
+	Name    string
+	Covered bool
+}
+
+// Transition is the coverage of a single transition.
+type Transition struct {
+	uml.Edge
+	Covered bool
+}
+
+// Guard is the coverage of a single outcome of a guard.
+type Guard struct {
+	State   string
+	Name    string
+	Passed  bool
+	Covered bool
+}
+
+// Read reads and merges the coverage files written for the state machine with
+// the given title in dir.
+func Read(dir, title string) ([]trace.Record, error) {
+	files, err := filepath.Glob(filepath.Join(dir, title+"-*.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find coverage files: %w", err)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no coverage files found for %s in %s", title, dir)
+	}
+
+	var records []trace.Record
+
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open coverage file: %w", err)
+		}
+
+		fileRecords, err := trace.Read(file)
+		file.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		records = append(records, fileRecords...)
+	}
+
+	return records, nil
+}
+
+// Compute returns the coverage of the chart by the records.
+func Compute(fsm *uml.FSM, records []trace.Record) Report {
+	var report Report
+
+	visited := make(map[string]bool)
+	taken := make(map[uml.Edge]bool)
+	outcomes := make(map[string]bool)
+
+	for _, record := range records {
+		visited[record.State] = true
+
+		if record.Next != uml.FinalState || record.Parent == "" {
+			// Ending a composite state does not reach the FinalState of the chart
+			visited[record.Next] = true
+		}
+
+		taken[record.Edge()] = true
+
+		for _, guard := range record.Guards {
+			outcomes[guardKey(record.State, guard.Name, guard.Passed)] = true
+		}
+	}
+
+	for _, state := range fsm.AllStates {
+		if state != uml.InitialState {
+			report.States = append(report.States, State{Name: state, Covered: visited[state]})
+		}
+	}
+
+	seen := make(map[string]bool)
+
+	for _, edge := range fsm.Edges() {
+		report.Transitions = append(report.Transitions, Transition{
+			Edge:    edge,
+			Covered: taken[edge],
+		})
+
+		if edge.Guard == "" || seen[edge.From+"/"+edge.Guard] {
+			continue
+		}
+
+		seen[edge.From+"/"+edge.Guard] = true
+
+		for _, passed := range []bool{true, false} {
+			report.Guards = append(report.Guards, Guard{
+				State:   edge.From,
+				Name:    edge.Guard,
+				Passed:  passed,
+				Covered: outcomes[guardKey(edge.From, edge.Guard, passed)],
+			})
+		}
+	}
+
+	return report
+}
+
+func guardKey(state, guard string, passed bool) string {
+	return fmt.Sprintf("%s/%s=%t", state, guard, passed)
+}
+
+// Print writes a summary of the report to w, followed by everything that is not
+// covered.
+func (r Report) Print(w io.Writer) error {
+	var (
+		sb                  strings.Builder
+		states, transitions []string
+		guards              []string
+	)
+
+	for _, state := range r.States {
+		if !state.Covered {
+			states = append(states, state.Name)
+		}
+	}
+
+	for _, transition := range r.Transitions {
+		if !transition.Covered {
+			transitions = append(transitions, describe(transition.Edge))
+		}
+	}
+
+	for _, guard := range r.Guards {
+		if !guard.Covered {
+			guards = append(guards, fmt.Sprintf("%s: %s=%t", guard.State, guard.Name, guard.Passed))
+		}
+	}
+
+	fmt.Fprintf(&sb, "States:         %s\n", ratio(len(r.States)-len(states), len(r.States)))
+	fmt.Fprintf(&sb, "Transitions:    %s\n", ratio(len(r.Transitions)-len(transitions), len(r.Transitions)))
+	fmt.Fprintf(&sb, "Guard outcomes: %s\n", ratio(len(r.Guards)-len(guards), len(r.Guards)))
+
+	for _, section := range []struct {
+		title string
+		items []string
+	}{
+		{"Uncovered states", states},
+		{"Uncovered transitions", transitions},
+		{"Uncovered guard outcomes", guards},
+	} {
+		if len(section.items) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "\n%s:\n", section.title)
+
+		for _, item := range section.items {
+			fmt.Fprintf(&sb, "  %s\n", item)
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+
+	return err
+}
+
+// Annotate returns the chart with the uncovered states and transitions
+// colored.
+func Annotate(chart string, fsm *uml.FSM, r Report) string {
+	uncoveredStates := make(map[string]bool)
+	uncoveredEdges := make(map[uml.Edge]bool)
+
+	for _, state := range r.States {
+		if !state.Covered {
+			uncoveredStates[state.Name] = true
+		}
+	}
+
+	for _, transition := range r.Transitions {
+		if !transition.Covered {
+			uncoveredEdges[transition.Edge] = true
+		}
+	}
+
+	return uml.Annotate(chart, fsm,
+		func(state string) string {
+			if uncoveredStates[state] {
+				return uncoveredState
+			}
+
+			return ""
+		},
+		func(edge uml.Edge) string {
+			if uncoveredEdges[edge] {
+				return uncoveredTransition
+			}
+
+			return ""
+		})
+}
+
+func describe(edge uml.Edge) string {
+	from := edge.From
+	if edge.Parent != "" {
+		from = edge.Parent + "/" + edge.From
+	}
+
+	switch {
+	case edge.OnError && edge.Error != "":
+		return fmt.Sprintf("%s -> %s on error(%s)", from, edge.To, edge.Error)
+	case edge.OnError:
+		return fmt.Sprintf("%s -> %s on error", from, edge.To)
+	case edge.OnTimeout:
+		return fmt.Sprintf("%s -> %s on timeout", from, edge.To)
+	case edge.Time != "":
+		return fmt.Sprintf("%s -> %s %s", from, edge.To, edge.Time)
+	case edge.Guard != "":
+		return fmt.Sprintf("%s -> %s [%s]", from, edge.To, edge.Guard)
+	default:
+		return fmt.Sprintf("%s -> %s", from, edge.To)
+	}
+}
+
+func ratio(covered, total int) string {
+	percent := 100.0
+	if total > 0 {
+		percent = float64(covered) / float64(total) * 100
+	}
+
+	return fmt.Sprintf("%d/%d (%.1f%%)", covered, total, percent)
+}
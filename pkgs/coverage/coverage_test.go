@@ -0,0 +1,201 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package coverage_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mhersson/vectorsigma/pkgs/coverage"
+	"github.com/mhersson/vectorsigma/pkgs/trace"
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const chart = `@startuml
+title Fetcher
+[*] --> Fetching
+Fetching: do / Fetch
+Fetching --> Retrying : on error(ErrTimeout)
+Fetching -[dotted]-> Failed : [ IsError ]
+Fetching -[bold]-> [*]
+Retrying --> Fetching
+Retrying --> Failed : on timeout
+Failed --> [*]
+Failed --> Retrying : after(1m)
+@enduml`
+
+var records = []trace.Record{
+	{State: "InitialState", Next: "Fetching"},
+	{State: "Fetching", Guards: []trace.Guard{{Name: "IsError", Passed: false}}, Next: "FinalState", Done: true},
+	{State: "InitialState", Next: "Fetching"},
+	{State: "Fetching", Next: "Retrying", OnError: true, ErrorMatch: "ErrTimeout"},
+}
+
+func TestCompute(t *testing.T) {
+	t.Parallel()
+
+	report := coverage.Compute(uml.Parse(chart), records)
+
+	assert.Equal(t, []coverage.State{
+		{Name: "Failed", Covered: false},
+		{Name: "Fetching", Covered: true},
+		{Name: "FinalState", Covered: true},
+		{Name: "Retrying", Covered: true},
+	}, report.States)
+
+	covered := make(map[string]bool)
+	for _, transition := range report.Transitions {
+		covered[transition.From+" -> "+transition.To] = transition.Covered
+	}
+
+	assert.Equal(t, map[string]bool{
+		"Failed -> FinalState":     false,
+		"Failed -> Retrying":       false,
+		"Fetching -> Failed":       false,
+		"Fetching -> FinalState":   true,
+		"Fetching -> Retrying":     true,
+		"InitialState -> Fetching": true,
+		"Retrying -> Failed":       false,
+		"Retrying -> Fetching":     false,
+	}, covered)
+
+	assert.Equal(t, []coverage.Guard{
+		{State: "Fetching", Name: "IsError", Passed: true, Covered: false},
+		{State: "Fetching", Name: "IsError", Passed: false, Covered: true},
+	}, report.Guards)
+}
+
+func TestCompute_EdgeKinds(t *testing.T) {
+	t.Parallel()
+
+	chart := `@startuml
+[*] --> Fetching
+Fetching: do / Fetch
+Fetching --> Retrying : on error(ErrTimeout)
+Fetching --> Retrying : after(5s)
+Fetching --> Retrying
+state Retrying {
+  [*] --> Waiting
+  Waiting --> [*]
+}
+Retrying --> [*]
+@enduml`
+
+	records := []trace.Record{
+		{State: "InitialState", Next: "Fetching"},
+		{State: "Fetching", Next: "Retrying"},
+		{State: "Retrying", Next: "InitialState"},
+		{Parent: "Retrying", State: "InitialState", Next: "Waiting"},
+		{Parent: "Retrying", State: "Waiting", Next: "FinalState"},
+	}
+
+	report := coverage.Compute(uml.Parse(chart), records)
+
+	covered := make(map[string]bool)
+	for _, transition := range report.Transitions {
+		covered[transition.Parent+"/"+transition.From+" -> "+transition.To+" "+transition.Error+transition.Time] = transition.Covered
+	}
+
+	assert.Equal(t, map[string]bool{
+		"/Fetching -> Retrying ":            true,
+		"/Fetching -> Retrying ErrTimeout":  false,
+		"/Fetching -> Retrying after(5s)":   false,
+		"/InitialState -> Fetching ":        true,
+		"/Retrying -> FinalState ":          false,
+		"Retrying/InitialState -> Waiting ": true,
+		"Retrying/Waiting -> FinalState ":   true,
+	}, covered)
+
+	for _, state := range report.States {
+		if state.Name == uml.FinalState {
+			assert.False(t, state.Covered, "ending a composite state does not reach the FinalState")
+		}
+	}
+}
+
+func TestReport_Print(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	require.NoError(t, coverage.Compute(uml.Parse(chart), records).Print(&buf))
+	assert.Equal(t, `States:         3/4 (75.0%)
+Transitions:    3/8 (37.5%)
+Guard outcomes: 1/2 (50.0%)
+
+Uncovered states:
+  Failed
+
+Uncovered transitions:
+  Failed -> FinalState
+  Failed -> Retrying after(1m0s)
+  Fetching -> Failed [IsError]
+  Retrying -> Failed on timeout
+  Retrying -> Fetching
+
+Uncovered guard outcomes:
+  Fetching: IsError=true
+`, buf.String())
+}
+
+func TestAnnotate(t *testing.T) {
+	t.Parallel()
+
+	fsm := uml.Parse(chart)
+
+	assert.Equal(t, `@startuml
+title Fetcher
+[*] --> Fetching
+Fetching: do / Fetch
+Fetching --> Retrying : on error(ErrTimeout)
+Fetching -[#red,bold]-> Failed : [ IsError ]
+Fetching -[bold]-> [*]
+Retrying -[#red,bold]-> Fetching
+Retrying -[#red,bold]-> Failed : on timeout
+Failed -[#red,bold]-> [*]
+Failed -[#red,bold]-> Retrying : after(1m)
+state Failed #pink
+@enduml`, coverage.Annotate(chart, fsm, coverage.Compute(fsm, records)))
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Fetcher-1.jsonl"),
+		[]byte(`{"step":0,"state":"InitialState","next":"Fetching"}`+"\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Fetcher-2.jsonl"),
+		[]byte(`{"step":0,"state":"Fetching","next":"FinalState","done":true}`+"\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Other-1.jsonl"),
+		[]byte(`{"step":0,"state":"Other","next":"FinalState"}`+"\n"), 0o600))
+
+	got, err := coverage.Read(dir, "Fetcher")
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+
+	_, err = coverage.Read(dir, "Missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+)
+
+// ErrUnsupported is returned when the generated code can't run the chart as
+// drawn.
+var ErrUnsupported = errors.New("the chart is not supported")
+
+// Check checks that the generated code can run the chart.
+//
+// The reconcile loop of an operator requeues after the delay of a time
+// transition instead of waiting for it, and the next reconcile starts over
+// from the initial state. A time transition in an operator must therefore end
+// the machine.
+func (g *Generator) Check() error {
+	if !g.Operator {
+		return nil
+	}
+
+	problems := checkTimeTransitions(g.FSM.States, true)
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnsupported, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// checkTimeTransitions returns the time transitions of the states, including
+// the states of the composite states, that don't end the machine. Inside a
+// composite state the final state only ends the state machine of the composite
+// state.
+func checkTimeTransitions(states map[string]*uml.State, top bool) []string {
+	var problems []string
+
+	for _, name := range slices.Sorted(maps.Keys(states)) {
+		state := states[name]
+
+		for _, transition := range state.TimeTransitions {
+			target, ok := states[transition.Target]
+			if top && (transition.Target == uml.FinalState || ok && target.End) {
+				continue
+			}
+
+			problems = append(problems, fmt.Sprintf("%s --> %s: %s must end the machine in an operator",
+				name, transition.Target, transition.Event()))
+		}
+
+		problems = append(problems, checkTimeTransitions(state.Composite.States, false)...)
+	}
+
+	return problems
+}
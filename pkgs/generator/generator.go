@@ -26,11 +26,16 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"go/token"
+	"io/fs"
 	"os/exec"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
+	"unicode"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
@@ -43,7 +48,7 @@ import (
 	"github.com/mhersson/vectorsigma/pkgs/uml"
 )
 
-//go:embed templates/application/* templates/operator/*
+//go:embed templates/application/* templates/operator/* templates/engine/*
 var templates embed.FS
 
 type Generator struct {
@@ -58,18 +63,53 @@ type Generator struct {
 	RelativePath string
 	Version      string
 	Init         bool
+	Operator     bool
+	Interfaces   bool
+	Runtime      bool
+	Table        bool
+	DebugHandler bool
+	Metrics      bool
+	OTel         bool
+	Supervisor   bool
+	Prefix       string
+	Naming       string
+	Chart        string // The PlantUML source of the chart, embedded in the generated code
 }
 
 func (g *Generator) ExecuteTemplate(filename string) ([]byte, error) {
 	titleTransformer := cases.Title(language.English)
 
 	funcMap := template.FuncMap{
-		"title":   titleTransformer.String,
-		"toLower": strings.ToLower,
-		"toUpper": strings.ToUpper,
+		"title":        titleTransformer.String,
+		"toLower":      strings.ToLower,
+		"toUpper":      strings.ToUpper,
+		"errorMessage": errorMessage,
+		"goDuration":   goDuration,
+		"goString":     goString,
+		// The receivers the actions and guards are bound to in New
+		"actionReceiver": func() string { return g.receiver("Actions") },
+		"guardReceiver":  func() string { return g.receiver("Guards") },
+		// The constants of the states, actions and guards in the naming scheme
+		"state":  func(name string) string { return g.identifier(uml.KindState, name) },
+		"action": func(name string) string { return g.identifier(uml.KindAction, name) },
+		"guard":  func(name string) string { return g.identifier(uml.KindGuard, name) },
 	}
 
-	tmpl, err := template.New(filepath.Base(filename)).Funcs(funcMap).ParseFS(templates, filename)
+	// The engines of the state machines are shared by the applications and the
+	// operators, which implement the hooks of the engines in engine.tmpl
+	hooks := filepath.Join(filepath.Dir(filename), "engine.tmpl")
+
+	// The files that are the same for both are only found among the engines
+	if !exists(filename) {
+		filename = filepath.Join("templates/engine", filepath.Base(filename))
+	}
+
+	patterns := []string{filename, "templates/engine/*.tmpl"}
+	if exists(hooks) {
+		patterns = append(patterns, hooks)
+	}
+
+	tmpl, err := template.New(filepath.Base(filename)).Funcs(funcMap).ParseFS(templates, patterns...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -84,6 +124,81 @@ func (g *Generator) ExecuteTemplate(filename string) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+// exists reports whether the embedded template exists.
+func exists(filename string) bool {
+	_, err := fs.Stat(templates, filename)
+
+	return err == nil
+}
+
+// receiver returns the receiver of the actions or guards. They are methods on
+// the state machine itself, unless interfaces are generated.
+func (g *Generator) receiver(field string) string {
+	if g.Interfaces {
+		return "fsm." + field
+	}
+
+	return "fsm"
+}
+
+// errorMessage turns the name of a sentinel error into an error message, e.g.
+// ErrNotFound becomes "not found".
+func errorMessage(name string) string {
+	name = strings.TrimPrefix(name, "Err")
+
+	var words []string
+
+	start := 0
+
+	for i, r := range name {
+		if i > start && unicode.IsUpper(r) {
+			words = append(words, strings.ToLower(name[start:i]))
+			start = i
+		}
+	}
+
+	words = append(words, strings.ToLower(name[start:]))
+
+	return strings.Join(words, " ")
+}
+
+// goDuration turns a duration into a Go expression, e.g. 200ms becomes
+// "200 * time.Millisecond".
+func goDuration(d time.Duration) string {
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"time.Hour", time.Hour},
+		{"time.Minute", time.Minute},
+		{"time.Second", time.Second},
+		{"time.Millisecond", time.Millisecond},
+		{"time.Microsecond", time.Microsecond},
+	}
+
+	if d == 0 {
+		return "0"
+	}
+
+	for _, unit := range units {
+		if d%unit.size == 0 {
+			return fmt.Sprintf("%d * %s", d/unit.size, unit.name)
+		}
+	}
+
+	return fmt.Sprintf("%d * time.Nanosecond", d)
+}
+
+// goString turns a string into a Go string literal, a raw string unless it
+// holds characters a raw string can't.
+func goString(s string) string {
+	if strings.ContainsAny(s, "`\r") {
+		return strconv.Quote(s)
+	}
+
+	return "`" + s + "`"
+}
+
 // Check if file or folder exists.
 func (g *Generator) Exists(path string) (bool, error) {
 	return afero.Exists(g.FS, path)
@@ -91,6 +206,10 @@ func (g *Generator) Exists(path string) (bool, error) {
 
 // Write file to disk.
 func (g *Generator) WriteFile(path string, data []byte) error {
+	if err := g.FS.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
 	err := afero.WriteFile(g.FS, path, data, 0o644)
 	if err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
@@ -123,7 +242,8 @@ func (g *Generator) FormatCode(ctx context.Context, path string) error {
 // have a doc comment prefixed with '// +vectorsigma' remove them from the
 // existing code. If any functions are in both trees, replace the existing code
 // with the generated code if the existing code still contains a `// TODO:
-// Impment me!` comment in the function body.
+// Impment me!` comment in the function body. Variables with a '// +vectorsigma'
+// doc comment are added and removed the same way, but never replaced.
 func (g *Generator) IncrementalUpdate(fullpath string, data []byte) ([]byte, bool, error) {
 	// load existing code
 	existing, err := afero.ReadFile(g.FS, fullpath)
@@ -147,6 +267,10 @@ func (g *Generator) IncrementalUpdate(fullpath string, data []byte) ([]byte, boo
 		containsChanges = true
 	}
 
+	if changed := addMissingVars(exisitingNode, generatedNode); changed {
+		containsChanges = true
+	}
+
 	if changed := removeNotInGenerated(exisitingNode, generatedNode); changed {
 		containsChanges = true
 	}
@@ -213,13 +337,45 @@ func addOrReplace(existingFile, generatedFile *dst.File) bool {
 	return containsChanges
 }
 
-// removeNotInGenerated removes functions with the // +vectorsigma comment that
-// are not in the generated code.
+// addMissingVars adds the variables with the // +vectorsigma comment in the
+// generated code that are not in the existing code. Existing variables are
+// left untouched, as the user is free to change their values.
+func addMissingVars(existingFile, generatedFile *dst.File) bool {
+	containsChanges := false
+
+	for _, genDecl := range generatedFile.Decls {
+		name, ok := varName(genDecl)
+		if !ok || !isTagged(genDecl) {
+			continue
+		}
+
+		found := false
+
+		for _, exDecl := range existingFile.Decls {
+			if exName, ok := varName(exDecl); ok && exName == name {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			containsChanges = true
+
+			existingFile.Decls = append(existingFile.Decls, genDecl)
+		}
+	}
+
+	return containsChanges
+}
+
+// removeNotInGenerated removes functions and variables with the // +vectorsigma
+// comment that are not in the generated code.
 func removeNotInGenerated(exisitingNode, generatedNode *dst.File) bool {
 	containsChanges := false
 
 	for i := 0; i < len(exisitingNode.Decls); i++ {
-		exDecl, ok := exisitingNode.Decls[i].(*dst.FuncDecl)
+		exName, ok := declName(exisitingNode.Decls[i])
 		if !ok {
 			continue
 		}
@@ -227,12 +383,7 @@ func removeNotInGenerated(exisitingNode, generatedNode *dst.File) bool {
 		found := false
 
 		for _, genDecl := range generatedNode.Decls {
-			genDecl, ok := genDecl.(*dst.FuncDecl)
-			if !ok {
-				continue
-			}
-
-			if exDecl.Name.Name == genDecl.Name.Name {
+			if genName, ok := declName(genDecl); ok && exName == genName {
 				found = true
 
 				break
@@ -240,17 +391,49 @@ func removeNotInGenerated(exisitingNode, generatedNode *dst.File) bool {
 		}
 
 		if !found {
-			for _, line := range exDecl.Decorations().Start {
-				if strings.HasPrefix(line, "// +vectorsigma:") {
-					containsChanges = true
-					exisitingNode.Decls = slices.Delete(exisitingNode.Decls, i, i+1)
-					i--
-
-					break
-				}
+			if isTagged(exisitingNode.Decls[i]) {
+				containsChanges = true
+				exisitingNode.Decls = slices.Delete(exisitingNode.Decls, i, i+1)
+				i--
 			}
 		}
 	}
 
 	return containsChanges
 }
+
+// declName returns the name of a function or a single variable declaration.
+func declName(decl dst.Decl) (string, bool) {
+	if funcDecl, ok := decl.(*dst.FuncDecl); ok {
+		return funcDecl.Name.Name, true
+	}
+
+	return varName(decl)
+}
+
+// varName returns the name of a declaration holding a single variable.
+func varName(decl dst.Decl) (string, bool) {
+	genDecl, ok := decl.(*dst.GenDecl)
+	if !ok || genDecl.Tok != token.VAR || len(genDecl.Specs) != 1 {
+		return "", false
+	}
+
+	spec, ok := genDecl.Specs[0].(*dst.ValueSpec)
+	if !ok || len(spec.Names) != 1 {
+		return "", false
+	}
+
+	return spec.Names[0].Name, true
+}
+
+// isTagged reports if the declaration has a doc comment prefixed with
+// '// +vectorsigma:'.
+func isTagged(decl dst.Decl) bool {
+	for _, line := range decl.Decorations().Start {
+		if strings.HasPrefix(line, "// +vectorsigma:") {
+			return true
+		}
+	}
+
+	return false
+}
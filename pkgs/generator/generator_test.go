@@ -62,6 +62,26 @@ func (fsm *UnitTest) IsErrorGuard(_ ...string) bool {
 	// TODO: Implement me!
 	return false
 }
+`,
+		},
+		{
+			name: "Errors template",
+			generator: &generator.Generator{
+				Package: "statemachine",
+				FS:      afero.NewMemMapFs(),
+				FSM: &uml.FSM{
+					ErrorNames: []string{"ErrNotFound"},
+					Title:      "UnitTest",
+				},
+			},
+			filename: "templates/application/errors.go.tmpl",
+			wantErr:  false,
+			want: `package statemachine
+
+import "errors"
+// +vectorsigma:error:ErrNotFound
+var ErrNotFound = errors.New("not found")
+
 `,
 		},
 		{
@@ -278,6 +298,48 @@ func (fsm *TrafficLight) AddMe(_ ...string) error {
 	// TODO: Implement me!
 	return nil
 }
+`,
+			changed: true,
+		},
+		{
+			name:      "IncrementalUpdate with variables",
+			generator: &generator.Generator{FS: afero.NewMemMapFs()},
+			filepath:  "/path/to/errors.go",
+			existingCode: `package statemachine
+
+import "errors"
+
+// +vectorsigma:error:ErrTimeout
+var ErrTimeout = errors.New("custom message is kept")
+
+// +vectorsigma:error:ErrRemoved
+var ErrRemoved = errors.New("removed")
+
+var errLeftAlone = errors.New("left alone")
+`,
+
+			generatedCode: `package statemachine
+
+import "errors"
+
+// +vectorsigma:error:ErrTimeout
+var ErrTimeout = errors.New("timeout")
+
+// +vectorsigma:error:ErrAdded
+var ErrAdded = errors.New("added")
+`,
+
+			wantedCode: `package statemachine
+
+import "errors"
+
+// +vectorsigma:error:ErrTimeout
+var ErrTimeout = errors.New("custom message is kept")
+
+var errLeftAlone = errors.New("left alone")
+
+// +vectorsigma:error:ErrAdded
+var ErrAdded = errors.New("added")
 `,
 			changed: true,
 		},
@@ -298,3 +360,389 @@ func (fsm *TrafficLight) AddMe(_ ...string) error {
 		})
 	}
 }
+
+func TestGenerator_ApplyPrefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator *generator.Generator
+		files     map[string]string
+		want      map[string]string
+		wantErr   bool
+	}{
+		{
+			name:      "No prefix",
+			generator: &generator.Generator{Package: "fsm"},
+			files:     map[string]string{"statemachine.go": "package fsm\n\ntype StateName string\n"},
+			want:      map[string]string{"statemachine.go": "package fsm\n\ntype StateName string\n"},
+		},
+		{
+			name:      "Declarations and references",
+			generator: &generator.Generator{Package: "fsm", Prefix: "Session"},
+			files: map[string]string{
+				"statemachine.go": `package fsm
+
+import "errors"
+
+type StateName string
+
+const Idle StateName = "Idle"
+
+// ErrNoTransition is returned when the machine is stuck.
+var ErrNoTransition = errors.New("no transition")
+
+// Runner represents the state machine.
+type Runner struct {
+	Context       *Context
+	CurrentState  StateName
+	StateConfigs  map[StateName]StateConfig
+}
+
+type StateConfig struct {
+	Parent StateName
+}
+
+type realClock struct{}
+
+// New returns a Runner.
+func New(opts ...func(*Runner)) *Runner {
+	fsm := &Runner{
+		Context:      &Context{},
+		CurrentState: Idle,
+		StateConfigs: map[StateName]StateConfig{
+			Idle: {Parent: Idle},
+		},
+	}
+
+	for _, opt := range opts {
+		opt(fsm)
+	}
+
+	return fsm
+}
+
+func (fsm *Runner) Run() error {
+	if fsm.CurrentState == Idle {
+		return ErrNoTransition
+	}
+
+	return nil
+}
+`,
+				"extendedstate.go": `package fsm
+
+type Context struct {
+	Idle bool
+}
+`,
+				"actions_test.go": `package fsm_test
+
+import (
+	"testing"
+
+	"example.com/fsm"
+)
+
+func TestRunner_Run(t *testing.T) {
+	fsm := fsm.New()
+	Idle := fsm.CurrentState
+	_ = Idle
+}
+
+func TestMain(m *testing.M) {}
+`,
+			},
+			want: map[string]string{
+				"statemachine.go": `package fsm
+
+import "errors"
+
+type SessionStateName string
+
+const SessionIdle SessionStateName = "Idle"
+
+// ErrSessionNoTransition is returned when the machine is stuck.
+var ErrSessionNoTransition = errors.New("no transition")
+
+// SessionRunner represents the state machine.
+type SessionRunner struct {
+	Context      *SessionContext
+	CurrentState SessionStateName
+	StateConfigs map[SessionStateName]SessionStateConfig
+}
+
+type SessionStateConfig struct {
+	Parent SessionStateName
+}
+
+type sessionRealClock struct{}
+
+// NewSession returns a Runner.
+func NewSession(opts ...func(*SessionRunner)) *SessionRunner {
+	fsm := &SessionRunner{
+		Context:      &SessionContext{},
+		CurrentState: SessionIdle,
+		StateConfigs: map[SessionStateName]SessionStateConfig{
+			SessionIdle: {Parent: SessionIdle},
+		},
+	}
+
+	for _, opt := range opts {
+		opt(fsm)
+	}
+
+	return fsm
+}
+
+func (fsm *SessionRunner) Run() error {
+	if fsm.CurrentState == SessionIdle {
+		return ErrSessionNoTransition
+	}
+
+	return nil
+}
+`,
+				"extendedstate.go": `package fsm
+
+type SessionContext struct {
+	Idle bool
+}
+`,
+				"actions_test.go": `package fsm_test
+
+import (
+	"testing"
+
+	"example.com/fsm"
+)
+
+func TestSessionRunner_Run(t *testing.T) {
+	fsm := fsm.NewSession()
+	Idle := fsm.CurrentState
+	_ = Idle
+}
+
+func TestMain(m *testing.M) {}
+`,
+			},
+		},
+		{
+			name:      "Invalid code",
+			generator: &generator.Generator{Package: "fsm", Prefix: "Session"},
+			files:     map[string]string{"statemachine.go": "package fsm\n\ntype StateName\n"},
+			wantErr:   true,
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			files := make(map[string][]byte)
+			for filename, code := range tt.files {
+				files[filename] = []byte(code)
+			}
+
+			err := tt.generator.ApplyPrefix(files)
+			if tt.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			for filename, code := range tt.want {
+				assert.Equal(t, code, string(files[filename]), filename)
+			}
+		})
+	}
+}
+
+func TestGenerator_Validate(t *testing.T) {
+	const chart = `@startuml
+title Loader
+[*] --> Loading
+Loading: do / Loading
+Loading --> New: IsDone
+New --> [*]
+@enduml
+`
+
+	tests := []struct {
+		name      string
+		naming    string
+		data      string
+		optional  generator.Generator
+		wantErr   bool
+		wantNames []string
+	}{
+		{
+			name:   "Plain names",
+			naming: generator.NamingPlain,
+			data:   "@startuml\ntitle Loader\n[*] --> Loading\nLoading: do / Load\nLoading --> [*]: IsDone\n@enduml\n",
+		},
+		{
+			name:      "Plain names colliding",
+			naming:    generator.NamingPlain,
+			data:      chart,
+			wantErr:   true,
+			wantNames: []string{"state Loading and action Loading", "state New collides with the generated New"},
+		},
+		{
+			name:   "Qualified names",
+			naming: generator.NamingQualified,
+			data:   chart,
+		},
+		{
+			name:      "Qualified names colliding",
+			naming:    generator.NamingQualified,
+			data:      "@startuml\ntitle Loader\n[*] --> Name\nName --> Config\nConfig --> [*]\n@enduml\n",
+			wantErr:   true,
+			wantNames: []string{"state Name collides with the generated StateName", "state Config collides with the generated StateConfig"},
+		},
+		{
+			name:      "Keyword",
+			naming:    generator.NamingPlain,
+			data:      "@startuml\ntitle Loader\n[*] --> func\nfunc --> [*]\n@enduml\n",
+			wantErr:   true,
+			wantNames: []string{"state func is not a valid Go identifier"},
+		},
+		{
+			name:      "Unexported action",
+			naming:    generator.NamingQualified,
+			data:      "@startuml\ntitle Loader\n[*] --> Loading\nLoading: do / load\nLoading --> [*]\n@enduml\n",
+			wantErr:   true,
+			wantNames: []string{"action load must start with an upper case letter"},
+		},
+		{
+			name:      "Title colliding",
+			naming:    generator.NamingPlain,
+			data:      "@startuml\ntitle Context\n[*] --> Loading\nLoading --> [*]\n@enduml\n",
+			wantErr:   true,
+			wantNames: []string{"title Context collides with the generated Context"},
+		},
+		{
+			name:   "Optional file disabled",
+			naming: generator.NamingPlain,
+			data:   "@startuml\ntitle Loader\n[*] --> Metrics\nMetrics --> [*]\n@enduml\n",
+		},
+		{
+			name:      "Debug handler colliding",
+			naming:    generator.NamingPlain,
+			data:      "@startuml\ntitle Loader\n[*] --> DebugHandler\nDebugHandler --> [*]\n@enduml\n",
+			optional:  generator.Generator{DebugHandler: true},
+			wantErr:   true,
+			wantNames: []string{"state DebugHandler collides with the generated DebugHandler"},
+		},
+		{
+			name:      "Metrics colliding",
+			naming:    generator.NamingPlain,
+			data:      "@startuml\ntitle Loader\n[*] --> Metrics\nMetrics --> [*]\n@enduml\n",
+			optional:  generator.Generator{Metrics: true},
+			wantErr:   true,
+			wantNames: []string{"state Metrics collides with the generated Metrics"},
+		},
+		{
+			name:      "OpenTelemetry colliding",
+			naming:    generator.NamingPlain,
+			data:      "@startuml\ntitle Loader\n[*] --> OTelTracer\nOTelTracer --> [*]\n@enduml\n",
+			optional:  generator.Generator{OTel: true},
+			wantErr:   true,
+			wantNames: []string{"state OTelTracer collides with the generated OTelTracer"},
+		},
+		{
+			name:      "Supervisor colliding",
+			naming:    generator.NamingPlain,
+			data:      "@startuml\ntitle Loader\n[*] --> Supervisor\nSupervisor --> [*]\n@enduml\n",
+			optional:  generator.Generator{Supervisor: true},
+			wantErr:   true,
+			wantNames: []string{"state Supervisor collides with the generated Supervisor"},
+		},
+		{
+			name:      "Coverage colliding",
+			naming:    generator.NamingPlain,
+			data:      "@startuml\ntitle Loader\n[*] --> coverageRecorder\ncoverageRecorder --> [*]\n@enduml\n",
+			wantErr:   true,
+			wantNames: []string{"state coverageRecorder collides with the generated coverageRecorder"},
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := &tt.optional
+			g.FSM = uml.Parse(tt.data)
+			g.Package = "fsm"
+			g.Naming = tt.naming
+
+			err := g.Validate()
+			if !tt.wantErr {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.ErrorIs(t, err, generator.ErrInvalidNames)
+
+			for _, name := range tt.wantNames {
+				assert.Contains(t, err.Error(), name)
+			}
+		})
+	}
+}
+
+func TestGenerator_Check(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator bool
+		data     string
+		wantErr  string
+	}{
+		{
+			name: "Application",
+			data: "@startuml\ntitle Poller\n[*] --> Polling\nPolling --> Sleeping\nSleeping --> Polling: after(10s)\n@enduml\n",
+		},
+		{
+			name:     "Operator ending the machine",
+			operator: true,
+			data:     "@startuml\ntitle Poller\nstate Stopped <<end>>\n[*] --> Polling\nPolling --> Sleeping\nSleeping --> [*]: after(10s)\nSleeping --> Stopped: at(07:30)\n@enduml\n",
+		},
+		{
+			name:     "Operator not ending the machine",
+			operator: true,
+			data:     "@startuml\ntitle Poller\n[*] --> Polling\nPolling --> Sleeping\nSleeping --> Polling: after(10s)\n@enduml\n",
+			wantErr:  "Sleeping --> Polling: after(10s) must end the machine in an operator",
+		},
+		{
+			name:     "Operator ending a composite state",
+			operator: true,
+			data:     "@startuml\ntitle Poller\n[*] --> Polling\nstate Polling {\n[*] --> Sleeping\nSleeping --> [*]: after(1m0s)\n}\nPolling --> [*]\n@enduml\n",
+			wantErr:  "Sleeping --> FinalState: after(1m0s) must end the machine in an operator",
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := &generator.Generator{FSM: uml.Parse(tt.data), Operator: tt.operator}
+
+			err := g.Check()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.ErrorIs(t, err, generator.ErrUnsupported)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,247 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+)
+
+// The naming schemes of the state, action and guard constants.
+const (
+	// NamingPlain uses the names from the chart as they are, e.g. Loading.
+	NamingPlain = "plain"
+	// NamingQualified prefixes the names with their kind, e.g. StateLoading,
+	// ActionLoad and GuardIsError.
+	NamingQualified = "qualified"
+)
+
+// ErrInvalidNames is returned when the names in the chart don't make valid Go.
+var ErrInvalidNames = errors.New("the chart does not generate valid Go")
+
+// identifier returns the Go identifier of a state, action or guard constant in
+// the naming scheme of the generator. The initial and final states are part
+// of the engine, and keep their names.
+func (g *Generator) identifier(kind, name string) string {
+	if g.Naming != NamingQualified || name == uml.InitialState || name == uml.FinalState {
+		return name
+	}
+
+	r, size := utf8.DecodeRuneInString(name)
+
+	return strings.ToUpper(kind[:1]) + kind[1:] + string(unicode.ToUpper(r)) + name[size:]
+}
+
+// Validate checks that the names in the chart make valid Go identifiers, and
+// that they don't collide with each other or with the identifiers of the
+// generated code.
+func (g *Generator) Validate() error {
+	reserved, members, err := g.reservedIdentifiers()
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+
+	seen := map[string]uml.Name{}
+
+	for _, name := range g.FSM.Names() {
+		ident := name.Value
+		if name.Kind == uml.KindState || name.Kind == uml.KindAction || name.Kind == uml.KindGuard {
+			ident = g.identifier(name.Kind, name.Value)
+		}
+
+		switch {
+		case !token.IsIdentifier(ident):
+			problems = append(problems, fmt.Sprintf("%s %s is not a valid Go identifier", name.Kind, name.Value))
+
+			continue
+		case name.Kind == uml.KindState && (ident == uml.InitialState || ident == uml.FinalState):
+			continue
+		case reserved[ident]:
+			problems = append(problems, fmt.Sprintf("%s %s collides with the generated %s", name.Kind, name.Value, ident))
+		}
+
+		if other, ok := seen[ident]; ok {
+			problems = append(problems, fmt.Sprintf("%s %s and %s %s are both named %s",
+				other.Kind, other.Value, name.Kind, name.Value, ident))
+		}
+
+		seen[ident] = name
+
+		// The actions and guards are methods on the state machine
+		method := ""
+
+		switch name.Kind {
+		case uml.KindAction:
+			method = name.Value + "Action"
+		case uml.KindGuard:
+			method = name.Value + "Guard"
+		}
+
+		switch {
+		case method == "":
+		case !token.IsIdentifier(method):
+			problems = append(problems, fmt.Sprintf("%s %s makes the invalid method name %s", name.Kind, name.Value, method))
+		case !token.IsExported(method):
+			// The generated tests and fakes live in an external test package
+			problems = append(problems, fmt.Sprintf("%s %s must start with an upper case letter", name.Kind, name.Value))
+		case members[method]:
+			problems = append(problems, fmt.Sprintf("%s %s collides with the generated method %s", name.Kind, name.Value, method))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidNames, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// reservedIdentifiers returns the package level identifiers of the generated
+// code, and the fields and methods of the state machine, not coming from the
+// chart. They are found by generating the code for an empty chart with all
+// engines, for both applications and operators, and the optional files
+// enabled in the generator.
+func (g *Generator) reservedIdentifiers() (map[string]bool, map[string]bool, error) {
+	const title = "Reserved"
+
+	empty := *g
+	empty.FSM = uml.Parse("title " + title + "\n[*] --> " + title + "State\n")
+	empty.Interfaces = true
+	empty.Naming = NamingPlain
+	empty.Package = "reserved"
+	empty.Module = "reserved"
+	empty.Group = "reserved"
+	empty.APIKind = "Kind"
+	empty.APIVersion = "v1"
+
+	files := []string{
+		"statemachine.go",
+		"statemachine_runtime.go",
+		"statemachine_table.go",
+		"statemachine_coverage.go",
+		"extendedstate.go",
+	}
+
+	if g.DebugHandler {
+		files = append(files, "statemachine_debug.go")
+	}
+
+	if g.Metrics {
+		files = append(files, "statemachine_metrics.go")
+	}
+
+	if g.OTel {
+		files = append(files, "statemachine_otel.go")
+	}
+
+	if g.Supervisor {
+		files = append(files, "statemachine_supervisor.go")
+	}
+
+	reserved := map[string]bool{}
+	members := map[string]bool{}
+
+	for _, variant := range []string{"application", "operator"} {
+		for _, file := range files {
+			code, err := empty.ExecuteTemplate(filepath.Join("templates", variant, file+".tmpl"))
+			if err != nil {
+				return nil, nil, err
+			}
+
+			node, err := parser.ParseFile(token.NewFileSet(), file, code, parser.SkipObjectResolution)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+
+			collectReserved(node, title, reserved, members)
+		}
+	}
+
+	for _, name := range empty.FSM.Names() {
+		delete(reserved, name.Value)
+	}
+
+	return reserved, members, nil
+}
+
+// collectReserved adds the package level identifiers of the file to reserved,
+// and the fields and methods of the state machine type to members.
+func collectReserved(node *ast.File, title string, reserved, members map[string]bool) {
+	for _, decl := range node.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil {
+				reserved[decl.Name.Name] = true
+			} else if receiverName(decl.Recv.List[0].Type) == title {
+				members[decl.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					reserved[spec.Name.Name] = true
+
+					if structType, ok := spec.Type.(*ast.StructType); ok && spec.Name.Name == title {
+						for _, field := range structType.Fields.List {
+							for _, name := range field.Names {
+								members[name.Name] = true
+							}
+
+							if len(field.Names) == 0 {
+								members[receiverName(field.Type)] = true
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						reserved[name.Name] = true
+					}
+				}
+			}
+		}
+	}
+}
+
+// receiverName returns the name of a, possibly pointer or qualified, type.
+func receiverName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	case *ast.Ident:
+		return expr.Name
+	default:
+		return ""
+	}
+}
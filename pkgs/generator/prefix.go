@@ -0,0 +1,277 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+)
+
+// ApplyPrefix adds the prefix of the generator to the package level
+// identifiers declared in the generated files, and renames all references to
+// them, so several state machines can be generated into the same package.
+// Constructors, options, sentinel errors and tests keep their leading verb,
+// e.g. New becomes NewSession and ErrNoTransition becomes
+// ErrSessionNoTransition. The
+// files are updated in place.
+func (g *Generator) ApplyPrefix(files map[string][]byte) error {
+	if g.Prefix == "" {
+		return nil
+	}
+
+	// Iterate the files in a stable order to keep the output reproducible.
+	filenames := make([]string, 0, len(files))
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+
+	sort.Strings(filenames)
+
+	nodes := make(map[string]*dst.File, len(files))
+
+	for _, filename := range filenames {
+		node, err := decorator.Parse(files[filename])
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+
+		nodes[filename] = node
+	}
+
+	names := map[string]string{}
+	for _, filename := range filenames {
+		g.collectNames(nodes[filename], names)
+	}
+
+	for _, filename := range filenames {
+		node := nodes[filename]
+
+		renameDocs(node, names)
+		renameIdents(node, names, g.Package)
+
+		var buf bytes.Buffer
+		if err := decorator.Fprint(&buf, node); err != nil {
+			return fmt.Errorf("failed to print %s: %w", filename, err)
+		}
+
+		files[filename] = buf.Bytes()
+	}
+
+	return nil
+}
+
+// collectNames adds the package level identifiers declared in the file, and
+// their prefixed names, to names.
+func (g *Generator) collectNames(node *dst.File, names map[string]string) {
+	add := func(name string, verbs ...string) {
+		if name == "_" {
+			return
+		}
+
+		names[name] = g.prefixed(name, verbs...)
+	}
+
+	for _, decl := range node.Decls {
+		switch decl := decl.(type) {
+		case *dst.FuncDecl:
+			// The functions with a meaning to the go tool must keep their names
+			if decl.Recv != nil || slices.Contains([]string{"init", "main", "TestMain"}, decl.Name.Name) {
+				continue
+			}
+
+			add(decl.Name.Name, "New", "With", "new", "Test", "Benchmark", "Example", "Fuzz")
+		case *dst.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *dst.TypeSpec:
+					add(spec.Name.Name)
+				case *dst.ValueSpec:
+					for _, name := range spec.Names {
+						if decl.Tok == token.VAR {
+							add(name.Name, "Err")
+						} else {
+							add(name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// prefixed returns the name with the prefix added. If the name starts with
+// one of the verbs, the prefix is added after it, e.g. NewSession.
+func (g *Generator) prefixed(name string, verbs ...string) string {
+	for _, verb := range verbs {
+		rest, ok := strings.CutPrefix(name, verb)
+		if ok && (rest == "" || rest[0] == '_' || unicode.IsUpper(rune(rest[0]))) {
+			return verb + g.Prefix + rest
+		}
+	}
+
+	if unicode.IsLower(rune(name[0])) {
+		return strings.ToLower(g.Prefix[:1]) + g.Prefix[1:] + strings.ToUpper(name[:1]) + name[1:]
+	}
+
+	return g.Prefix + name
+}
+
+// renameIdents renames the identifiers in the file referring to the package
+// level declarations in names. Struct fields, methods, local variables and
+// selectors on anything but the package itself are left alone.
+func renameIdents(node *dst.File, names map[string]string, pkg string) {
+	skip := map[*dst.Ident]bool{}
+	qualified := map[*dst.Ident]bool{}
+	litTypes := map[*dst.CompositeLit]dst.Expr{}
+
+	dst.Inspect(node, func(n dst.Node) bool {
+		switch n := n.(type) {
+		case *dst.FuncDecl:
+			if n.Recv != nil {
+				skip[n.Name] = true
+			}
+		case *dst.Field:
+			for _, name := range n.Names {
+				skip[name] = true
+			}
+		case *dst.SelectorExpr:
+			if x, ok := n.X.(*dst.Ident); ok && x.Obj == nil && x.Name == pkg {
+				qualified[n.Sel] = true
+			} else {
+				skip[n.Sel] = true
+			}
+		case *dst.CompositeLit:
+			typ := n.Type
+			if typ == nil {
+				typ = litTypes[n]
+			}
+
+			for _, elt := range n.Elts {
+				value := elt
+				if kv, ok := elt.(*dst.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*dst.Ident); ok && isStruct(typ) {
+						skip[key] = true
+					}
+
+					value = kv.Value
+				}
+
+				if lit, ok := value.(*dst.CompositeLit); ok && lit.Type == nil {
+					litTypes[lit] = elemType(typ)
+				}
+			}
+		}
+
+		return true
+	})
+
+	dst.Inspect(node, func(n dst.Node) bool {
+		ident, ok := n.(*dst.Ident)
+		if !ok || skip[ident] {
+			return true
+		}
+
+		name, ok := names[ident.Name]
+		if !ok {
+			return true
+		}
+
+		// Only rename references to the package level declaration, not to
+		// local variables shadowing it.
+		if qualified[ident] || ident.Obj == nil || node.Scope.Lookup(ident.Name) == ident.Obj {
+			ident.Name = name
+		}
+
+		return true
+	})
+}
+
+// isStruct reports if a composite literal of the type has struct fields as
+// keys. Maps, slices and arrays are keyed by values.
+func isStruct(typ dst.Expr) bool {
+	switch typ.(type) {
+	case *dst.MapType, *dst.ArrayType:
+		return false
+	default:
+		return true
+	}
+}
+
+// elemType returns the type of the elements of a map, slice or array type.
+func elemType(typ dst.Expr) dst.Expr {
+	switch typ := typ.(type) {
+	case *dst.MapType:
+		return typ.Value
+	case *dst.ArrayType:
+		return typ.Elt
+	case *dst.StarExpr:
+		return elemType(typ.X)
+	default:
+		return nil
+	}
+}
+
+// renameDocs renames the declarations at the start of their doc comments.
+func renameDocs(node *dst.File, names map[string]string) {
+	rename := func(decs dst.Decorations, name string) {
+		for i, line := range decs {
+			newName, found := names[name]
+			rest, ok := strings.CutPrefix(line, "// "+name+" ")
+
+			if found && ok {
+				decs[i] = "// " + newName + " " + rest
+
+				return
+			}
+		}
+	}
+
+	for _, decl := range node.Decls {
+		switch decl := decl.(type) {
+		case *dst.FuncDecl:
+			if decl.Recv == nil {
+				rename(decl.Decs.Start, decl.Name.Name)
+			}
+		case *dst.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *dst.TypeSpec:
+					rename(decl.Decs.Start, spec.Name.Name)
+					rename(spec.Decs.Start, spec.Name.Name)
+				case *dst.ValueSpec:
+					for _, name := range spec.Names {
+						rename(decl.Decs.Start, name.Name)
+						rename(spec.Decs.Start, name.Name)
+					}
+				}
+			}
+		}
+	}
+}
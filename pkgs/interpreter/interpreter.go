@@ -0,0 +1,476 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+// Package interpreter runs a chart without generating code. The chart is parsed
+// when the machine is created, the actions, guards and errors it names are bound
+// to the functions in a Registry, and the machine is run by the engine of the
+// runtime package, with the same semantics as the generated state machines.
+// The chart can be reloaded while the machine is running, to change its wiring
+// without rebuilding the program.
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mhersson/vectorsigma/pkgs/runtime"
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+)
+
+// ErrNoFile is returned when reloading a machine that was not loaded from a file.
+var ErrNoFile = errors.New("machine not loaded from a file")
+
+// UnregisteredError is returned when a chart names actions, guards or errors
+// that are not in the Registry.
+type UnregisteredError struct {
+	Names []string // Like "action LoadObjects", sorted
+}
+
+func (e *UnregisteredError) Error() string {
+	return "unregistered names in chart: " + strings.Join(e.Names, ", ")
+}
+
+// chart is a parsed chart bound to the functions of a Registry.
+type chart struct {
+	title   string
+	configs map[runtime.StateName]runtime.StateConfig
+}
+
+// host gives the engine access to the logger and the error of the machine.
+type host struct {
+	logger *slog.Logger
+	err    error
+}
+
+func (h *host) Logger() runtime.Logger { return h.logger }
+
+func (h *host) Err() error { return h.err }
+
+func (h *host) SetErr(err error) { h.err = err }
+
+// Machine is a state machine running an interpreted chart. The engine is the
+// embedded runtime.Machine.
+type Machine struct {
+	runtime.Machine
+	Title    string // The title of the chart
+	registry *Registry
+	path     string // The file the chart was loaded from, empty if none
+	host     host
+	idle     bool // True between runs, when a reloaded chart can be applied
+
+	mu      sync.Mutex
+	pending *chart // The reloaded chart, applied when the next run starts
+	source  string // The source of the last chart loaded
+}
+
+// Option configures the machine created by New or Load.
+type Option func(*Machine)
+
+// WithLogger sets the logger used by the machine.
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Machine) {
+		m.host.logger = logger
+	}
+}
+
+// WithObserver adds an observer to the machine.
+func WithObserver(observer runtime.Observer) Option {
+	return func(m *Machine) {
+		m.Observers = append(m.Observers, observer)
+	}
+}
+
+// WithTracer sets the tracer starting the spans of the machine.
+func WithTracer(tracer runtime.Tracer) Option {
+	return func(m *Machine) {
+		m.Tracer = tracer
+	}
+}
+
+// WithTrace records a trace of each step to w, as lines of JSON.
+func WithTrace(w io.Writer) Option {
+	return WithObserver(runtime.NewTraceRecorder(w))
+}
+
+// WithMaxSteps limits the number of steps in a single run.
+func WithMaxSteps(maxSteps int) Option {
+	return func(m *Machine) {
+		m.MaxSteps = maxSteps
+	}
+}
+
+// WithClock sets the clock used to wait between retries, and for the timeouts
+// and the time transitions.
+func WithClock(clock runtime.Clock) Option {
+	return func(m *Machine) {
+		m.Clock = clock
+	}
+}
+
+// New returns a machine running the PlantUML chart, with the actions, guards
+// and errors bound from registry. It fails if the chart names anything that is
+// not registered.
+func New(source string, registry *Registry, opts ...Option) (*Machine, error) {
+	m := &Machine{
+		Machine: runtime.Machine{
+			CurrentState: runtime.InitialState,
+			Clock:        runtime.RealClock{},
+		},
+		registry: registry,
+		host:     host{logger: slog.New(slog.NewJSONHandler(os.Stdout, nil))},
+		idle:     true,
+	}
+	m.Host = &m.host
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	parsed, err := bind(source, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	m.apply(parsed)
+	m.source = source
+
+	return m, nil
+}
+
+// Load returns a machine running the chart in the file at path, which can also
+// be a markdown file containing a plantuml code block. The machine can reload
+// the chart from the file with Reload and Watch.
+func Load(path string, registry *Registry, opts ...Option) (*Machine, error) {
+	source, err := readChart(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := New(source, registry, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	m.path = path
+
+	return m, nil
+}
+
+// Update replaces the chart of the machine. The chart is bound at once, and an
+// error is returned with the machine keeping its chart if it fails, but the
+// machine only changes to it when the next run starts. Update is safe to call
+// while the machine is running.
+func (m *Machine) Update(source string) error {
+	parsed, err := bind(source, m.registry)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending = parsed
+	m.source = source
+
+	return nil
+}
+
+// Reload reads the chart again from the file the machine was loaded from, and
+// updates the machine with it.
+func (m *Machine) Reload() error {
+	if m.path == "" {
+		return ErrNoFile
+	}
+
+	source, err := readChart(m.path)
+	if err != nil {
+		return err
+	}
+
+	return m.Update(source)
+}
+
+// Run steps the machine until it reaches the FinalState or an end state, and
+// returns the outcome naming the final state the machine ended in.
+func (m *Machine) Run() (runtime.Outcome, error) {
+	return m.RunContext(context.Background())
+}
+
+// RunContext is Run with a context, which is passed to the actions through
+// ActionContext, and carries the parent span of the steps.
+func (m *Machine) RunContext(ctx context.Context) (runtime.Outcome, error) {
+	m.applyPending()
+
+	m.idle = false
+	err := m.Machine.RunContext(ctx)
+	m.idle = true
+
+	if err != nil {
+		return runtime.Outcome{}, err
+	}
+
+	return m.Outcome(), m.host.err
+}
+
+// Step executes a single step of the machine. A machine driven by Step changes
+// to an updated chart before the first step, and after a step that ends it.
+func (m *Machine) Step(ctx context.Context) (runtime.StepResult, error) {
+	if m.idle {
+		m.applyPending()
+	}
+
+	result, err := m.Machine.Step(ctx)
+	m.idle = result.Done
+
+	return result, err
+}
+
+// Err returns the error of the last action that failed.
+func (m *Machine) Err() error {
+	return m.host.err
+}
+
+// Describe returns the structure of the machine, and the state it is in. It
+// must not be called while the machine is stepping, but can be called from an
+// Observer.
+func (m *Machine) Describe() runtime.Description {
+	description := m.Machine.Describe()
+	description.Title = m.Title
+
+	return description
+}
+
+// applyPending changes the machine to the chart of the last update, if any.
+func (m *Machine) applyPending() {
+	m.mu.Lock()
+	parsed := m.pending
+	m.pending = nil
+	m.mu.Unlock()
+
+	if parsed != nil {
+		m.apply(parsed)
+		m.host.logger.Debug("chart reloaded", "title", parsed.title)
+	}
+}
+
+func (m *Machine) apply(parsed *chart) {
+	m.Title = parsed.title
+	m.StateConfigs = parsed.configs
+
+	if _, ok := m.StateConfigs[m.CurrentState]; !ok && m.CurrentState != runtime.FinalState {
+		// The state the machine stopped in was removed from the chart
+		m.CurrentState = runtime.InitialState
+	}
+}
+
+// readChart reads the chart in the file at path, which can also be a markdown
+// file containing a plantuml code block, with the included charts inlined.
+func readChart(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read chart: %w", err)
+	}
+
+	source := string(content)
+
+	if filepath.Ext(path) == ".md" {
+		var err error
+		if source, err = uml.ExtractFromMarkdown(source); err != nil {
+			return "", err
+		}
+	}
+
+	return uml.ResolveIncludes(source, path, os.ReadFile)
+}
+
+// bind parses the chart, and builds the state configs of the engine with the
+// functions in registry.
+func bind(source string, registry *Registry) (*chart, error) {
+	fsm := uml.Parse(source)
+
+	if _, ok := fsm.States[uml.InitialState]; !ok {
+		return nil, errors.New("chart has no initial state")
+	}
+
+	b := binder{registry: registry}
+	configs := b.stateConfigs(fsm.States)
+
+	if len(b.missing) > 0 {
+		slices.Sort(b.missing)
+
+		return nil, &UnregisteredError{Names: b.missing}
+	}
+
+	return &chart{title: fsm.Title, configs: configs}, nil
+}
+
+// binder builds the state configs of a chart, and collects the names that are
+// not in the registry.
+type binder struct {
+	registry *Registry
+	missing  []string
+}
+
+func (b *binder) unregistered(kind, name string) {
+	if name := kind + " " + name; !slices.Contains(b.missing, name) {
+		b.missing = append(b.missing, name)
+	}
+}
+
+func (b *binder) stateConfigs(states map[string]*uml.State) map[runtime.StateName]runtime.StateConfig {
+	configs := make(map[runtime.StateName]runtime.StateConfig, len(states))
+
+	for name, state := range states {
+		if name == uml.FinalState {
+			continue
+		}
+
+		configs[runtime.StateName(name)] = b.stateConfig(state)
+	}
+
+	return configs
+}
+
+func (b *binder) stateConfig(state *uml.State) runtime.StateConfig {
+	config := runtime.StateConfig{
+		Transitions:   make(map[int]runtime.StateName, len(state.Transitions)),
+		Timeout:       state.Timeout,
+		TimeoutTarget: runtime.StateName(state.TimeoutTarget),
+		End:           state.End,
+	}
+
+	for _, action := range state.Actions {
+		bound := b.action(action)
+
+		if action.Retry != nil {
+			bound.Retry = &runtime.RetryPolicy{
+				Max:     action.Retry.Max,
+				Backoff: runtime.Backoff(action.Retry.Backoff),
+				Base:    action.Retry.Base,
+			}
+		}
+
+		bound.Group = action.Group
+		bound.Timeout = action.Timeout
+
+		config.Actions = append(config.Actions, bound)
+	}
+
+	// The guarded transitions come first, and the guard of each is at the index
+	// of the transition
+	for i, transition := range state.Transitions {
+		config.Transitions[i] = runtime.StateName(transition.Target)
+
+		if transition.Guard == "" {
+			continue
+		}
+
+		check, ok := b.registry.guard(transition.Guard)
+		if !ok {
+			b.unregistered(uml.KindGuard, transition.Guard)
+		}
+
+		guard := runtime.Guard{
+			Name:   runtime.GuardName(transition.Guard),
+			Params: params(transition.GuardParams),
+			Check:  check,
+		}
+
+		if transition.Action != nil {
+			action := b.action(*transition.Action)
+			guard.Action = &action
+		}
+
+		config.Guards = append(config.Guards, guard)
+	}
+
+	for _, transition := range state.ErrorTransitions {
+		bound := runtime.ErrorTransition{
+			Error:  transition.Error,
+			Match:  runtime.AnyError,
+			Target: runtime.StateName(transition.Target),
+		}
+
+		if transition.Error != "" {
+			match, ok := b.registry.errorMatcher(transition.Error)
+			if !ok {
+				b.unregistered(uml.KindError, transition.Error)
+			}
+
+			bound.Match = match
+		}
+
+		config.ErrorTransitions = append(config.ErrorTransitions, bound)
+	}
+
+	for _, transition := range state.TimeTransitions {
+		config.TimeTransitions = append(config.TimeTransitions, runtime.TimeTransition{
+			After:  transition.After,
+			At:     transition.At,
+			Target: runtime.StateName(transition.Target),
+		})
+	}
+
+	if state.Compensation != nil {
+		compensation := b.action(*state.Compensation)
+		config.Compensation = &compensation
+	}
+
+	if state.Composite.InitialState != "" {
+		config.Composite = runtime.CompositeState{
+			InitialState: runtime.StateName(state.Composite.InitialState),
+			StateConfigs: b.stateConfigs(state.Composite.States),
+		}
+	}
+
+	return config
+}
+
+// action binds the name and the parameters of the action.
+func (b *binder) action(action uml.Action) runtime.Action {
+	execute, ok := b.registry.action(action.Name)
+	if !ok {
+		b.unregistered(uml.KindAction, action.Name)
+	}
+
+	return runtime.Action{
+		Name:    runtime.ActionName(action.Name),
+		Params:  params(action.Params),
+		Execute: execute,
+	}
+}
+
+// params splits the parameters of an action or a guard, as quoted by the parser.
+func params(quoted string) []string {
+	if quoted == "" {
+		return nil
+	}
+
+	return strings.Split(strings.Trim(quoted, `"`), `","`)
+}
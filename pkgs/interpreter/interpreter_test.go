@@ -0,0 +1,463 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package interpreter_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mhersson/vectorsigma/pkgs/interpreter"
+	"github.com/mhersson/vectorsigma/pkgs/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTimeout = errors.New("timeout")
+
+type authError struct{}
+
+func (authError) Error() string { return "unauthorized" }
+
+const orders = `@startuml
+title Orders
+[*] --> Loading
+Loading: do / LoadObjects(orders, open)
+Loading --> Shipping: [ IsReady ]
+Loading --> Waiting
+Waiting: do / Wait
+Waiting --> [*]
+Shipping: do / Ship
+Shipping --> [*]
+@enduml
+`
+
+// recorder returns a registry whose actions record their names and
+// parameters in calls, and whose guards pass when named in passing.
+func recorder(calls *[]string, passing ...string) *interpreter.Registry {
+	registry := interpreter.NewRegistry()
+
+	for _, name := range []string{"LoadObjects", "Wait", "Ship", "Fetch", "Retry", "Reauthenticate", "Release",
+		"Enter", "Leave"} {
+		registry.RegisterAction(name, func(params ...string) error {
+			call := name
+			for _, param := range params {
+				call += " " + param
+			}
+
+			*calls = append(*calls, call)
+
+			return nil
+		})
+	}
+
+	for _, name := range []string{"IsReady", "IsError"} {
+		registry.RegisterGuard(name, func(...string) bool {
+			for _, pass := range passing {
+				if pass == name {
+					return true
+				}
+			}
+
+			return false
+		})
+	}
+
+	registry.RegisterError("ErrTimeout", runtime.IsError(errTimeout))
+	registry.RegisterError("*authError", runtime.AsError[authError])
+
+	return registry
+}
+
+func discard() interpreter.Option {
+	return interpreter.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		chart   string
+		missing []string
+		wantErr bool
+	}{
+		{
+			name:  "all names registered",
+			chart: orders,
+		},
+		{
+			name: "unregistered names",
+			chart: `@startuml
+title Broken
+[*] --> Loading
+Loading: do / LoadObjects & Count
+Loading --> Counted: [ IsCounted ] :: Report
+Loading --> Failed: on error(ErrMissing)
+Loading --> Denied: on error(*AccessError)
+Loading --> [*]
+state Counted {
+  [*] --> Checking
+  Checking: do / Check
+  Checking --> [*]
+}
+Counted --> [*]
+Failed: compensate / Undo
+Failed --> [*]
+Denied --> [*]
+@enduml
+`,
+			missing: []string{"action Check", "action Count", "action Report", "action Undo", "error *AccessError",
+				"error ErrMissing", "guard IsCounted"},
+		},
+		{
+			name:    "no initial state",
+			chart:   "@startuml\ntitle Empty\n@enduml\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+
+			m, err := interpreter.New(tt.chart, recorder(&calls), discard())
+
+			var unregistered *interpreter.UnregisteredError
+
+			switch {
+			case tt.missing != nil:
+				require.ErrorAs(t, err, &unregistered)
+				assert.Equal(t, tt.missing, unregistered.Names)
+				assert.Nil(t, m)
+			case tt.wantErr:
+				require.Error(t, err)
+				assert.Nil(t, m)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, "Orders", m.Describe().Title)
+			}
+		})
+	}
+}
+
+func TestMachine_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		chart   string
+		passing []string
+		failing map[string]error
+		want    []string
+		outcome runtime.StateName
+		wantErr error
+	}{
+		{
+			name:    "unguarded transition",
+			chart:   orders,
+			want:    []string{"LoadObjects orders open", "Wait"},
+			outcome: runtime.FinalState,
+		},
+		{
+			name:    "guarded transition",
+			chart:   orders,
+			passing: []string{"IsReady"},
+			want:    []string{"LoadObjects orders open", "Ship"},
+			outcome: runtime.FinalState,
+		},
+		{
+			name: "error transitions",
+			chart: `@startuml
+title Fetcher
+[*] --> Fetching
+Fetching: do / Fetch
+Fetching --> Retrying: on error(ErrTimeout)
+Fetching --> Reauthenticating: on error(*authError)
+Fetching --> [*]
+Retrying: do / Retry
+Retrying --> [*]
+Reauthenticating: do / Reauthenticate
+Reauthenticating --> [*]
+@enduml
+`,
+			failing: map[string]error{"Fetch": authError{}},
+			want:    []string{"Fetch", "Reauthenticate"},
+			outcome: runtime.FinalState,
+		},
+		{
+			name: "composite state and end state",
+			chart: `@startuml
+title Nested
+[*] --> Outer
+state Outer {
+  [*] --> Entering
+  Entering: do / Enter(inner)
+  Entering --> [*]
+}
+Outer --> Done
+Done: do / Leave
+state Done <<end>>
+@enduml
+`,
+			want:    []string{"Enter inner"},
+			outcome: "Done",
+		},
+		{
+			name: "compensation on the error path",
+			chart: `@startuml
+title Compensated
+[*] --> Loading
+Loading: do / LoadObjects
+Loading: compensate / Release(all)
+Loading --> Fetching
+Fetching: do / Fetch
+Fetching --> [*]: on error
+Fetching --> Waiting
+Waiting: do / Wait
+Waiting --> [*]
+@enduml
+`,
+			failing: map[string]error{"Fetch": errTimeout},
+			want:    []string{"LoadObjects", "Fetch", "Release all"},
+			outcome: runtime.FinalState,
+		},
+		{
+			name: "failing action without transition",
+			chart: `@startuml
+title Failing
+[*] --> Fetching
+Fetching: do / Fetch
+Fetching --> [*]: [ IsError ]
+Fetching --> Waiting
+Waiting: do / Wait
+Waiting --> [*]
+@enduml
+`,
+			passing: []string{"IsError"},
+			failing: map[string]error{"Fetch": errTimeout},
+			want:    []string{"Fetch"},
+			outcome: runtime.FinalState,
+			wantErr: errTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+
+			registry := recorder(&calls, tt.passing...)
+			for name, err := range tt.failing {
+				registry.RegisterAction(name, func(...string) error {
+					calls = append(calls, name)
+
+					return err
+				})
+			}
+
+			m, err := interpreter.New(tt.chart, registry, discard())
+			require.NoError(t, err)
+
+			outcome, err := m.Run()
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.want, calls)
+			assert.Equal(t, tt.outcome, outcome.State)
+			assert.Equal(t, runtime.InitialState, m.CurrentState)
+		})
+	}
+}
+
+func TestMachine_Update(t *testing.T) {
+	var calls []string
+
+	registry := recorder(&calls)
+
+	// The new chart is applied when the next run starts, not while running
+	var m *interpreter.Machine
+
+	rewired := `@startuml
+title Orders
+[*] --> Loading
+Loading: do / LoadObjects(orders, open)
+Loading --> Shipping
+Shipping: do / Ship
+Shipping --> [*]
+@enduml
+`
+	registry.RegisterAction("Wait", func(...string) error {
+		calls = append(calls, "Wait")
+
+		return m.Update(rewired)
+	})
+
+	m, err := interpreter.New(orders, registry, discard())
+	require.NoError(t, err)
+
+	_, err = m.Run()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"LoadObjects orders open", "Wait"}, calls)
+
+	calls = nil
+	_, err = m.Run()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"LoadObjects orders open", "Ship"}, calls)
+
+	// A chart that fails to bind is rejected, and the machine keeps its chart
+	var unregistered *interpreter.UnregisteredError
+
+	require.ErrorAs(t, m.Update("@startuml\n[*] --> A\nA: do / Unknown\nA --> [*]\n@enduml\n"), &unregistered)
+	assert.Equal(t, []string{"action Unknown"}, unregistered.Names)
+
+	calls = nil
+	_, err = m.Run()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"LoadObjects orders open", "Ship"}, calls)
+
+	require.ErrorIs(t, m.Reload(), interpreter.ErrNoFile)
+}
+
+func TestMachine_Step(t *testing.T) {
+	var calls []string
+
+	m, err := interpreter.New(orders, recorder(&calls), discard())
+	require.NoError(t, err)
+
+	result, err := m.Step(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, runtime.StateName("Loading"), result.NextState)
+
+	require.NoError(t, m.Update(`@startuml
+title Orders
+[*] --> Shipping
+Shipping: do / Ship
+Shipping --> [*]
+@enduml
+`))
+
+	// The machine keeps the chart it started with until it is done
+	for !result.Done {
+		result, err = m.Step(context.Background())
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"LoadObjects orders open", "Wait"}, calls)
+
+	m.CurrentState = runtime.InitialState
+	result, err = m.Step(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, runtime.StateName("Shipping"), result.NextState)
+}
+
+func TestMachine_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.md")
+	require.NoError(t, os.WriteFile(path, []byte("# Orders\n\n```plantuml\n"+orders+"```\n"), 0o600))
+
+	var calls []string
+
+	m, err := interpreter.Load(path, recorder(&calls), discard())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- m.Watch(ctx, 5*time.Millisecond)
+	}()
+
+	// A broken chart is logged and ignored
+	require.NoError(t, os.WriteFile(path, []byte("```plantuml\n[*] --> A\nA: do / Unknown\n```\n"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+
+	_, err = m.Run()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"LoadObjects orders open", "Wait"}, calls)
+
+	require.NoError(t, os.WriteFile(path, []byte("```plantuml\n[*] --> Shipping\nShipping: do / Ship\n"+
+		"Shipping --> [*]\n```\n"), 0o600))
+
+	assert.Eventually(t, func() bool {
+		calls = nil
+		_, err := m.Run()
+
+		return err == nil && len(calls) == 1 && calls[0] == "Ship"
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestMachine_WatchIncludes(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.puml"), []byte(`@startuml
+title Orders
+[*] --> Loading
+state Loading : include loading.puml
+Loading --> [*]
+@enduml
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "loading.puml"), []byte(`@startuml
+[*] --> Fetching
+Fetching: do / LoadObjects(orders)
+Fetching --> [*]
+@enduml
+`), 0o600))
+
+	var calls []string
+
+	m, err := interpreter.Load(filepath.Join(dir, "main.puml"), recorder(&calls), discard())
+	require.NoError(t, err)
+
+	_, err = m.Run()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"LoadObjects orders"}, calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- m.Watch(ctx, 5*time.Millisecond)
+	}()
+
+	// A change of the included chart reloads the chart
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "loading.puml"), []byte(`@startuml
+[*] --> Fetching
+Fetching: do / LoadObjects(orders, open)
+Fetching --> [*]
+@enduml
+`), 0o600))
+
+	assert.Eventually(t, func() bool {
+		calls = nil
+		_, err := m.Run()
+
+		return err == nil && len(calls) == 1 && calls[0] == "LoadObjects orders open"
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package interpreter
+
+import (
+	"sync"
+)
+
+// Registry binds the names used in a chart to the functions implementing them.
+// It is safe for concurrent use, and can be shared by several machines.
+type Registry struct {
+	mu      sync.RWMutex
+	actions map[string]func(...string) error
+	guards  map[string]func(...string) bool
+	errors  map[string]func(error) bool
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		actions: make(map[string]func(...string) error),
+		guards:  make(map[string]func(...string) bool),
+		errors:  make(map[string]func(error) bool),
+	}
+}
+
+// RegisterAction binds the action with the given name in the chart to fn,
+// replacing any action registered with the name.
+func (r *Registry) RegisterAction(name string, fn func(params ...string) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.actions[name] = fn
+}
+
+// RegisterGuard binds the guard with the given name in the chart to fn,
+// replacing any guard registered with the name.
+func (r *Registry) RegisterGuard(name string, fn func(params ...string) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.guards[name] = fn
+}
+
+// RegisterError binds the error with the given name in the error transitions of
+// the chart to a matcher, like runtime.IsError(ErrTimeout) for a sentinel error,
+// or runtime.AsError[*TimeoutError] for an error type.
+func (r *Registry) RegisterError(name string, match func(error) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors[name] = match
+}
+
+func (r *Registry) action(name string) (func(...string) error, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, ok := r.actions[name]
+
+	return fn, ok
+}
+
+func (r *Registry) guard(name string) (func(...string) bool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, ok := r.guards[name]
+
+	return fn, ok
+}
+
+func (r *Registry) errorMatcher(name string) (func(error) bool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	match, ok := r.errors[name]
+
+	return match, ok
+}
@@ -0,0 +1,80 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package interpreter
+
+import (
+	"context"
+	"time"
+)
+
+// Watch checks the file the machine was loaded from every interval, and reloads
+// the chart when the file, or a chart it includes, changes, until ctx is done. A
+// chart that fails to load is logged, and the machine keeps the chart it has.
+// The machine changes to a reloaded chart when its next run starts.
+func (m *Machine) Watch(ctx context.Context, interval time.Duration) error {
+	if m.path == "" {
+		return ErrNoFile
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failed := ""   // The last error reading the chart, logged once
+	rejected := "" // The last chart that failed to load, not loaded again
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		source, err := readChart(m.path)
+		if err != nil {
+			if err.Error() != failed {
+				m.host.logger.Warn("failed to read chart", "path", m.path, "error", err)
+				failed = err.Error()
+			}
+
+			continue
+		}
+
+		failed = ""
+
+		m.mu.Lock()
+		changed := source != m.source
+		m.mu.Unlock()
+
+		if !changed || source == rejected {
+			continue
+		}
+
+		if err := m.Update(source); err != nil {
+			m.host.logger.Error("failed to reload chart", "path", m.path, "error", err)
+			rejected = source
+
+			continue
+		}
+
+		m.host.logger.Info("chart changed", "path", m.path)
+	}
+}
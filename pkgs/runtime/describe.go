@@ -0,0 +1,162 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package runtime
+
+import (
+	"slices"
+	"time"
+)
+
+// Description describes the structure of a state machine and the state it is
+// in. It holds no functions, and can be serialized to show the machine in
+// other tools.
+type Description struct {
+	Title   string    `json:"title"`
+	Current StateName `json:"current"`
+	// Composites holds the composite states the machine is in, outermost first
+	Composites []StateName        `json:"composites,omitempty"`
+	States     []StateDescription `json:"states"`
+}
+
+// StateDescription describes a state, and the states inside it when it is a
+// composite state.
+type StateDescription struct {
+	Name         StateName               `json:"name"`
+	Actions      []ActionDescription     `json:"actions,omitempty"`
+	Transitions  []TransitionDescription `json:"transitions,omitempty"`
+	Timeout      time.Duration           `json:"timeout,omitempty"`
+	Compensation *ActionDescription      `json:"compensation,omitempty"`
+	End          bool                    `json:"end,omitempty"`
+	States       []StateDescription      `json:"states,omitempty"`
+}
+
+// ActionDescription describes an action and its parameters.
+type ActionDescription struct {
+	Name   ActionName `json:"name"`
+	Params []string   `json:"params,omitempty"`
+}
+
+// TransitionDescription describes a transition, in the order the transitions
+// of a state are tried. Event is the label of the transitions that are not
+// guarded, like "on error(ErrTimeout)", "on timeout" or "after(10s)".
+type TransitionDescription struct {
+	Target      StateName          `json:"target"`
+	Guard       GuardName          `json:"guard,omitempty"`
+	GuardParams []string           `json:"guardParams,omitempty"`
+	Action      *ActionDescription `json:"action,omitempty"`
+	Event       string             `json:"event,omitempty"`
+}
+
+// Describe returns the structure of the state machine, and the state it is in.
+// It must not be called while the machine is stepping, but can be called from
+// an Observer.
+func (m *Machine) Describe() Description {
+	description := Description{Current: m.CurrentState, States: describeStates(m.StateConfigs)}
+
+	for _, frame := range m.stack {
+		description.Composites = append(description.Composites, frame.state)
+	}
+
+	return description
+}
+
+// describeStates describes the states of configs, ordered by name.
+func describeStates(configs map[StateName]StateConfig) []StateDescription {
+	if len(configs) == 0 {
+		return nil
+	}
+
+	names := make([]StateName, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	states := make([]StateDescription, 0, len(names))
+
+	for _, name := range names {
+		config := configs[name]
+		state := StateDescription{
+			Name:         name,
+			Timeout:      config.Timeout,
+			Compensation: describeAction(config.Compensation),
+			End:          config.End,
+			States:       describeStates(config.Composite.StateConfigs),
+		}
+
+		for i := range config.Actions {
+			state.Actions = append(state.Actions, *describeAction(&config.Actions[i]))
+		}
+
+		state.Transitions = describeTransitions(config)
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// describeTransitions describes the transitions of a state, guarded first.
+func describeTransitions(config StateConfig) []TransitionDescription {
+	var transitions []TransitionDescription
+
+	for i, guard := range config.Guards {
+		transitions = append(transitions, TransitionDescription{
+			Target:      config.Transitions[i],
+			Guard:       guard.Name,
+			GuardParams: guard.Params,
+			Action:      describeAction(guard.Action),
+		})
+	}
+
+	if target, ok := config.Transitions[len(config.Guards)]; ok {
+		transitions = append(transitions, TransitionDescription{Target: target})
+	}
+
+	for _, transition := range config.ErrorTransitions {
+		event := "on error"
+		if transition.Error != "" {
+			event += "(" + transition.Error + ")"
+		}
+
+		transitions = append(transitions, TransitionDescription{Target: transition.Target, Event: event})
+	}
+
+	if config.TimeoutTarget != "" {
+		transitions = append(transitions, TransitionDescription{Target: config.TimeoutTarget, Event: "on timeout"})
+	}
+
+	for _, transition := range config.TimeTransitions {
+		transitions = append(transitions, TransitionDescription{Target: transition.Target, Event: transition.Event()})
+	}
+
+	return transitions
+}
+
+// describeAction describes an action, and returns nil for a nil action.
+func describeAction(action *Action) *ActionDescription {
+	if action == nil {
+		return nil
+	}
+
+	return &ActionDescription{Name: action.Name, Params: action.Params}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package runtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mhersson/vectorsigma/pkgs/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachine_Describe(t *testing.T) {
+	m := &runtime.Machine{
+		CurrentState: runtime.InitialState,
+		Host:         &host{},
+		StateConfigs: map[runtime.StateName]runtime.StateConfig{
+			runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Fetching"}},
+			"Fetching": {
+				Actions: []runtime.Action{{Name: "Fetch", Params: []string{"users"}, Execute: failing(0, nil)}},
+				Guards: []runtime.Guard{{
+					Name:   "IsReady",
+					Check:  check(false),
+					Action: &runtime.Action{Name: "Store", Execute: failing(0, nil)},
+				}},
+				Transitions:      map[int]runtime.StateName{0: "Working", 1: "Working"},
+				ErrorTransitions: []runtime.ErrorTransition{{Error: "ErrTimeout", Target: "Failed"}, {Target: "Failed"}},
+				Timeout:          time.Minute,
+				TimeoutTarget:    "Failed",
+				TimeTransitions:  []runtime.TimeTransition{{After: 10 * time.Second, Target: "Fetching"}, {At: "07:30", Target: "Failed"}},
+				Compensation:     &runtime.Action{Name: "Forget", Execute: failing(0, nil)},
+			},
+			"Working": {
+				Transitions: map[int]runtime.StateName{0: runtime.FinalState},
+				Composite: runtime.CompositeState{
+					InitialState: runtime.InitialState,
+					StateConfigs: map[runtime.StateName]runtime.StateConfig{
+						runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Inner"}},
+						"Inner":              {Transitions: map[int]runtime.StateName{0: runtime.FinalState}},
+					},
+				},
+			},
+			"Failed": {End: true},
+		},
+	}
+
+	want := runtime.Description{
+		Current: runtime.InitialState,
+		States: []runtime.StateDescription{
+			{Name: "Failed", End: true},
+			{
+				Name:    "Fetching",
+				Actions: []runtime.ActionDescription{{Name: "Fetch", Params: []string{"users"}}},
+				Transitions: []runtime.TransitionDescription{
+					{Target: "Working", Guard: "IsReady", Action: &runtime.ActionDescription{Name: "Store"}},
+					{Target: "Working"},
+					{Target: "Failed", Event: "on error(ErrTimeout)"},
+					{Target: "Failed", Event: "on error"},
+					{Target: "Failed", Event: "on timeout"},
+					{Target: "Fetching", Event: "after(10s)"},
+					{Target: "Failed", Event: "at(07:30)"},
+				},
+				Timeout:      time.Minute,
+				Compensation: &runtime.ActionDescription{Name: "Forget"},
+			},
+			{Name: runtime.InitialState, Transitions: []runtime.TransitionDescription{{Target: "Fetching"}}},
+			{
+				Name:        "Working",
+				Transitions: []runtime.TransitionDescription{{Target: runtime.FinalState}},
+				States: []runtime.StateDescription{
+					{Name: runtime.InitialState, Transitions: []runtime.TransitionDescription{{Target: "Inner"}}},
+					{Name: "Inner", Transitions: []runtime.TransitionDescription{{Target: runtime.FinalState}}},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, want, m.Describe())
+
+	// Enter the composite state
+	m.CurrentState = "Working"
+	_, err := m.Step(context.Background())
+	require.NoError(t, err)
+
+	description := m.Describe()
+	assert.Equal(t, runtime.InitialState, description.Current)
+	assert.Equal(t, []runtime.StateName{"Working"}, description.Composites)
+
+	data, err := json.Marshal(description)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"composites":["Working"]`)
+}
@@ -0,0 +1,1274 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package runtime holds the engine of the state machines generated by
+// VectorSigma with the --runtime flag. The generated code only holds the state
+// tables and the bindings to the actions and guards, and the engine is shared
+// by all the state machines importing the package.
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The versions of the generated code supported by the engine. The generated
+// code fails to compile if its version is not in the range.
+const (
+	MinVersion = 1
+	MaxVersion = 8
+)
+
+// EnforceVersion is used by the generated code to verify at compile time that
+// its version is supported by the engine.
+type EnforceVersion uint
+
+type (
+	StateName  string
+	ActionName string
+	GuardName  string
+)
+
+const (
+	InitialState StateName = "InitialState"
+	FinalState   StateName = "FinalState"
+)
+
+const maxStateDepth = 5
+
+// ErrNoTransition is matched by errors returned when the machine gets stuck in a
+// state without any transition that can be taken.
+var ErrNoTransition = errors.New("no transition")
+
+// ErrMaxStepsExceeded is matched by errors returned when a run exceeds MaxSteps.
+var ErrMaxStepsExceeded = errors.New("max steps exceeded")
+
+// Logger is the logger used by the engine. It is implemented by *slog.Logger.
+// Errors are always logged with the error as the value of the "error" key.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Host gives the engine access to the logger, and to the error in the extended
+// state, of the generated state machine.
+type Host interface {
+	Logger() Logger
+	Err() error
+	SetErr(err error)
+}
+
+// Action represents a function that can be executed in a state and may return an error.
+type Action struct {
+	Name    ActionName
+	Params  []string
+	Execute func(...string) error
+	Retry   *RetryPolicy  // Retries the action when it fails, nil means no retries
+	Group   int           // Actions with the same non-zero group run concurrently
+	Timeout time.Duration // Cancels the action context of each attempt after the duration, 0 means no limit
+}
+
+// Backoff is the strategy used to compute the delay between retries.
+type Backoff string
+
+const (
+	BackoffConstant    Backoff = "constant"
+	BackoffLinear      Backoff = "linear"
+	BackoffExponential Backoff = "exponential"
+)
+
+// RetryPolicy describes how a failing action is retried before the error is
+// handled by the state machine.
+type RetryPolicy struct {
+	Max     int // The number of retries after the first attempt
+	Backoff Backoff
+	Base    time.Duration // The delay before the first retry
+}
+
+// Delay returns the delay before the given retry, counting from 1. A delay too
+// long for a time.Duration is cut to the longest one.
+func (p *RetryPolicy) Delay(retry int) time.Duration {
+	const maxDelay = time.Duration(1<<63 - 1)
+
+	switch p.Backoff {
+	case BackoffLinear:
+		if p.Base > 0 && time.Duration(retry) > maxDelay/p.Base {
+			return maxDelay
+		}
+
+		return p.Base * time.Duration(retry)
+	case BackoffExponential:
+		shift := min(retry-1, 30)
+		if p.Base > maxDelay>>shift {
+			return maxDelay
+		}
+
+		return p.Base << shift
+	default:
+		return p.Base
+	}
+}
+
+// Clock provides the time to the state machine. Replace it to control the
+// delays between retries, and the timeouts, in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the Clock used when none is set.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time { return time.Now() }
+
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Observer is notified as the state machine makes progress.
+type Observer interface {
+	// ActionAttempted is called after each attempt to execute an action.
+	ActionAttempted(attempt ActionAttempt)
+	// Stepped is called after each call to Step.
+	Stepped(result StepResult, err error)
+}
+
+// RunObserver is an Observer that is also notified when a run starts, to drop
+// what it kept of an earlier run.
+type RunObserver interface {
+	Observer
+	// RunStarted is called when RunContext starts a run.
+	RunStarted()
+}
+
+// Tracer starts the spans of a state machine. The span of a step is a child of
+// the span in the context passed to Step or RunContext, and the spans of the
+// attempts to execute the actions of the step are its children. A Tracer must
+// be safe for concurrent use, as the actions of a state can run concurrently.
+type Tracer interface {
+	// StartState starts the span of a step in state, and returns ctx with the span.
+	StartState(ctx context.Context, state StateName) context.Context
+	// EndState ends the span in ctx with the result of the step. The step
+	// leaving a composite state starts in the end state inside it, and its
+	// result names the composite state as the PreviousState.
+	EndState(ctx context.Context, result StepResult, err error)
+	// StartAction starts the span of an attempt to execute an action, and
+	// returns ctx with the span.
+	StartAction(ctx context.Context, attempt ActionAttempt) context.Context
+	// EndAction ends the span in ctx, with the duration and error of the attempt.
+	EndAction(ctx context.Context, attempt ActionAttempt)
+}
+
+// ActionAttempt describes a single attempt to execute an action.
+type ActionAttempt struct {
+	State    StateName
+	Action   ActionName
+	Attempt  int // Counting from 1
+	Duration time.Duration
+	Err      error
+}
+
+// Guard represents a function that returns a boolean indicating if a transition should occur.
+type Guard struct {
+	Name   GuardName
+	Params []string
+	Check  func(...string) bool
+	Action *Action
+}
+
+// StateConfig holds the actions and guards for a state.
+type StateConfig struct {
+	Actions          []Action
+	Guards           []Guard
+	Transitions      map[int]StateName // Maps guard index to the next state
+	ErrorTransitions []ErrorTransition
+	Composite        CompositeState
+	Timeout          time.Duration // The time the machine may stay in the state, 0 means no limit
+	TimeoutTarget    StateName     // The state entered when the state or one of its actions times out
+	TimeTransitions  []TimeTransition
+	Compensation     *Action // Undoes the actions of the state when the machine ends on an error path
+	End              bool    // The machine ends when it enters the state, like in the FinalState
+}
+
+// TimeTransition moves the machine to Target when no other transition of the
+// state can be taken, a delay after the state was entered, or at a time of day.
+type TimeTransition struct {
+	After  time.Duration
+	At     string // The time of day, like "07:30", empty for a delay
+	Target StateName
+}
+
+// Delay returns the time left before the transition is taken, in a state that
+// was entered at entered.
+func (t TimeTransition) Delay(entered, now time.Time) time.Duration {
+	if t.At == "" {
+		return max(entered.Add(t.After).Sub(now), 0)
+	}
+
+	layout := "15:04"
+	if len(t.At) > len(layout) {
+		layout = "15:04:05"
+	}
+
+	at, err := time.Parse(layout, t.At)
+	if err != nil {
+		return 0
+	}
+
+	// The next time the clock shows the time of day
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), at.Second(), 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next.Sub(now)
+}
+
+// Event returns the time event of the transition as written in the chart, like
+// after(10s) or at(07:30).
+func (t TimeTransition) Event() string {
+	if t.At != "" {
+		return "at(" + t.At + ")"
+	}
+
+	return "after(" + t.After.String() + ")"
+}
+
+// ErrorTransition moves the machine to Target when an action of the state fails
+// with an error accepted by Match.
+type ErrorTransition struct {
+	Error  string // The declared error, empty if all errors are accepted
+	Match  func(error) bool
+	Target StateName
+}
+
+type CompositeState struct {
+	InitialState StateName
+	StateConfigs map[StateName]StateConfig
+}
+
+// GuardResult holds the outcome of a single guard evaluation.
+type GuardResult struct {
+	Name   GuardName `json:"name"`
+	Params []string  `json:"params,omitempty"`
+	Passed bool      `json:"passed"`
+}
+
+// StepResult describes what happened during a single call to Step.
+type StepResult struct {
+	Parent        StateName // The composite state the step was taken in, empty at the top level
+	PreviousState StateName
+	NextState     StateName
+	Guard         GuardName // The guard that fired, empty for unguarded transitions
+	Guards        []GuardResult
+	ActionErrors  []error
+	OnError       bool          // True when an error transition was taken
+	ErrorMatch    string        // The error accepted by the error transition taken, empty for all errors
+	TimedOut      bool          // True when the timeout transition was taken
+	Time          string        // The time event of the time transition taken, like after(10s)
+	Delay         time.Duration // The delay of the time transition taken
+	Done          bool          // True when the machine has reached the FinalState or an end state
+	// Compensations holds the outcome of the compensations run when the
+	// machine reached the FinalState on an error path
+	Compensations []Compensation
+}
+
+// Outcome tells how the machine ended. State is the FinalState when the
+// machine ended in [*], and the end state it entered otherwise.
+type Outcome struct {
+	State StateName
+}
+
+// Compensation is the outcome of the compensating action of a completed state.
+type Compensation struct {
+	State  StateName
+	Action ActionName
+	Err    error
+}
+
+// NoTransitionError is returned when none of the guards of a state fired, and
+// the state has no unguarded transition to fall back on.
+type NoTransitionError struct {
+	State  StateName
+	Guards []GuardResult
+}
+
+func (e *NoTransitionError) Error() string {
+	results := make([]string, 0, len(e.Guards))
+	for _, guard := range e.Guards {
+		results = append(results, fmt.Sprintf("%s=%t", guard.Name, guard.Passed))
+	}
+
+	return fmt.Sprintf("no transition from state %s [%s]", e.State, strings.Join(results, ", "))
+}
+
+func (e *NoTransitionError) Is(target error) bool {
+	return target == ErrNoTransition
+}
+
+// MaxStepsExceededError is returned when a run takes more than MaxSteps steps
+// without reaching the FinalState. Trace holds the states that were visited.
+type MaxStepsExceededError struct {
+	MaxSteps int
+	Trace    []StateName
+}
+
+func (e *MaxStepsExceededError) Error() string {
+	const maxTraceLength = 10
+
+	trace := e.Trace
+	prefix := ""
+
+	if len(trace) > maxTraceLength {
+		trace = trace[len(trace)-maxTraceLength:]
+		prefix = "... -> "
+	}
+
+	states := make([]string, 0, len(trace))
+	for _, state := range trace {
+		states = append(states, string(state))
+	}
+
+	return fmt.Sprintf("max steps (%d) exceeded: %s%s", e.MaxSteps, prefix, strings.Join(states, " -> "))
+}
+
+func (e *MaxStepsExceededError) Is(target error) bool {
+	return target == ErrMaxStepsExceeded
+}
+
+// DeadlineError is returned when an action, or the state running the action,
+// times out. It matches context.DeadlineExceeded.
+type DeadlineError struct {
+	State   StateName
+	Action  ActionName // Empty when the state timed out
+	Timeout time.Duration
+	Err     error // The error returned by the action after its context was canceled
+}
+
+func (e *DeadlineError) Error() string {
+	if e.Action == "" {
+		return fmt.Sprintf("state %s timed out after %s", e.State, e.Timeout)
+	}
+
+	return fmt.Sprintf("action %s in state %s timed out after %s", e.Action, e.State, e.Timeout)
+}
+
+func (e *DeadlineError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DeadlineError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// CompensationError is added to the error of the extended state when a
+// compensating action fails.
+type CompensationError struct {
+	State  StateName
+	Action ActionName
+	Err    error
+}
+
+func (e *CompensationError) Error() string {
+	return fmt.Sprintf("compensation %s of state %s failed: %v", e.Action, e.State, e.Err)
+}
+
+func (e *CompensationError) Unwrap() error {
+	return e.Err
+}
+
+// AnyError accepts all errors.
+func AnyError(error) bool {
+	return true
+}
+
+// IsError returns a matcher accepting errors that wrap the target error.
+func IsError(target error) func(error) bool {
+	return func(err error) bool {
+		return errors.Is(err, target)
+	}
+}
+
+// AsError accepts errors that wrap an error of type T.
+func AsError[T error](err error) bool {
+	var target T
+
+	return errors.As(err, &target)
+}
+
+// compositeFrame holds the composite state being executed while the machine
+// runs its nested states.
+type compositeFrame struct {
+	state    StateName
+	config   StateConfig
+	deadline time.Time // The deadline of the composite state, zero if it has no timeout
+}
+
+// completedState is a state with a compensation, that completed in the
+// current run.
+type completedState struct {
+	state        StateName
+	compensation *Action
+}
+
+// Machine is the engine of a state machine. It is embedded in the generated
+// state machine, which sets the Host and the StateConfigs.
+type Machine struct {
+	CurrentState StateName
+	StateConfigs map[StateName]StateConfig
+	MaxSteps     int   // The maximum number of steps in a single run, 0 means no limit
+	HandledError error // The last error handled by an error transition
+	Clock        Clock
+	Observers    []Observer
+	Tracer       Tracer
+	Host         Host
+	// Requeue is called with the delay of a time transition instead of waiting
+	// for it, when set. The transition is then taken at once.
+	Requeue    func(after time.Duration)
+	stack      []compositeFrame
+	actionCtx  context.Context
+	mu         sync.Mutex // Guards the extended state and the observers while actions run concurrently
+	deadline   time.Time  // The deadline of the state with a timeout the machine is in
+	timedState StateName  // The state the deadline belongs to, empty if none
+	entered    time.Time  // The time the state with time transitions was entered
+	completed  []completedState
+	outcome    Outcome
+}
+
+// Run steps the machine until it reaches the FinalState or an end state, and
+// then resets it to the InitialState. The error in the extended state is left
+// to the generated state machine, and is not returned. How the machine ended
+// is returned by Outcome.
+func (m *Machine) Run() error {
+	return m.RunContext(context.Background())
+}
+
+// RunContext is Run with a context, which is passed to the actions through
+// ActionContext, and carries the parent span of the steps.
+func (m *Machine) RunContext(ctx context.Context) error {
+	m.outcome = Outcome{}
+
+	for _, observer := range m.Observers {
+		if observer, ok := observer.(RunObserver); ok {
+			observer.RunStarted()
+		}
+	}
+
+	var trace []StateName
+	if m.MaxSteps > 0 {
+		trace = append(trace, m.CurrentState)
+	}
+
+	for steps := 0; ; steps++ {
+		if m.MaxSteps > 0 && steps >= m.MaxSteps {
+			err := &MaxStepsExceededError{MaxSteps: m.MaxSteps, Trace: trace}
+			m.Host.Logger().Error("max steps exceeded", "state", m.CurrentState, "error", err)
+
+			m.fail(ctx, err)
+			m.reset()
+
+			return err
+		}
+
+		result, err := m.Step(ctx)
+		if err != nil {
+			m.reset()
+
+			return err
+		}
+
+		if m.MaxSteps > 0 {
+			trace = append(trace, result.NextState)
+		}
+
+		if result.Done {
+			// Reset to the Initial State in case the FSM is run in a loop
+			m.reset()
+
+			return nil
+		}
+	}
+}
+
+// reset moves the machine back to the InitialState when a run ends, also when
+// it fails inside a composite state, so the next run starts from the beginning.
+func (m *Machine) reset() {
+	m.CurrentState = InitialState
+	m.stack = nil
+	m.completed = nil
+	m.deadline = time.Time{}
+	m.timedState = ""
+}
+
+// Outcome returns how the last run of the machine ended, or the zero Outcome
+// while the machine has not ended.
+func (m *Machine) Outcome() Outcome {
+	return m.outcome
+}
+
+// Step executes the actions of the current state, evaluates its guards and
+// moves the machine to the next state. Entering and leaving a composite state
+// are steps of their own.
+func (m *Machine) Step(ctx context.Context) (StepResult, error) {
+	if m.Tracer != nil {
+		ctx = m.Tracer.StartState(ctx, m.CurrentState)
+	}
+
+	result, err := m.step(ctx)
+
+	if result.Done {
+		m.outcome = Outcome{State: m.CurrentState}
+	}
+
+	if result.Done || err != nil {
+		m.compensate(ctx, &result, err)
+	}
+
+	if m.CurrentState != m.timedState {
+		// The deadline is kept while the machine stays in the state
+		m.timedState = ""
+	}
+
+	if m.Tracer != nil {
+		m.Tracer.EndState(ctx, result, err)
+	}
+
+	for _, observer := range m.Observers {
+		observer.Stepped(result, err)
+	}
+
+	return result, err
+}
+
+// fail ends the run with err in the current state without taking a step. It is
+// reported like a failing step: the compensations are run, and the Tracer and
+// the Observers are notified.
+func (m *Machine) fail(ctx context.Context, err error) {
+	if m.Tracer != nil {
+		ctx = m.Tracer.StartState(ctx, m.CurrentState)
+	}
+
+	result := StepResult{Parent: m.parent(), PreviousState: m.CurrentState, NextState: m.CurrentState}
+	m.compensate(ctx, &result, err)
+
+	if m.Tracer != nil {
+		m.Tracer.EndState(ctx, result, err)
+	}
+
+	for _, observer := range m.Observers {
+		observer.Stepped(result, err)
+	}
+}
+
+func (m *Machine) step(ctx context.Context) (StepResult, error) {
+	result := StepResult{Parent: m.parent(), PreviousState: m.CurrentState, NextState: m.CurrentState}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	m.actionCtx = ctx
+
+	if i := m.expiredComposite(); i >= 0 {
+		// The composite state times out, leaving the states inside it
+		frame := m.stack[i]
+		m.stack = m.stack[:i]
+		m.CurrentState = frame.state
+		m.timedState = frame.state
+		m.deadline = frame.deadline
+		result.Parent = m.parent()
+		result.PreviousState = frame.state
+
+		return m.expire(result, frame.config, &DeadlineError{State: frame.state, Timeout: frame.config.Timeout})
+	}
+
+	if m.ended(m.CurrentState) {
+		if len(m.stack) == 0 {
+			result.Done = true
+
+			return result, nil
+		}
+
+		return m.exitComposite(ctx, result)
+	}
+
+	logger := m.Host.Logger()
+
+	config, exists := m.stateConfigs()[m.CurrentState]
+	if !exists {
+		err := fmt.Errorf("missing config for state: %s", m.CurrentState)
+		logger.Error("missing config", "state", m.CurrentState, "error", err)
+
+		if len(m.stack) == 0 {
+			return result, err
+		}
+
+		// A broken composite state is handled by the guards of its parent
+		m.Host.SetErr(err)
+
+		return m.exitComposite(ctx, result)
+	}
+
+	var err error
+
+	if config.Composite.StateConfigs != nil {
+		frame := compositeFrame{state: m.CurrentState, config: config}
+
+		if config.Timeout > 0 {
+			if m.startDeadline(m.clock().Now(), config) <= 0 {
+				return m.expire(result, config, &DeadlineError{State: m.CurrentState, Timeout: config.Timeout})
+			}
+
+			frame.deadline = m.deadline
+		}
+
+		if len(m.stack) < maxStateDepth {
+			m.stack = append(m.stack, frame)
+			m.CurrentState = config.Composite.InitialState
+			logger.Debug("entering composite state", "state", result.PreviousState, "initial", m.CurrentState)
+			result.NextState = m.CurrentState
+
+			return result, nil
+		}
+
+		err = errors.New("max state depth exceeded")
+		logger.Error("composite state machine failed", "state", m.CurrentState, "error", err)
+	} else {
+		if len(config.TimeTransitions) > 0 {
+			m.entered = m.clock().Now()
+		}
+
+		// Execute all actions for the current state, before its deadline
+		err = m.runStateActions(ctx, config)
+
+		if config.TimeoutTarget != "" && AsError[*DeadlineError](err) {
+			result.ActionErrors = append(result.ActionErrors, err)
+
+			return m.timeout(result, config.TimeoutTarget, err), nil
+		}
+
+		if err != nil {
+			logger.Error("action failed", "state", m.CurrentState, "error", err)
+			result.ActionErrors = append(result.ActionErrors, err)
+		} else if config.Compensation != nil {
+			m.completed = append(m.completed, completedState{state: m.CurrentState, compensation: config.Compensation})
+		}
+	}
+
+	if err != nil {
+		m.Host.SetErr(err)
+
+		if routed, ok := m.routeError(result, config, err); ok {
+			return routed, nil
+		}
+	}
+
+	return m.transition(ctx, result, config)
+}
+
+// ActionContext returns the context of the running actions. It is canceled
+// with the context of the step, when the action or its state times out, and
+// when an action running concurrently with the action fails.
+func (m *Machine) ActionContext() context.Context {
+	if m.actionCtx == nil {
+		return context.Background()
+	}
+
+	return m.actionCtx
+}
+
+// Synchronize calls fn while holding the lock of the machine. The generated
+// state machine uses it to guard the extended state from actions running
+// concurrently.
+func (m *Machine) Synchronize(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fn()
+}
+
+// stateConfigs returns the state configs of the innermost running state machine.
+func (m *Machine) stateConfigs() map[StateName]StateConfig {
+	if len(m.stack) == 0 {
+		return m.StateConfigs
+	}
+
+	return m.stack[len(m.stack)-1].config.Composite.StateConfigs
+}
+
+// parent returns the innermost composite state being executed, or an empty
+// name at the top level.
+func (m *Machine) parent() StateName {
+	if len(m.stack) == 0 {
+		return ""
+	}
+
+	return m.stack[len(m.stack)-1].state
+}
+
+// ended reports whether the state ends the state machine it belongs to, being
+// the FinalState or an end state.
+func (m *Machine) ended(state StateName) bool {
+	return state == FinalState || m.stateConfigs()[state].End
+}
+
+// exitComposite leaves the innermost composite state and continues with the
+// guards of the composite state itself.
+func (m *Machine) exitComposite(ctx context.Context, result StepResult) (StepResult, error) {
+	frame := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+
+	logger := m.Host.Logger()
+	logger.Debug("exiting composite state", "state", frame.state)
+	m.CurrentState = frame.state
+	result.Parent = m.parent()
+	result.PreviousState = frame.state
+
+	if !frame.deadline.IsZero() {
+		// The deadline is kept while the machine stays in the composite state
+		m.timedState = frame.state
+		m.deadline = frame.deadline
+	}
+
+	if len(frame.config.TimeTransitions) > 0 {
+		// The delays of a composite state count from the end of its state machine
+		m.entered = m.clock().Now()
+	}
+
+	if err := m.Host.Err(); err != nil {
+		logger.Error("composite state machine failed", "state", frame.state, "error", err)
+
+		if routed, ok := m.routeError(result, frame.config, err); ok {
+			return routed, nil
+		}
+	} else if frame.config.Compensation != nil {
+		m.completed = append(m.completed, completedState{state: frame.state, compensation: frame.config.Compensation})
+	}
+
+	return m.transition(ctx, result, frame.config)
+}
+
+// transition checks the guards of the current state and moves to the next
+// state. A NoTransitionError is returned if no transition can be taken.
+func (m *Machine) transition(ctx context.Context, result StepResult, config StateConfig) (StepResult, error) {
+	logger := m.Host.Logger()
+
+	nextState, err := m.runAllGuards(ctx, config, &result)
+	if err != nil {
+		m.Host.SetErr(err)
+		result.ActionErrors = append(result.ActionErrors, err)
+
+		if routed, ok := m.routeError(result, config, err); ok {
+			return routed, nil
+		}
+
+		// Guarded actions without a matching error transition end in the FinalState
+		nextState = FinalState
+	}
+
+	if nextState == "" {
+		// Check for unguarded transition
+		if next, exists := config.Transitions[len(config.Guards)]; exists {
+			logger.Debug("unguarded transition", "current", m.CurrentState, "next", next)
+			nextState = next
+		}
+	}
+
+	if nextState == "" && len(config.TimeTransitions) > 0 {
+		if nextState, err = m.waitForTime(ctx, config, &result); err != nil {
+			if AsError[*DeadlineError](err) {
+				return m.expire(result, config, err)
+			}
+
+			return result, err
+		}
+	}
+
+	if nextState == "" {
+		err := &NoTransitionError{State: m.CurrentState, Guards: result.Guards}
+		logger.Error("no transition", "state", m.CurrentState, "error", err)
+
+		return result, err
+	}
+
+	m.CurrentState = nextState
+	result.NextState = nextState
+	result.Done = m.ended(nextState) && len(m.stack) == 0
+
+	return result, nil
+}
+
+// waitForTime waits for the earliest of the time transitions of the state, and
+// returns its target. When Requeue is set, it is called with the delay instead.
+// A DeadlineError is returned if the deadline of the state passes first.
+func (m *Machine) waitForTime(ctx context.Context, config StateConfig, result *StepResult) (StateName, error) {
+	clock := m.clock()
+	now := clock.Now()
+
+	next := config.TimeTransitions[0]
+	delay := next.Delay(m.entered, now)
+
+	for _, transition := range config.TimeTransitions[1:] {
+		if d := transition.Delay(m.entered, now); d < delay {
+			next, delay = transition, d
+		}
+	}
+
+	timed := config.Timeout > 0 && m.timedState == m.CurrentState
+	if timed {
+		delay = min(delay, max(m.deadline.Sub(now), 0))
+	}
+
+	if m.Requeue != nil {
+		m.Requeue(delay)
+	} else if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-clock.After(delay):
+		}
+	}
+
+	if timed && !clock.Now().Before(m.deadline) {
+		return "", &DeadlineError{State: m.CurrentState, Timeout: config.Timeout}
+	}
+
+	m.Host.Logger().Debug("time transition", "current", m.CurrentState, "next", next.Target, "delay", delay)
+	result.Time = next.Event()
+	result.Delay = delay
+
+	return next.Target, nil
+}
+
+// timeout takes the timeout transition of the state, after the state or one of
+// its actions timed out.
+func (m *Machine) timeout(result StepResult, target StateName, err error) StepResult {
+	m.Host.Logger().Warn("timeout transition", "current", m.CurrentState, "next", target, "error", err)
+	m.CurrentState = target
+	result.NextState = target
+	result.TimedOut = true
+	result.Done = m.ended(target) && len(m.stack) == 0
+
+	return result
+}
+
+// expire handles the deadline of the current state passing outside of its
+// actions, while the machine waits for a time transition or runs the state
+// machine of a composite state. The machine takes the timeout transition of the
+// state, or an error transition matching the DeadlineError, and the step fails
+// with the DeadlineError otherwise.
+func (m *Machine) expire(result StepResult, config StateConfig, err error) (StepResult, error) {
+	result.ActionErrors = append(result.ActionErrors, err)
+
+	if config.TimeoutTarget != "" {
+		return m.timeout(result, config.TimeoutTarget, err), nil
+	}
+
+	m.Host.Logger().Error("state timed out", "state", m.CurrentState, "error", err)
+	m.Host.SetErr(err)
+
+	if routed, ok := m.routeError(result, config, err); ok {
+		return routed, nil
+	}
+
+	return result, err
+}
+
+// expiredComposite returns the position in the stack of the outermost
+// composite state the machine is in whose deadline has passed, or -1.
+func (m *Machine) expiredComposite() int {
+	var now time.Time
+
+	for i := range m.stack {
+		if m.stack[i].deadline.IsZero() {
+			continue
+		}
+
+		if now.IsZero() {
+			now = m.clock().Now()
+		}
+
+		if !now.Before(m.stack[i].deadline) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// compensate runs the compensations of the completed states in reverse order,
+// when the machine reached the FinalState on an error path. That is with an
+// error in the extended state, or through an error or timeout transition. They
+// are also run when the run fails with stepErr, like a NoTransitionError, a
+// MaxStepsExceededError or the error of a canceled context. The errors of the
+// compensations are added to the error of the extended state.
+func (m *Machine) compensate(ctx context.Context, result *StepResult, stepErr error) {
+	completed := m.completed
+	m.completed = nil
+
+	if stepErr == nil && m.Host.Err() == nil && !result.OnError && !result.TimedOut {
+		return
+	}
+
+	logger := m.Host.Logger()
+	// The compensations also run when the run was canceled
+	m.actionCtx = context.WithoutCancel(ctx)
+
+	for i := len(completed) - 1; i >= 0; i-- {
+		state, action := completed[i].state, completed[i].compensation
+
+		logger.Debug("compensating", "state", state, "action", action.Name)
+
+		compensation := Compensation{State: state, Action: action.Name, Err: action.Execute(action.Params...)}
+		if compensation.Err != nil {
+			err := &CompensationError{State: state, Action: action.Name, Err: compensation.Err}
+			logger.Error("compensation failed", "state", state, "action", action.Name, "error", err)
+			m.Host.SetErr(errors.Join(m.Host.Err(), err))
+		}
+
+		result.Compensations = append(result.Compensations, compensation)
+	}
+}
+
+// routeError takes the first error transition of config accepting err. The
+// error is then handled, and is moved from the extended state to HandledError.
+func (m *Machine) routeError(result StepResult, config StateConfig, err error) (StepResult, bool) {
+	for _, transition := range config.ErrorTransitions {
+		if !transition.Match(err) {
+			continue
+		}
+
+		m.Host.Logger().Debug("error transition", "current", m.CurrentState, "next", transition.Target,
+			"match", transition.Error, "error", err)
+		m.Host.SetErr(nil)
+		m.HandledError = err
+		m.CurrentState = transition.Target
+		result.NextState = transition.Target
+		result.OnError = true
+		result.ErrorMatch = transition.Error
+		result.Done = m.ended(transition.Target) && len(m.stack) == 0
+
+		return result, true
+	}
+
+	return result, false
+}
+
+// startDeadline sets the deadline of the current state, which has a timeout,
+// when the machine enters it. The deadline is kept while the machine stays in
+// the state. It returns the time left before the deadline.
+func (m *Machine) startDeadline(now time.Time, config StateConfig) time.Duration {
+	if m.timedState != m.CurrentState {
+		m.timedState = m.CurrentState
+		m.deadline = now.Add(config.Timeout)
+	}
+
+	return m.deadline.Sub(now)
+}
+
+// runStateActions executes the actions of the state. When the state has a
+// timeout, a DeadlineError is returned if the deadline has passed before the
+// actions are run, or expires while they run, so the guards of the state are
+// not checked after the deadline.
+func (m *Machine) runStateActions(ctx context.Context, config StateConfig) error {
+	if config.Timeout <= 0 {
+		return m.runAllActions(ctx, config.Actions)
+	}
+
+	clock := m.clock()
+	timedOut := &DeadlineError{State: m.CurrentState, Timeout: config.Timeout}
+
+	remaining := m.startDeadline(clock.Now(), config)
+	if remaining <= 0 {
+		return timedOut
+	}
+
+	stateCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m.actionCtx = stateCtx
+	timer := startTimer(clock, remaining, cancel)
+	err := m.runAllActions(stateCtx, config.Actions)
+	m.actionCtx = ctx
+
+	if timer.stop() {
+		timedOut.Err = err
+
+		return timedOut
+	}
+
+	return err
+}
+
+func (m *Machine) runAllActions(ctx context.Context, actions []Action) error {
+	for i := 0; i < len(actions); {
+		// The actions of a group are run together
+		end := i + 1
+		for actions[i].Group != 0 && end < len(actions) && actions[end].Group == actions[i].Group {
+			end++
+		}
+
+		var err error
+		if end-i == 1 {
+			err = m.runAction(ctx, actions[i], nil)
+		} else {
+			err = m.runConcurrently(ctx, actions[i:end])
+		}
+
+		if err != nil {
+			return err
+		}
+
+		i = end
+	}
+
+	return nil
+}
+
+// runConcurrently executes the actions concurrently. The first error cancels
+// the context of the other actions, and the errors of all the actions that
+// failed are joined. The errors caused by the cancellation are left out.
+func (m *Machine) runConcurrently(ctx context.Context, actions []Action) error {
+	groupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m.actionCtx = groupCtx
+	errs := make([]error, len(actions))
+	first := -1
+
+	var (
+		once sync.Once
+		wg   sync.WaitGroup
+	)
+
+	for i, action := range actions {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			fail := func() {
+				once.Do(func() {
+					first = i
+
+					cancel()
+				})
+			}
+
+			if errs[i] = m.runAction(groupCtx, action, fail); errs[i] != nil {
+				fail()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	m.actionCtx = ctx
+
+	if ctx.Err() == nil {
+		for i, err := range errs {
+			if i != first && errors.Is(err, context.Canceled) {
+				errs[i] = nil
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// runAction executes an action, and retries it according to its retry policy.
+// The error of the last attempt is returned when all retries are exhausted.
+// Actions running concurrently share their context, so the timeout of such an
+// action calls cancel instead of canceling a context of its own.
+func (m *Machine) runAction(ctx context.Context, action Action, cancel func()) error {
+	clock := m.clock()
+	logger := m.Host.Logger()
+
+	for attempt := 1; ; attempt++ {
+		logger.Debug("executing", "action", action.Name, "state", m.CurrentState, "attempt", attempt)
+
+		attempted := ActionAttempt{State: m.CurrentState, Action: action.Name, Attempt: attempt}
+		attemptCtx := ctx
+
+		if m.Tracer != nil {
+			attemptCtx = m.Tracer.StartAction(ctx, attempted)
+			if cancel == nil {
+				// Actions running one at a time get their span from ActionContext
+				m.actionCtx = attemptCtx
+			}
+		}
+
+		start := clock.Now()
+		err := m.execute(attemptCtx, clock, action, cancel)
+		attempted.Duration = clock.Now().Sub(start)
+		attempted.Err = err
+
+		if m.Tracer != nil {
+			m.Tracer.EndAction(attemptCtx, attempted)
+			if cancel == nil {
+				m.actionCtx = ctx
+			}
+		}
+
+		m.mu.Lock()
+		for _, observer := range m.Observers {
+			observer.ActionAttempted(attempted)
+		}
+		m.mu.Unlock()
+
+		if err == nil {
+			return nil
+		}
+
+		if action.Retry == nil {
+			return err
+		}
+
+		if attempt > action.Retry.Max {
+			return fmt.Errorf("%s failed after %d attempts: %w", action.Name, attempt, err)
+		}
+
+		delay := action.Retry.Delay(attempt)
+		logger.Warn("action failed, retrying", "action", action.Name, "state", m.CurrentState,
+			"attempt", attempt, "delay", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-clock.After(delay):
+		}
+	}
+}
+
+// execute runs a single attempt of the action. When the action has a timeout,
+// its context is canceled when the timeout expires, and the error of the action
+// is returned as a DeadlineError.
+func (m *Machine) execute(ctx context.Context, clock Clock, action Action, cancel func()) error {
+	if action.Timeout <= 0 {
+		return action.Execute(action.Params...)
+	}
+
+	if cancel == nil {
+		attemptCtx, cancelAttempt := context.WithCancel(ctx)
+		defer cancelAttempt()
+
+		m.actionCtx = attemptCtx
+		defer func() { m.actionCtx = ctx }()
+
+		cancel = cancelAttempt
+	}
+
+	timer := startTimer(clock, action.Timeout, cancel)
+	err := action.Execute(action.Params...)
+
+	if timer.stop() && err != nil {
+		return &DeadlineError{State: m.CurrentState, Action: action.Name, Timeout: action.Timeout, Err: err}
+	}
+
+	return err
+}
+
+func (m *Machine) clock() Clock {
+	if m.Clock == nil {
+		return RealClock{}
+	}
+
+	return m.Clock
+}
+
+// timer calls a function when a duration has passed on a clock, unless it is
+// stopped first.
+type timer struct {
+	mu      sync.Mutex
+	stopped chan struct{}
+	expired bool
+}
+
+func startTimer(clock Clock, d time.Duration, expire func()) *timer {
+	t := &timer{stopped: make(chan struct{})}
+	after := clock.After(d)
+
+	go func() {
+		select {
+		case <-after:
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			select {
+			case <-t.stopped:
+			default:
+				t.expired = true
+
+				expire()
+			}
+		case <-t.stopped:
+		}
+	}()
+
+	return t
+}
+
+// stop stops the timer, and reports whether it had expired.
+func (t *timer) stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	close(t.stopped)
+
+	return t.expired
+}
+
+func (m *Machine) runAllGuards(ctx context.Context, config StateConfig, result *StepResult) (StateName, error) {
+	for guardIndex, guard := range config.Guards {
+		passed := guard.Check(guard.Params...)
+		result.Guards = append(result.Guards, GuardResult{Name: guard.Name, Params: guard.Params, Passed: passed})
+
+		if passed {
+			if guard.Action != nil {
+				action := guard.Action
+				if err := m.runAction(ctx, *action, nil); err != nil {
+					m.Host.Logger().Debug("guarded action failed", "state", m.CurrentState,
+						"guard", guard.Name, "action", action.Name, "error", err)
+
+					result.Guard = guard.Name
+
+					return "", err
+				}
+			}
+
+			// Transition to the state mapped to this guard index
+			if nextState, exists := config.Transitions[guardIndex]; exists {
+				m.Host.Logger().Debug("guarded transition", "guard", guard.Name, "current", m.CurrentState,
+					"next", nextState)
+
+				result.Guard = guard.Name
+
+				return nextState, nil
+			}
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,1177 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mhersson/vectorsigma/pkgs/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTimeout = errors.New("timeout")
+
+type host struct {
+	err error
+}
+
+func (h *host) Logger() runtime.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+func (h *host) Err() error { return h.err }
+
+func (h *host) SetErr(err error) { h.err = err }
+
+// fakeClock returns immediately from After, and records the delays.
+type fakeClock struct {
+	delays []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return time.Time{} }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+	ch := make(chan time.Time, 1)
+	ch <- time.Time{}
+
+	return ch
+}
+
+// manualClock only moves when it is advanced.
+type manualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), ch: ch})
+
+	return ch
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	waiting := c.waiters[:0]
+
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiting = append(waiting, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+
+	c.waiters = waiting
+}
+
+// failing returns an action failing the given number of times before it succeeds.
+func failing(times int, err error) func(...string) error {
+	return func(...string) error {
+		if times > 0 {
+			times--
+
+			return err
+		}
+
+		return nil
+	}
+}
+
+func check(passed bool) func(...string) bool {
+	return func(...string) bool { return passed }
+}
+
+// recorder is a RunObserver recording the runs, the visited states and the
+// last error.
+type recorder struct {
+	runs   int
+	states []runtime.StateName
+	err    error
+}
+
+func (r *recorder) RunStarted() {
+	r.runs++
+}
+
+func (r *recorder) ActionAttempted(runtime.ActionAttempt) {}
+
+func (r *recorder) Stepped(result runtime.StepResult, err error) {
+	r.states = append(r.states, result.NextState)
+	r.err = err
+}
+
+func TestMachine_Run(t *testing.T) {
+	tests := []struct {
+		name      string
+		configs   map[runtime.StateName]runtime.StateConfig
+		maxSteps  int
+		want      []runtime.StateName
+		wantErr   error
+		wantState error
+	}{
+		{
+			name: "Unguarded transitions",
+			configs: map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "A"}},
+				"A":                  {Transitions: map[int]runtime.StateName{0: runtime.FinalState}},
+			},
+			want: []runtime.StateName{"A", runtime.FinalState},
+		},
+		{
+			name: "Guarded transition",
+			configs: map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {
+					Guards: []runtime.Guard{
+						{Name: "IsA", Check: check(false)},
+						{Name: "IsB", Check: check(true)},
+					},
+					Transitions: map[int]runtime.StateName{0: "A", 1: "B", 2: runtime.FinalState},
+				},
+				"B": {Transitions: map[int]runtime.StateName{0: runtime.FinalState}},
+			},
+			want: []runtime.StateName{"B", runtime.FinalState},
+		},
+		{
+			name: "Error transition",
+			configs: map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {
+					Actions: []runtime.Action{{Name: "Fetch", Execute: failing(1, errTimeout)}},
+					ErrorTransitions: []runtime.ErrorTransition{
+						{Error: "ErrTimeout", Match: runtime.IsError(errTimeout), Target: "Failed"},
+					},
+					Transitions: map[int]runtime.StateName{0: runtime.FinalState},
+				},
+				"Failed": {Transitions: map[int]runtime.StateName{0: runtime.FinalState}},
+			},
+			want: []runtime.StateName{"Failed", runtime.FinalState},
+		},
+		{
+			name: "Unhandled error is left in the extended state",
+			configs: map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {
+					Actions:     []runtime.Action{{Name: "Fetch", Execute: failing(1, errTimeout)}},
+					Transitions: map[int]runtime.StateName{0: runtime.FinalState},
+				},
+			},
+			want:      []runtime.StateName{runtime.FinalState},
+			wantState: errTimeout,
+		},
+		{
+			name: "Retry",
+			configs: map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {
+					Actions: []runtime.Action{{
+						Name:    "Fetch",
+						Execute: failing(2, errTimeout),
+						Retry:   &runtime.RetryPolicy{Max: 2, Backoff: runtime.BackoffExponential, Base: time.Second},
+					}},
+					ErrorTransitions: []runtime.ErrorTransition{{Match: runtime.AnyError, Target: "Failed"}},
+					Transitions:      map[int]runtime.StateName{0: runtime.FinalState},
+				},
+			},
+			want: []runtime.StateName{runtime.FinalState},
+		},
+		{
+			name: "Composite state",
+			configs: map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Outer"}},
+				"Outer": {
+					Transitions: map[int]runtime.StateName{0: runtime.FinalState},
+					Composite: runtime.CompositeState{
+						InitialState: runtime.InitialState,
+						StateConfigs: map[runtime.StateName]runtime.StateConfig{
+							runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Inner"}},
+							"Inner":              {Transitions: map[int]runtime.StateName{0: runtime.FinalState}},
+						},
+					},
+				},
+			},
+			want: []runtime.StateName{"Outer", runtime.InitialState, "Inner", runtime.FinalState, runtime.FinalState},
+		},
+		{
+			name: "End state",
+			configs: map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {
+					Guards:      []runtime.Guard{{Name: "IsRejected", Check: check(true)}},
+					Transitions: map[int]runtime.StateName{0: "Rejected", 1: runtime.FinalState},
+				},
+				"Rejected": {End: true},
+			},
+			want: []runtime.StateName{"Rejected"},
+		},
+		{
+			name: "End state in a composite state",
+			configs: map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Outer"}},
+				"Outer": {
+					Transitions: map[int]runtime.StateName{0: runtime.FinalState},
+					Composite: runtime.CompositeState{
+						InitialState: runtime.InitialState,
+						StateConfigs: map[runtime.StateName]runtime.StateConfig{
+							runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Skipped"}},
+							"Skipped":            {End: true},
+						},
+					},
+				},
+			},
+			want: []runtime.StateName{"Outer", runtime.InitialState, "Skipped", runtime.FinalState},
+		},
+		{
+			name: "No transition",
+			configs: map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {
+					Guards:      []runtime.Guard{{Name: "IsA", Check: check(false)}},
+					Transitions: map[int]runtime.StateName{0: "A"},
+				},
+			},
+			want:    []runtime.StateName{runtime.InitialState},
+			wantErr: runtime.ErrNoTransition,
+		},
+		{
+			name: "Max steps exceeded",
+			configs: map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "A"}},
+				"A":                  {Transitions: map[int]runtime.StateName{0: "A"}},
+			},
+			maxSteps: 3,
+			want:     []runtime.StateName{"A", "A", "A", "A"},
+			wantErr:  runtime.ErrMaxStepsExceeded,
+		},
+		{
+			name: "Missing config",
+			configs: map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "A"}},
+			},
+			want:    []runtime.StateName{"A", "A"},
+			wantErr: errors.New("missing config for state: A"),
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &host{}
+			r := &recorder{}
+			m := &runtime.Machine{
+				CurrentState: runtime.InitialState,
+				StateConfigs: tt.configs,
+				MaxSteps:     tt.maxSteps,
+				Clock:        &fakeClock{},
+				Observers:    []runtime.Observer{r},
+				Host:         h,
+			}
+
+			err := m.Run()
+
+			switch {
+			case tt.wantErr == nil:
+				require.NoError(t, err)
+				assert.Equal(t, runtime.InitialState, m.CurrentState)
+				assert.Equal(t, runtime.Outcome{State: tt.want[len(tt.want)-1]}, m.Outcome())
+			case errors.Is(err, tt.wantErr):
+			default:
+				assert.EqualError(t, err, tt.wantErr.Error())
+			}
+
+			assert.Equal(t, 1, r.runs)
+			assert.Equal(t, tt.want, r.states)
+			assert.Equal(t, err, r.err)
+			assert.Equal(t, tt.wantState, h.err)
+		})
+	}
+}
+
+func TestMachine_RunAfterError(t *testing.T) {
+	t.Parallel()
+
+	m := &runtime.Machine{
+		CurrentState: runtime.InitialState,
+		StateConfigs: map[runtime.StateName]runtime.StateConfig{
+			runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Outer"}},
+			"Outer": {
+				Transitions: map[int]runtime.StateName{0: runtime.FinalState},
+				Composite: runtime.CompositeState{
+					InitialState: runtime.InitialState,
+					StateConfigs: map[runtime.StateName]runtime.StateConfig{
+						runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Inner"}},
+						"Inner": {
+							Guards:      []runtime.Guard{{Name: "IsDone", Check: check(false)}},
+							Transitions: map[int]runtime.StateName{0: runtime.FinalState},
+						},
+					},
+				},
+			},
+		},
+		Clock: &fakeClock{},
+		Host:  &host{},
+	}
+
+	// Every run fails in the composite state, and starts over from the beginning
+	for range 2 {
+		err := m.Run()
+
+		var noTransition *runtime.NoTransitionError
+		require.ErrorAs(t, err, &noTransition)
+		assert.Equal(t, runtime.StateName("Inner"), noTransition.State)
+		assert.Equal(t, runtime.InitialState, m.CurrentState)
+		assert.Empty(t, m.Describe().Composites)
+	}
+}
+
+func TestMachine_RunRetryDelays(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{}
+	h := &host{}
+	m := &runtime.Machine{
+		CurrentState: runtime.InitialState,
+		StateConfigs: map[runtime.StateName]runtime.StateConfig{
+			runtime.InitialState: {
+				Actions: []runtime.Action{{
+					Name:    "Fetch",
+					Execute: failing(5, errTimeout),
+					Retry:   &runtime.RetryPolicy{Max: 3, Backoff: runtime.BackoffExponential, Base: time.Second},
+				}},
+				ErrorTransitions: []runtime.ErrorTransition{{Match: runtime.AnyError, Target: runtime.FinalState}},
+			},
+		},
+		Clock: clock,
+		Host:  h,
+	}
+
+	require.NoError(t, m.Run())
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, clock.delays)
+	require.ErrorIs(t, m.HandledError, errTimeout)
+	assert.EqualError(t, m.HandledError, "Fetch failed after 4 attempts: timeout")
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	const maxDelay = time.Duration(math.MaxInt64)
+
+	tests := []struct {
+		name   string
+		policy runtime.RetryPolicy
+		retry  int
+		want   time.Duration
+	}{
+		{name: "Constant", policy: runtime.RetryPolicy{Max: 3, Base: time.Second}, retry: 3, want: time.Second},
+		{
+			name:   "Linear",
+			policy: runtime.RetryPolicy{Max: 3, Backoff: runtime.BackoffLinear, Base: time.Second},
+			retry:  3,
+			want:   3 * time.Second,
+		},
+		{
+			name:   "Exponential",
+			policy: runtime.RetryPolicy{Max: 3, Backoff: runtime.BackoffExponential, Base: time.Second},
+			retry:  3,
+			want:   4 * time.Second,
+		},
+		{
+			name:   "Linear saturates",
+			policy: runtime.RetryPolicy{Max: math.MaxInt, Backoff: runtime.BackoffLinear, Base: time.Hour},
+			retry:  math.MaxInt,
+			want:   maxDelay,
+		},
+		{
+			name:   "Exponential saturates",
+			policy: runtime.RetryPolicy{Max: 100, Backoff: runtime.BackoffExponential, Base: time.Minute},
+			retry:  100,
+			want:   maxDelay,
+		},
+		{
+			name:   "Exponential below the limit",
+			policy: runtime.RetryPolicy{Max: 100, Backoff: runtime.BackoffExponential, Base: 8 * time.Second},
+			retry:  100,
+			want:   8 * time.Second << 30,
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, tt.policy.Delay(tt.retry))
+		})
+	}
+}
+
+type spanKey struct{}
+
+// tracer is a Tracer recording the start and end of the spans, named by their
+// path from the root span.
+type tracer struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (tr *tracer) start(ctx context.Context, name string) context.Context {
+	path, _ := ctx.Value(spanKey{}).(string)
+	path += "/" + name
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	tr.events = append(tr.events, "start "+path)
+
+	return context.WithValue(ctx, spanKey{}, path)
+}
+
+func (tr *tracer) end(ctx context.Context, detail string) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	tr.events = append(tr.events, "end "+ctx.Value(spanKey{}).(string)+" "+detail)
+}
+
+func (tr *tracer) StartState(ctx context.Context, state runtime.StateName) context.Context {
+	return tr.start(ctx, string(state))
+}
+
+func (tr *tracer) EndState(ctx context.Context, result runtime.StepResult, err error) {
+	tr.end(ctx, fmt.Sprintf("guard=%s next=%s err=%v", result.Guard, result.NextState, err))
+}
+
+func (tr *tracer) StartAction(ctx context.Context, attempt runtime.ActionAttempt) context.Context {
+	return tr.start(ctx, fmt.Sprintf("%s#%d", attempt.Action, attempt.Attempt))
+}
+
+func (tr *tracer) EndAction(ctx context.Context, attempt runtime.ActionAttempt) {
+	tr.end(ctx, fmt.Sprintf("err=%v", attempt.Err))
+}
+
+func TestMachine_Tracer(t *testing.T) {
+	t.Parallel()
+
+	tr := &tracer{}
+	m := &runtime.Machine{CurrentState: "Fetching", Clock: &fakeClock{}, Tracer: tr, Host: &host{}}
+
+	// The actions get the context with their span from ActionContext
+	var spans []string
+
+	fetch := failing(1, errTimeout)
+	traced := func(action func(...string) error) func(...string) error {
+		return func(params ...string) error {
+			spans = append(spans, m.ActionContext().Value(spanKey{}).(string))
+
+			return action(params...)
+		}
+	}
+
+	m.StateConfigs = map[runtime.StateName]runtime.StateConfig{
+		"Fetching": {
+			Actions: []runtime.Action{{Name: "Fetch", Execute: traced(fetch), Retry: &runtime.RetryPolicy{Max: 1}}},
+			Guards: []runtime.Guard{{
+				Name:   "IsFetched",
+				Check:  check(true),
+				Action: &runtime.Action{Name: "Store", Execute: traced(failing(0, nil))},
+			}},
+			Transitions: map[int]runtime.StateName{0: runtime.FinalState},
+		},
+	}
+
+	require.NoError(t, m.RunContext(context.WithValue(context.Background(), spanKey{}, "root")))
+	assert.Equal(t, []string{
+		"start root/Fetching",
+		"start root/Fetching/Fetch#1",
+		"end root/Fetching/Fetch#1 err=timeout",
+		"start root/Fetching/Fetch#2",
+		"end root/Fetching/Fetch#2 err=<nil>",
+		"start root/Fetching/Store#1",
+		"end root/Fetching/Store#1 err=<nil>",
+		"end root/Fetching guard=IsFetched next=FinalState err=<nil>",
+	}, tr.events)
+	assert.Equal(t, []string{"root/Fetching/Fetch#1", "root/Fetching/Fetch#2", "root/Fetching/Store#1"}, spans)
+	assert.Equal(t, "root/Fetching", m.ActionContext().Value(spanKey{}))
+}
+
+func TestMachine_StepCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	m := &runtime.Machine{CurrentState: runtime.InitialState, Host: &host{}}
+
+	result, err := m.Step(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, runtime.InitialState, result.NextState)
+}
+
+func TestMachine_RunConcurrently(t *testing.T) {
+	t.Parallel()
+
+	m := &runtime.Machine{CurrentState: runtime.InitialState, Host: &host{}}
+
+	// Waits for the other actions to be canceled by the failing action
+	waiting := func(...string) error {
+		<-m.ActionContext().Done()
+
+		return m.ActionContext().Err()
+	}
+
+	var stored bool
+
+	m.StateConfigs = map[runtime.StateName]runtime.StateConfig{
+		runtime.InitialState: {
+			Actions: []runtime.Action{
+				{Name: "FetchUsers", Execute: waiting, Group: 1},
+				{Name: "FetchOrders", Execute: failing(1, errTimeout), Group: 1},
+				{Name: "FetchInvoices", Execute: waiting, Group: 1},
+				{Name: "Store", Execute: func(...string) error {
+					stored = true
+
+					return nil
+				}},
+			},
+			ErrorTransitions: []runtime.ErrorTransition{
+				{Error: "ErrTimeout", Match: runtime.IsError(errTimeout), Target: runtime.FinalState},
+			},
+		},
+	}
+
+	result, err := m.Step(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, runtime.FinalState, result.NextState)
+	require.Len(t, result.ActionErrors, 1)
+	// The errors of the canceled actions are left out
+	assert.Equal(t, "timeout", result.ActionErrors[0].Error())
+	require.ErrorIs(t, m.HandledError, errTimeout)
+	assert.False(t, stored)
+	require.NoError(t, m.ActionContext().Err())
+}
+
+func TestMachine_StateTimeout(t *testing.T) {
+	t.Parallel()
+
+	clock := &manualClock{}
+	polls := 0
+
+	m := &runtime.Machine{
+		CurrentState: "Waiting",
+		Host:         &host{},
+		Clock:        clock,
+		StateConfigs: map[runtime.StateName]runtime.StateConfig{
+			"Waiting": {
+				Actions: []runtime.Action{{Name: "Poll", Execute: func(...string) error {
+					polls++
+
+					return nil
+				}}},
+				Transitions:   map[int]runtime.StateName{0: "Waiting"},
+				Timeout:       30 * time.Second,
+				TimeoutTarget: "TimedOut",
+			},
+			"TimedOut": {Transitions: map[int]runtime.StateName{0: runtime.FinalState}},
+		},
+	}
+
+	// The deadline is kept while the machine stays in the state
+	for range 2 {
+		result, err := m.Step(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, runtime.StateName("Waiting"), result.NextState)
+		assert.False(t, result.TimedOut)
+
+		clock.Advance(15 * time.Second)
+	}
+
+	result, err := m.Step(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, runtime.StateName("TimedOut"), result.NextState)
+	assert.True(t, result.TimedOut)
+	assert.Equal(t, 2, polls)
+	require.Len(t, result.ActionErrors, 1)
+	require.ErrorIs(t, result.ActionErrors[0], context.DeadlineExceeded)
+	assert.Equal(t, "state Waiting timed out after 30s", result.ActionErrors[0].Error())
+}
+
+func TestMachine_ActionTimeout(t *testing.T) {
+	tests := []struct {
+		name          string
+		group         int
+		timeoutTarget runtime.StateName
+		wantNext      runtime.StateName
+		wantTimedOut  bool
+	}{
+		{name: "Timeout transition", timeoutTarget: "TimedOut", wantNext: "TimedOut", wantTimedOut: true},
+		{name: "Error transition", wantNext: "Failed"},
+		{name: "Concurrent actions", group: 1, timeoutTarget: "TimedOut", wantNext: "TimedOut", wantTimedOut: true},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			clock := &manualClock{}
+			m := &runtime.Machine{CurrentState: "Fetching", Host: &host{}, Clock: clock}
+
+			waiting := func(...string) error {
+				<-m.ActionContext().Done()
+
+				return m.ActionContext().Err()
+			}
+
+			slow := func(...string) error {
+				clock.Advance(5 * time.Second)
+
+				return waiting()
+			}
+
+			m.StateConfigs = map[runtime.StateName]runtime.StateConfig{
+				"Fetching": {
+					Actions: []runtime.Action{
+						{Name: "Fetch", Execute: slow, Group: tt.group, Timeout: 5 * time.Second},
+						{Name: "Wait", Execute: waiting, Group: tt.group},
+					},
+					ErrorTransitions: []runtime.ErrorTransition{
+						{Error: "*DeadlineError", Match: runtime.AsError[*runtime.DeadlineError], Target: "Failed"},
+					},
+					TimeoutTarget: tt.timeoutTarget,
+				},
+			}
+
+			if tt.group == 0 {
+				// The next action is not run after the timeout
+				m.StateConfigs["Fetching"].Actions[1].Execute = nil
+			}
+
+			result, err := m.Step(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNext, result.NextState)
+			assert.Equal(t, tt.wantTimedOut, result.TimedOut)
+			require.Len(t, result.ActionErrors, 1)
+			assert.Equal(t, "action Fetch in state Fetching timed out after 5s", result.ActionErrors[0].Error())
+			require.ErrorIs(t, result.ActionErrors[0], context.Canceled)
+			require.NoError(t, m.ActionContext().Err())
+		})
+	}
+}
+
+func TestTimeTransition_Delay(t *testing.T) {
+	entered := time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		transition runtime.TimeTransition
+		now        time.Time
+		want       time.Duration
+	}{
+		{name: "After", transition: runtime.TimeTransition{After: 10 * time.Second}, now: entered.Add(4 * time.Second), want: 6 * time.Second},
+		{name: "After passed", transition: runtime.TimeTransition{After: 10 * time.Second}, now: entered.Add(time.Minute), want: 0},
+		{name: "At later today", transition: runtime.TimeTransition{At: "07:30"}, now: entered, want: 30 * time.Minute},
+		{name: "At tomorrow", transition: runtime.TimeTransition{At: "06:59:30"}, now: entered, want: 23*time.Hour + 59*time.Minute + 30*time.Second},
+		{name: "At now", transition: runtime.TimeTransition{At: "07:00"}, now: entered, want: 24 * time.Hour},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, tt.transition.Delay(entered, tt.now))
+		})
+	}
+}
+
+func TestMachine_TimeTransition(t *testing.T) {
+	tests := []struct {
+		name      string
+		awake     bool
+		requeue   bool
+		cancel    bool
+		wantNext  runtime.StateName
+		wantDelay time.Duration
+		wantErr   error
+	}{
+		{name: "Guard first", awake: true, wantNext: "Awake"},
+		{name: "Wait", wantNext: "Polling", wantDelay: 6 * time.Second},
+		{name: "Requeue", requeue: true, wantNext: "Polling", wantDelay: 6 * time.Second},
+		{name: "Canceled", cancel: true, wantNext: "Sleeping", wantErr: context.Canceled},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			clock := &manualClock{now: time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)}
+
+			var requeued []time.Duration
+
+			m := &runtime.Machine{
+				CurrentState: "Sleeping",
+				Host:         &host{},
+				Clock:        clock,
+				StateConfigs: map[runtime.StateName]runtime.StateConfig{
+					"Sleeping": {
+						Actions: []runtime.Action{{Name: "Check", Execute: func(...string) error {
+							clock.Advance(4 * time.Second)
+
+							return nil
+						}}},
+						Guards:      []runtime.Guard{{Name: "IsAwake", Check: func(...string) bool { return tt.awake }}},
+						Transitions: map[int]runtime.StateName{0: "Awake"},
+						TimeTransitions: []runtime.TimeTransition{
+							{At: "08:00", Target: "Reporting"},
+							{After: 10 * time.Second, Target: "Polling"},
+						},
+					},
+				},
+			}
+
+			if tt.requeue {
+				m.Requeue = func(after time.Duration) { requeued = append(requeued, after) }
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			type step struct {
+				result runtime.StepResult
+				err    error
+			}
+
+			done := make(chan step)
+
+			go func() {
+				result, err := m.Step(ctx)
+				done <- step{result: result, err: err}
+			}()
+
+			if !tt.awake && !tt.requeue {
+				// The machine waits for the clock
+				assert.Eventually(t, func() bool {
+					clock.mu.Lock()
+					defer clock.mu.Unlock()
+
+					return len(clock.waiters) == 1
+				}, time.Second, time.Millisecond)
+
+				if tt.cancel {
+					cancel()
+				} else {
+					clock.Advance(6 * time.Second)
+				}
+			}
+
+			got := <-done
+			require.ErrorIs(t, got.err, tt.wantErr)
+			assert.Equal(t, tt.wantNext, m.CurrentState)
+			assert.Equal(t, tt.wantDelay, got.result.Delay)
+
+			if tt.requeue {
+				assert.Equal(t, []time.Duration{6 * time.Second}, requeued)
+			}
+		})
+	}
+}
+
+func TestMachine_TimeTransitionTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       runtime.StateName
+		requeue      bool
+		wantNext     runtime.StateName
+		wantTimedOut bool
+		wantErr      error
+	}{
+		{name: "Timeout transition", target: "TimedOut", wantNext: "TimedOut", wantTimedOut: true},
+		{name: "No timeout transition", wantNext: "Waiting", wantErr: context.DeadlineExceeded},
+		{name: "Requeue", target: "TimedOut", requeue: true, wantNext: runtime.FinalState},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			clock := &manualClock{}
+
+			var requeued []time.Duration
+
+			m := &runtime.Machine{
+				CurrentState: "Waiting",
+				Host:         &host{},
+				Clock:        clock,
+				StateConfigs: map[runtime.StateName]runtime.StateConfig{
+					"Waiting": {
+						Guards:          []runtime.Guard{{Name: "IsReady", Check: check(false)}},
+						Transitions:     map[int]runtime.StateName{0: "Ready"},
+						TimeTransitions: []runtime.TimeTransition{{After: time.Hour, Target: runtime.FinalState}},
+						Timeout:         30 * time.Second,
+						TimeoutTarget:   tt.target,
+					},
+				},
+			}
+
+			if tt.requeue {
+				m.Requeue = func(after time.Duration) { requeued = append(requeued, after) }
+			}
+
+			type step struct {
+				result runtime.StepResult
+				err    error
+			}
+
+			done := make(chan step)
+
+			go func() {
+				result, err := m.Step(context.Background())
+				done <- step{result: result, err: err}
+			}()
+
+			if !tt.requeue {
+				// The machine waits for the deadline, after the timer of the actions
+				assert.Eventually(t, func() bool {
+					clock.mu.Lock()
+					defer clock.mu.Unlock()
+
+					return len(clock.waiters) == 2
+				}, time.Second, time.Millisecond)
+
+				clock.Advance(30 * time.Second)
+			}
+
+			got := <-done
+			require.ErrorIs(t, got.err, tt.wantErr)
+			assert.Equal(t, tt.wantNext, m.CurrentState)
+			assert.Equal(t, tt.wantTimedOut, got.result.TimedOut)
+
+			if tt.requeue {
+				// The reconcile loop is requeued at the deadline
+				assert.Equal(t, []time.Duration{30 * time.Second}, requeued)
+			}
+		})
+	}
+}
+
+func TestMachine_CompositeTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		nested bool
+	}{
+		{name: "Composite state"},
+		{name: "Nested composite state", nested: true},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			clock := &manualClock{}
+
+			inner := map[runtime.StateName]runtime.StateConfig{
+				runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Waiting"}},
+				"Waiting": {
+					Actions: []runtime.Action{{Name: "Poll", Execute: func(...string) error {
+						clock.Advance(20 * time.Second)
+
+						return nil
+					}}},
+					Transitions: map[int]runtime.StateName{0: "Waiting"},
+				},
+			}
+
+			if tt.nested {
+				inner = map[runtime.StateName]runtime.StateConfig{
+					runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Rollout"}},
+					"Rollout": {
+						Composite:   runtime.CompositeState{InitialState: runtime.InitialState, StateConfigs: inner},
+						Transitions: map[int]runtime.StateName{0: runtime.FinalState},
+					},
+				}
+			}
+
+			r := &recorder{}
+			m := &runtime.Machine{
+				CurrentState: runtime.InitialState,
+				Host:         &host{},
+				Clock:        clock,
+				MaxSteps:     20,
+				Observers:    []runtime.Observer{r},
+				StateConfigs: map[runtime.StateName]runtime.StateConfig{
+					runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Deploying"}},
+					"Deploying": {
+						Composite:     runtime.CompositeState{InitialState: runtime.InitialState, StateConfigs: inner},
+						Transitions:   map[int]runtime.StateName{0: runtime.FinalState},
+						Timeout:       time.Minute,
+						TimeoutTarget: "RolledBack",
+					},
+					"RolledBack": {Transitions: map[int]runtime.StateName{0: runtime.FinalState}},
+				},
+			}
+
+			require.NoError(t, m.Run())
+			assert.Equal(t, []runtime.StateName{"RolledBack", runtime.FinalState}, r.states[len(r.states)-2:])
+			assert.Equal(t, runtime.InitialState, m.CurrentState)
+		})
+	}
+}
+
+func TestMachine_Compensate(t *testing.T) {
+	errUndo := errors.New("undo failed")
+
+	tests := []struct {
+		name        string
+		startErr    error
+		onError     bool
+		eraseErr    error
+		want        []runtime.Compensation
+		wantErr     []error
+		wantOnError bool
+	}{
+		{name: "Success"},
+		{
+			name: "Error in the extended state", startErr: errTimeout,
+			want: []runtime.Compensation{
+				{State: "Configuring", Action: "Unconfigure"},
+				{State: "Writing", Action: "Erase"},
+				{State: "Creating", Action: "Delete"},
+			},
+			wantErr: []error{errTimeout},
+		},
+		{
+			name: "Error transition", startErr: errTimeout, onError: true,
+			want: []runtime.Compensation{
+				{State: "Configuring", Action: "Unconfigure"},
+				{State: "Writing", Action: "Erase"},
+				{State: "Creating", Action: "Delete"},
+			},
+			wantOnError: true,
+		},
+		{
+			name: "Failing compensation", startErr: errTimeout, eraseErr: errUndo,
+			want: []runtime.Compensation{
+				{State: "Configuring", Action: "Unconfigure"},
+				{State: "Writing", Action: "Erase", Err: errUndo},
+				{State: "Creating", Action: "Delete"},
+			},
+			wantErr: []error{errTimeout, errUndo},
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &host{}
+			undo := func(name runtime.ActionName, err error) *runtime.Action {
+				return &runtime.Action{Name: name, Execute: func(...string) error { return err }}
+			}
+
+			starting := runtime.StateConfig{
+				Actions:     []runtime.Action{{Name: "Start", Execute: failing(1, tt.startErr)}},
+				Guards:      []runtime.Guard{{Name: "IsError", Check: func(...string) bool { return h.err != nil }}},
+				Transitions: map[int]runtime.StateName{0: runtime.FinalState, 1: runtime.FinalState},
+			}
+
+			if tt.onError {
+				starting.ErrorTransitions = []runtime.ErrorTransition{{Match: runtime.AnyError, Target: runtime.FinalState}}
+			}
+
+			m := &runtime.Machine{
+				CurrentState: "Creating",
+				Host:         h,
+				StateConfigs: map[runtime.StateName]runtime.StateConfig{
+					"Creating": {
+						Actions:      []runtime.Action{{Name: "Create", Execute: failing(0, nil)}},
+						Transitions:  map[int]runtime.StateName{0: "Configuring"},
+						Compensation: undo("Delete", nil),
+					},
+					"Configuring": {
+						Composite: runtime.CompositeState{
+							InitialState: "Writing",
+							StateConfigs: map[runtime.StateName]runtime.StateConfig{
+								"Writing": {
+									Transitions:  map[int]runtime.StateName{0: runtime.FinalState},
+									Compensation: undo("Erase", tt.eraseErr),
+								},
+							},
+						},
+						Transitions:  map[int]runtime.StateName{0: "Starting"},
+						Compensation: undo("Unconfigure", nil),
+					},
+					"Starting": starting,
+				},
+			}
+
+			var result runtime.StepResult
+
+			for !result.Done {
+				var err error
+
+				result, err = m.Step(context.Background())
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.want, result.Compensations)
+			assert.Equal(t, tt.wantOnError, result.OnError)
+
+			for _, want := range tt.wantErr {
+				require.ErrorIs(t, h.err, want)
+			}
+
+			if tt.eraseErr != nil {
+				assert.True(t, runtime.AsError[*runtime.CompensationError](h.err))
+			}
+
+			if tt.wantErr == nil {
+				require.NoError(t, h.err)
+			}
+		})
+	}
+}
+
+func TestMachine_CompensateFailedRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		next     runtime.StateConfig
+		maxSteps int
+		cancel   bool
+		wantErr  error
+	}{
+		{
+			name: "No transition",
+			next: runtime.StateConfig{
+				Guards:      []runtime.Guard{{Name: "IsDone", Check: check(false)}},
+				Transitions: map[int]runtime.StateName{0: runtime.FinalState},
+			},
+			wantErr: runtime.ErrNoTransition,
+		},
+		{
+			name:     "Max steps exceeded",
+			next:     runtime.StateConfig{Transitions: map[int]runtime.StateName{0: "Next"}},
+			maxSteps: 3,
+			wantErr:  runtime.ErrMaxStepsExceeded,
+		},
+		{
+			name:    "Canceled",
+			next:    runtime.StateConfig{Transitions: map[int]runtime.StateName{0: runtime.FinalState}},
+			cancel:  true,
+			wantErr: context.Canceled,
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var (
+				m      *runtime.Machine
+				undone []runtime.ActionName
+			)
+
+			undo := func(name runtime.ActionName) *runtime.Action {
+				return &runtime.Action{Name: name, Execute: func(...string) error {
+					// The compensations run with a live context, also when the run was canceled
+					if err := m.ActionContext().Err(); err != nil {
+						return err
+					}
+
+					undone = append(undone, name)
+
+					return nil
+				}}
+			}
+
+			m = &runtime.Machine{
+				CurrentState: runtime.InitialState,
+				MaxSteps:     tt.maxSteps,
+				Host:         &host{},
+				StateConfigs: map[runtime.StateName]runtime.StateConfig{
+					runtime.InitialState: {Transitions: map[int]runtime.StateName{0: "Creating"}},
+					"Creating": {
+						Transitions:  map[int]runtime.StateName{0: "Configuring"},
+						Compensation: undo("Delete"),
+					},
+					"Configuring": {
+						Actions: []runtime.Action{{Name: "Configure", Execute: func(...string) error {
+							if tt.cancel {
+								cancel()
+							}
+
+							return nil
+						}}},
+						Transitions:  map[int]runtime.StateName{0: "Next"},
+						Compensation: undo("Unconfigure"),
+					},
+					"Next": tt.next,
+				},
+			}
+
+			err := m.RunContext(ctx)
+
+			require.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, []runtime.ActionName{"Unconfigure", "Delete"}, undone)
+			require.NoError(t, m.Host.Err())
+		})
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package runtime
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// TraceRecord describes a single step in an execution trace.
+type TraceRecord struct {
+	Step          int                 `json:"step"`
+	Parent        StateName           `json:"parent,omitempty"`
+	State         StateName           `json:"state"`
+	Actions       []TraceAction       `json:"actions,omitempty"`
+	Guards        []GuardResult       `json:"guards,omitempty"`
+	Guard         GuardName           `json:"guard,omitempty"`
+	Next          StateName           `json:"next"`
+	OnError       bool                `json:"onError,omitempty"`
+	ErrorMatch    string              `json:"errorMatch,omitempty"`
+	TimedOut      bool                `json:"timedOut,omitempty"`
+	Time          string              `json:"time,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	Done          bool                `json:"done,omitempty"`
+	Compensations []TraceCompensation `json:"compensations,omitempty"`
+}
+
+// TraceAction describes a single attempt to execute an action in a trace.
+type TraceAction struct {
+	Name     ActionName    `json:"name"`
+	Attempt  int           `json:"attempt"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// TraceCompensation describes a compensation run at the end of a trace.
+type TraceCompensation struct {
+	State StateName  `json:"state"`
+	Name  ActionName `json:"name"`
+	Error string     `json:"error,omitempty"`
+}
+
+// TraceRecorder is an Observer writing each step to a trace, as a line of JSON.
+type TraceRecorder struct {
+	encoder *json.Encoder
+	steps   int
+	actions []TraceAction
+	err     error
+}
+
+// NewTraceRecorder returns a TraceRecorder writing to w.
+func NewTraceRecorder(w io.Writer) *TraceRecorder {
+	return &TraceRecorder{encoder: json.NewEncoder(w)}
+}
+
+func (r *TraceRecorder) ActionAttempted(attempt ActionAttempt) {
+	action := TraceAction{Name: attempt.Action, Attempt: attempt.Attempt, Duration: attempt.Duration}
+	if attempt.Err != nil {
+		action.Error = attempt.Err.Error()
+	}
+
+	r.actions = append(r.actions, action)
+}
+
+func (r *TraceRecorder) Stepped(result StepResult, err error) {
+	r.steps++
+
+	record := TraceRecord{
+		Step:       r.steps,
+		Parent:     result.Parent,
+		State:      result.PreviousState,
+		Actions:    r.actions,
+		Guards:     result.Guards,
+		Guard:      result.Guard,
+		Next:       result.NextState,
+		OnError:    result.OnError,
+		ErrorMatch: result.ErrorMatch,
+		TimedOut:   result.TimedOut,
+		Time:       result.Time,
+		Done:       result.Done,
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	for _, compensation := range result.Compensations {
+		traced := TraceCompensation{State: compensation.State, Name: compensation.Action}
+		if compensation.Err != nil {
+			traced.Error = compensation.Err.Error()
+		}
+
+		record.Compensations = append(record.Compensations, traced)
+	}
+
+	r.actions = nil
+
+	if err := r.encoder.Encode(record); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// Err returns the first error that occurred while writing the trace.
+func (r *TraceRecorder) Err() error {
+	return r.err
+}
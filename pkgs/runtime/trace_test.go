@@ -0,0 +1,61 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package runtime_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mhersson/vectorsigma/pkgs/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceRecorder(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	r := runtime.NewTraceRecorder(&buf)
+	r.Stepped(runtime.StepResult{PreviousState: runtime.InitialState, NextState: "Fetching"}, nil)
+	r.ActionAttempted(runtime.ActionAttempt{
+		State: "Fetching", Action: "Fetch", Attempt: 1, Duration: time.Millisecond, Err: errTimeout,
+	})
+	r.Stepped(runtime.StepResult{
+		PreviousState: "Fetching",
+		NextState:     runtime.FinalState,
+		Guard:         "IsError",
+		Guards:        []runtime.GuardResult{{Name: "IsError", Passed: true}},
+		Done:          true,
+		Compensations: []runtime.Compensation{{State: "Authorizing", Action: "Revoke", Err: errTimeout}},
+	}, errors.New("stuck"))
+
+	want := `{"step":1,"state":"InitialState","next":"Fetching"}
+{"step":2,"state":"Fetching","actions":[{"name":"Fetch","attempt":1,"duration":1000000,"error":"timeout"}],` +
+		`"guards":[{"name":"IsError","passed":true}],"guard":"IsError","next":"FinalState","error":"stuck","done":true,` +
+		`"compensations":[{"state":"Authorizing","name":"Revoke","error":"timeout"}]}
+`
+
+	assert.Equal(t, want, buf.String())
+	assert.NoError(t, r.Err())
+}
@@ -0,0 +1,258 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+)
+
+const (
+	highlightState      = "#palegreen"
+	highlightTransition = "[#green,bold]"
+)
+
+// Record is a single step in an execution trace, as written by the
+// TraceRecorder of a generated state machine.
+type Record struct {
+	Step          int            `json:"step"`
+	Parent        string         `json:"parent,omitempty"`
+	State         string         `json:"state"`
+	Actions       []Action       `json:"actions,omitempty"`
+	Guards        []Guard        `json:"guards,omitempty"`
+	Guard         string         `json:"guard,omitempty"`
+	Next          string         `json:"next"`
+	OnError       bool           `json:"onError,omitempty"`
+	ErrorMatch    string         `json:"errorMatch,omitempty"`
+	TimedOut      bool           `json:"timedOut,omitempty"`
+	Time          string         `json:"time,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	Done          bool           `json:"done,omitempty"`
+	Compensations []Compensation `json:"compensations,omitempty"`
+}
+
+// Action is a single attempt to execute an action.
+type Action struct {
+	Name     string        `json:"name"`
+	Attempt  int           `json:"attempt"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Compensation is a compensating action run when the machine ended on an
+// error path.
+type Compensation struct {
+	State string `json:"state"`
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// Guard is the result of a single guard evaluation.
+type Guard struct {
+	Name   string   `json:"name"`
+	Params []string `json:"params,omitempty"`
+	Passed bool     `json:"passed"`
+}
+
+type transition struct {
+	from, to string
+}
+
+// Edge returns the transition of the chart taken in the step. The guard is
+// left out of error, timeout and time transitions, as they are not guarded.
+func (r Record) Edge() uml.Edge {
+	edge := uml.Edge{From: r.State, To: r.Next, Parent: r.Parent}
+
+	switch {
+	case r.OnError:
+		edge.OnError = true
+		edge.Error = r.ErrorMatch
+	case r.TimedOut:
+		edge.OnTimeout = true
+	case r.Time != "":
+		edge.Time = r.Time
+	default:
+		edge.Guard = r.Guard
+	}
+
+	return edge
+}
+
+// Read reads a trace written as lines of JSON.
+func Read(r io.Reader) ([]Record, error) {
+	var records []Record
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to read trace at line %d: %w", line, err)
+		}
+
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trace: %w", err)
+	}
+
+	return records, nil
+}
+
+// Print writes the path taken in the trace to w. Transitions that are not in
+// the chart are marked, as the trace may have been recorded with an older
+// version of the chart.
+func Print(w io.Writer, fsm *uml.FSM, records []Record) error {
+	known := transitions(fsm)
+
+	var sb strings.Builder
+
+	for _, record := range records {
+		fmt.Fprintf(&sb, "%d: %s -> %s", record.Step, record.State, record.Next)
+
+		if record.Guard != "" {
+			fmt.Fprintf(&sb, " [%s]", record.Guard)
+		}
+
+		if record.TimedOut {
+			sb.WriteString(" on timeout")
+		}
+
+		if record.State != record.Next && !known[transition{record.State, record.Next}] {
+			sb.WriteString(" (not in chart)")
+		}
+
+		sb.WriteString("\n")
+
+		for _, action := range record.Actions {
+			if action.Error != "" {
+				fmt.Fprintf(&sb, "    %s attempt %d failed after %s: %s\n", action.Name, action.Attempt, action.Duration, action.Error)
+			} else {
+				fmt.Fprintf(&sb, "    %s attempt %d took %s\n", action.Name, action.Attempt, action.Duration)
+			}
+		}
+
+		if len(record.Guards) > 0 {
+			results := make([]string, 0, len(record.Guards))
+			for _, guard := range record.Guards {
+				results = append(results, fmt.Sprintf("%s=%t", guard.Name, guard.Passed))
+			}
+
+			fmt.Fprintf(&sb, "    guards: %s\n", strings.Join(results, ", "))
+		}
+
+		if record.Error != "" {
+			fmt.Fprintf(&sb, "    error: %s\n", record.Error)
+		}
+
+		for _, compensation := range record.Compensations {
+			if compensation.Error != "" {
+				fmt.Fprintf(&sb, "    compensated %s with %s, which failed: %s\n",
+					compensation.State, compensation.Name, compensation.Error)
+			} else {
+				fmt.Fprintf(&sb, "    compensated %s with %s\n", compensation.State, compensation.Name)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+
+	return err
+}
+
+// Highlight returns the chart with the states and transitions visited in the
+// trace highlighted. The transitions are told apart by their guard and kind, see
+// Record.Edge, so only the one of several transitions between two states that
+// was taken is highlighted.
+func Highlight(chart string, fsm *uml.FSM, records []Record) string {
+	visitedStates := make(map[string]bool)
+	visitedTransitions := make(map[uml.Edge]bool)
+
+	for _, record := range records {
+		visitedStates[record.State] = true
+		visitedStates[record.Next] = true
+		visitedTransitions[record.Edge()] = true
+	}
+
+	return uml.Annotate(chart, fsm,
+		func(state string) string {
+			if visitedStates[state] {
+				return highlightState
+			}
+
+			return ""
+		},
+		func(edge uml.Edge) string {
+			if visitedTransitions[edge] {
+				return highlightTransition
+			}
+
+			return ""
+		})
+}
+
+// transitions returns all transitions in the chart, including entering the
+// initial state of composite states.
+func transitions(fsm *uml.FSM) map[transition]bool {
+	known := make(map[transition]bool)
+
+	for _, edge := range fsm.Edges() {
+		known[transition{edge.From, edge.To}] = true
+	}
+
+	for _, state := range fsm.AllStates {
+		if composite := findState(fsm.States, state); composite != nil && composite.Composite.States != nil {
+			known[transition{state, composite.Composite.InitialState}] = true
+		}
+	}
+
+	return known
+}
+
+// findState returns the state with the given name, searching composite states.
+func findState(states map[string]*uml.State, name string) *uml.State {
+	if state, ok := states[name]; ok {
+		return state
+	}
+
+	for _, state := range states {
+		if state.Composite.States != nil {
+			if found := findState(state.Composite.States, name); found != nil {
+				return found
+			}
+		}
+	}
+
+	return nil
+}
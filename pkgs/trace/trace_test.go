@@ -0,0 +1,263 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package trace_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mhersson/vectorsigma/pkgs/trace"
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const chart = `@startuml
+title Fetcher
+[*] --> Fetching
+Fetching: do / Fetch
+Fetching -[dotted]-> Failed : [ IsError ]
+Fetching -[bold]-> [*]
+Failed --> [*]
+@enduml`
+
+const traceData = `{"step":1,"state":"InitialState","next":"Fetching"}
+{"step":2,"state":"Fetching","actions":[{"name":"Fetch","attempt":1,"duration":1000000,"error":"timeout"}],"guards":[{"name":"IsError","passed":true}],"guard":"IsError","next":"Failed"}
+
+{"step":3,"state":"Failed","next":"FinalState","done":true}
+`
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    int
+		wantErr bool
+	}{
+		{name: "OK", data: traceData, want: 3},
+		{name: "Empty", data: "", want: 0},
+		{name: "Invalid JSON", data: `{"step":1,` + "\n", wantErr: true},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := trace.Read(strings.NewReader(tt.data))
+			if tt.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Len(t, got, tt.want)
+		})
+	}
+}
+
+func TestRead_Fields(t *testing.T) {
+	t.Parallel()
+
+	records, err := trace.Read(strings.NewReader(traceData))
+	require.NoError(t, err)
+
+	assert.Equal(t, trace.Record{
+		Step:    2,
+		State:   "Fetching",
+		Actions: []trace.Action{{Name: "Fetch", Attempt: 1, Duration: time.Millisecond, Error: "timeout"}},
+		Guards:  []trace.Guard{{Name: "IsError", Passed: true}},
+		Guard:   "IsError",
+		Next:    "Failed",
+	}, records[1])
+}
+
+func TestPrint(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []trace.Record
+		want    string
+	}{
+		{
+			name: "Path",
+			records: []trace.Record{
+				{Step: 1, State: "InitialState", Next: "Fetching"},
+				{
+					Step: 2, State: "Fetching", Next: "Failed", Guard: "IsError",
+					Actions: []trace.Action{
+						{Name: "Fetch", Attempt: 1, Duration: time.Millisecond, Error: "timeout"},
+						{Name: "Fetch", Attempt: 2, Duration: 2 * time.Millisecond},
+					},
+					Guards: []trace.Guard{{Name: "IsError", Passed: true}},
+				},
+			},
+			want: `1: InitialState -> Fetching
+2: Fetching -> Failed [IsError]
+    Fetch attempt 1 failed after 1ms: timeout
+    Fetch attempt 2 took 2ms
+    guards: IsError=true
+`,
+		},
+		{
+			name: "Transition not in chart",
+			records: []trace.Record{
+				{Step: 1, State: "Fetching", Next: "Retrying"},
+			},
+			want: "1: Fetching -> Retrying (not in chart)\n",
+		},
+		{
+			name: "Timeout",
+			records: []trace.Record{
+				{Step: 1, State: "Fetching", Next: "Failed", TimedOut: true},
+			},
+			want: "1: Fetching -> Failed on timeout\n",
+		},
+		{
+			name: "Failed step",
+			records: []trace.Record{
+				{Step: 1, State: "Failed", Next: "Failed", Error: "no transition from state Failed []"},
+			},
+			want: "1: Failed -> Failed\n    error: no transition from state Failed []\n",
+		},
+		{
+			name: "Compensations",
+			records: []trace.Record{
+				{
+					Step: 1, State: "Failed", Next: "FinalState", Done: true,
+					Compensations: []trace.Compensation{
+						{State: "Fetching", Name: "Unfetch", Error: "gone"},
+						{State: "Authorizing", Name: "Revoke"},
+					},
+				},
+			},
+			want: `1: Failed -> FinalState
+    compensated Fetching with Unfetch, which failed: gone
+    compensated Authorizing with Revoke
+`,
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			require.NoError(t, trace.Print(&buf, uml.Parse(chart), tt.records))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	t.Parallel()
+
+	records, err := trace.Read(strings.NewReader(traceData))
+	require.NoError(t, err)
+
+	want := `@startuml
+title Fetcher
+[*] -[#green,bold]-> Fetching
+Fetching: do / Fetch
+Fetching -[#green,bold]-> Failed : [ IsError ]
+Fetching -[bold]-> [*]
+Failed -[#green,bold]-> [*]
+state Failed #palegreen
+state Fetching #palegreen
+@enduml`
+
+	assert.Equal(t, want, trace.Highlight(chart, uml.Parse(chart), records))
+}
+
+func TestHighlight_Composite(t *testing.T) {
+	t.Parallel()
+
+	composite := `@startuml
+[*] --> Working
+state Working {
+  [*] --> Inner
+  Inner --> [*]
+}
+Working --> [*]
+@enduml`
+
+	records := []trace.Record{
+		{Step: 1, State: "InitialState", Next: "Working"},
+		{Step: 2, State: "Working", Next: "InitialState"},
+		{Step: 3, Parent: "Working", State: "InitialState", Next: "Inner"},
+		{Step: 4, Parent: "Working", State: "Inner", Next: "FinalState"},
+		{Step: 5, State: "Working", Next: "FinalState", Done: true},
+	}
+
+	want := `@startuml
+[*] -[#green,bold]-> Working
+state Working {
+  [*] -[#green,bold]-> Inner
+  Inner -[#green,bold]-> [*]
+  state Inner #palegreen
+}
+Working -[#green,bold]-> [*]
+state Working #palegreen
+@enduml`
+
+	assert.Equal(t, want, trace.Highlight(composite, uml.Parse(composite), records))
+}
+
+func TestHighlight_SameStates(t *testing.T) {
+	t.Parallel()
+
+	chart := `@startuml
+[*] --> Fetching
+Fetching: do / Fetch
+Fetching --> Retrying : [ IsBusy ]
+Fetching --> Retrying : on error(ErrTimeout)
+Fetching --> Retrying : after(5s)
+Fetching --> Retrying
+Retrying --> [*]
+@enduml`
+
+	records := []trace.Record{
+		{Step: 1, State: "InitialState", Next: "Fetching"},
+		{Step: 2, State: "Fetching", Next: "Retrying", OnError: true, ErrorMatch: "ErrTimeout"},
+		{Step: 3, State: "Retrying", Next: "FinalState", Done: true},
+	}
+
+	want := `@startuml
+[*] -[#green,bold]-> Fetching
+Fetching: do / Fetch
+Fetching --> Retrying : [ IsBusy ]
+Fetching -[#green,bold]-> Retrying : on error(ErrTimeout)
+Fetching --> Retrying : after(5s)
+Fetching --> Retrying
+Retrying -[#green,bold]-> [*]
+state Fetching #palegreen
+state Retrying #palegreen
+@enduml`
+
+	assert.Equal(t, want, trace.Highlight(chart, uml.Parse(chart), records))
+}
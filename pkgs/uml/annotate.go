@@ -0,0 +1,215 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package uml
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// StartingConversation -[bold]-> FinalState : [ isError ] or [*] --> StartingConversation.
+	arrowPattern = `^(\s*)(\[\*\]|\w+)(\s*)-(\[[^\]]*\])?(left|right|up|down)?->(\s*)(\[\*\]|\w+)(.*)$`
+	// Closing brace of a composite state.
+	compositeEndPattern = `^(\s*)}\s*$`
+	endPattern          = `^(\s*)@enduml`
+)
+
+// Edge is a single transition in the chart.
+type Edge struct {
+	From      string
+	To        string
+	Guard     string // The guard of the transition, empty if unguarded
+	OnError   bool   // True for error transitions
+	Error     string // The error accepted by an error transition, empty for all errors
+	OnTimeout bool   // True for the transition taken when the state times out
+	Time      string // The time event of a time transition, like after(10s)
+	Parent    string // The composite state the transition is in, empty at the top level
+}
+
+// Edges returns all transitions in the chart, including the transitions inside
+// composite states, sorted by state.
+func (f *FSM) Edges() []Edge {
+	edges := edgesOf(f.States, "")
+
+	slices.SortFunc(edges, func(a, b Edge) int {
+		return cmp.Or(
+			strings.Compare(a.From, b.From),
+			strings.Compare(a.To, b.To),
+			strings.Compare(a.Guard, b.Guard),
+			strings.Compare(a.Error, b.Error),
+			strings.Compare(a.Time, b.Time),
+			strings.Compare(a.Parent, b.Parent),
+		)
+	})
+
+	return edges
+}
+
+func edgesOf(states map[string]*State, parent string) []Edge {
+	var edges []Edge
+
+	for name, state := range states {
+		for _, t := range state.Transitions {
+			edges = append(edges, Edge{From: name, To: t.Target, Guard: t.Guard, Parent: parent})
+		}
+
+		for _, t := range state.ErrorTransitions {
+			edges = append(edges, Edge{From: name, To: t.Target, OnError: true, Error: t.Error, Parent: parent})
+		}
+
+		if state.TimeoutTarget != "" {
+			edges = append(edges, Edge{From: name, To: state.TimeoutTarget, OnTimeout: true, Parent: parent})
+		}
+
+		for _, t := range state.TimeTransitions {
+			edges = append(edges, Edge{From: name, To: t.Target, Time: t.Event(), Parent: parent})
+		}
+
+		if state.Composite.States != nil {
+			edges = append(edges, edgesOf(state.Composite.States, name)...)
+		}
+	}
+
+	return edges
+}
+
+// Annotate returns the chart with styles added to its states and transitions.
+// stateStyle returns the color of a state, like "#palegreen", and edgeStyle the
+// style of a transition, like "[#green,bold]". An empty style leaves the state
+// or transition unchanged.
+func Annotate(chart string, fsm *FSM, stateStyle func(state string) string, edgeStyle func(edge Edge) string) string {
+	arrow := regexp.MustCompile(arrowPattern)
+	compositeStart := regexp.MustCompile(compositeStateStartPattern)
+	compositeEnd := regexp.MustCompile(compositeEndPattern)
+	end := regexp.MustCompile(endPattern)
+
+	var (
+		out        []string
+		composites []*State
+		parents    []string
+		ended      bool
+	)
+
+	for _, line := range strings.Split(chart, "\n") {
+		if m := compositeStart.FindStringSubmatch(line); m != nil {
+			composites = append(composites, compositeOf(fsm, composites, m[1]))
+			parents = append(parents, m[1])
+		} else if m := compositeEnd.FindStringSubmatch(line); m != nil && len(composites) > 0 {
+			if composite := composites[len(composites)-1]; composite != nil {
+				out = append(out, stateStyles(m[1]+"  ", composite.Composite.States, stateStyle)...)
+			}
+
+			composites = composites[:len(composites)-1]
+			parents = parents[:len(parents)-1]
+		} else if m := end.FindStringSubmatch(line); m != nil {
+			out = append(out, stateStyles(m[1], fsm.States, stateStyle)...)
+			ended = true
+		} else if m := arrow.FindStringSubmatch(line); m != nil {
+			edge := parseEdge(m[2], m[7], m[8])
+			if len(parents) > 0 {
+				edge.Parent = parents[len(parents)-1]
+			}
+
+			if style := edgeStyle(edge); style != "" {
+				line = m[1] + m[2] + m[3] + "-" + style + m[5] + "->" + m[6] + m[7] + m[8]
+			}
+		}
+
+		out = append(out, line)
+	}
+
+	if !ended {
+		out = append(out, stateStyles("", fsm.States, stateStyle)...)
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// parseEdge parses a single transition from the chart, given the states on
+// each side of the arrow and the rest of the line.
+func parseEdge(from, to, rest string) Edge {
+	if from == "[*]" {
+		from = InitialState
+	}
+
+	if to == "[*]" {
+		to = FinalState
+	}
+
+	edge := Edge{From: from, To: to}
+	line := from + " --> " + to + rest
+	f := &FSM{States: make(map[string]*State)}
+
+	switch {
+	case f.IsErrorTransition(line):
+		edge.OnError = true
+		edge.Error = f.States[from].ErrorTransitions[0].Error
+	case f.IsTimeoutTransition(line):
+		edge.OnTimeout = true
+	case f.IsTimeTransition(line):
+		edge.Time = f.States[from].TimeTransitions[0].Event()
+	case f.IsGuardedTransition(line):
+		edge.Guard = f.States[from].Transitions[0].Guard
+	}
+
+	return edge
+}
+
+// compositeOf returns the composite state with the given name, inside the
+// innermost composite state of the stack, or nil if it is unknown.
+func compositeOf(fsm *FSM, stack []*State, name string) *State {
+	states := fsm.States
+
+	if len(stack) > 0 {
+		parent := stack[len(stack)-1]
+		if parent == nil {
+			return nil
+		}
+
+		states = parent.Composite.States
+	}
+
+	return states[name]
+}
+
+// stateStyles returns the declarations adding a style to the states.
+func stateStyles(indent string, states map[string]*State, stateStyle func(state string) string) []string {
+	var lines []string
+
+	for name := range states {
+		if name == InitialState || name == FinalState {
+			continue
+		}
+
+		if style := stateStyle(name); style != "" {
+			lines = append(lines, fmt.Sprintf("%sstate %s %s", indent, name, style))
+		}
+	}
+
+	slices.Sort(lines)
+
+	return lines
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package uml_test
+
+import (
+	"testing"
+
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+	"github.com/stretchr/testify/assert"
+)
+
+const annotateChart = `@startuml
+[*] --> Fetching
+Fetching --> Retrying : on error(ErrTimeout)
+Fetching -[dotted]-> Failed : [ IsError ]
+Fetching -[bold]down-> Working
+state Working {
+  [*] --> Inner
+  Inner --> [*]
+}
+Working --> [*]
+Retrying --> Fetching
+Retrying --> Failed : on timeout
+Retrying --> Fetching : after(5s)
+Failed --> [*]
+@enduml`
+
+func TestFSM_Edges(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []uml.Edge{
+		{From: "Failed", To: "FinalState"},
+		{From: "Fetching", To: "Failed", Guard: "IsError"},
+		{From: "Fetching", To: "Retrying", OnError: true, Error: "ErrTimeout"},
+		{From: "Fetching", To: "Working"},
+		{From: "InitialState", To: "Fetching"},
+		{From: "InitialState", To: "Inner", Parent: "Working"},
+		{From: "Inner", To: "FinalState", Parent: "Working"},
+		{From: "Retrying", To: "Failed", OnTimeout: true},
+		{From: "Retrying", To: "Fetching"},
+		{From: "Retrying", To: "Fetching", Time: "after(5s)"},
+		{From: "Working", To: "FinalState"},
+	}, uml.Parse(annotateChart).Edges())
+}
+
+func TestAnnotate(t *testing.T) {
+	t.Parallel()
+
+	fsm := uml.Parse(annotateChart)
+
+	got := uml.Annotate(annotateChart, fsm,
+		func(state string) string {
+			if state == "Inner" || state == "Failed" {
+				return "#pink"
+			}
+
+			return ""
+		},
+		func(edge uml.Edge) string {
+			if edge.Guard == "IsError" || edge.OnError || edge.OnTimeout || edge.To == "Working" ||
+				edge.Parent == "Working" && edge.To == "FinalState" {
+				return "[#red]"
+			}
+
+			return ""
+		})
+
+	assert.Equal(t, `@startuml
+[*] --> Fetching
+Fetching -[#red]-> Retrying : on error(ErrTimeout)
+Fetching -[#red]-> Failed : [ IsError ]
+Fetching -[#red]down-> Working
+state Working {
+  [*] --> Inner
+  Inner -[#red]-> [*]
+  state Inner #pink
+}
+Working --> [*]
+Retrying --> Fetching
+Retrying -[#red]-> Failed : on timeout
+Retrying --> Fetching : after(5s)
+Failed --> [*]
+state Failed #pink
+@enduml`, got)
+}
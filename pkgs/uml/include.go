@@ -0,0 +1,362 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package uml
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// state Authenticating : include auth.puml.
+	stateIncludePattern = `^\s*state\s+(\w+)\s*:\s*include\s+(\S+)\s*$`
+	// !include auth.puml.
+	includePattern = `^(\s*)!include\s+(\S+)\s*$`
+	// @startuml, @enduml, title and skin lines, left out of included charts.
+	includedHeaderPattern = `^\s*(@startuml|@enduml|title\s|skin\s)`
+	// Comments and the PlantUML commands that only change how the chart is drawn.
+	ignoredPattern = `^\s*('|skinparam\s|hide\s|show\s|scale\s|left to right direction\s*$|note\s)`
+	// The first line of a note written on several lines, ended by end note.
+	noteStartPattern = `^\s*note\s+(((left|right|top|bottom)\s+of\s+\w+)|(as\s+\w+))\s*$`
+	noteEndPattern   = `^\s*end\s*note\s*$`
+	endumlPattern    = `^\s*@enduml`
+)
+
+var (
+	stateIncludeRegexp   = regexp.MustCompile(stateIncludePattern)
+	includeRegexp        = regexp.MustCompile(includePattern)
+	includedHeaderRegexp = regexp.MustCompile(includedHeaderPattern)
+	ignoredRegexp        = regexp.MustCompile(ignoredPattern)
+	noteStartRegexp      = regexp.MustCompile(noteStartPattern)
+	noteEndRegexp        = regexp.MustCompile(noteEndPattern)
+	endumlRegexp         = regexp.MustCompile(endumlPattern)
+	compositeStartRegexp = regexp.MustCompile(compositeStateStartPattern)
+	compositeEndRegexp   = regexp.MustCompile(compositeStateEndPattern)
+	initialStateRegexp   = regexp.MustCompile(firstInitialStatePattern)
+	arrowRegexp          = regexp.MustCompile(arrowPattern)
+)
+
+// ErrIncludeCycle is matched by errors returned when a chart includes itself,
+// directly or through other charts.
+var ErrIncludeCycle = errors.New("include cycle")
+
+// IncludeError is returned when an included chart cannot be inlined. File and
+// Line locate the problem, which is the include itself or a line of the
+// included chart, with the lines of a markdown file counted from its plantuml
+// code block.
+type IncludeError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *IncludeError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *IncludeError) Unwrap() error {
+	return e.Err
+}
+
+// ResolveIncludes returns the chart with the charts it includes inlined. The
+// chart is the content of the file at path, and read returns the content of the
+// included files, which can also be markdown files with a plantuml code block.
+// The included paths are relative to the file including them.
+//
+// A composite state written as
+//
+//	state Authenticating : include auth.puml
+//
+// gets the states of auth.puml, and a line with
+//
+//	!include auth.puml
+//
+// is replaced by the states of auth.puml. The title of an included chart is
+// left out. Composite states cannot be nested, so a chart included in a
+// composite state cannot have composite states of its own.
+//
+// The lines of an included chart must be understood by Parse, and a chart
+// included in a composite state may only enter its own states. Otherwise an
+// IncludeError locates the line in the included chart.
+func ResolveIncludes(chart, path string, read func(path string) ([]byte, error)) (string, error) {
+	r := includeResolver{read: read}
+
+	lines, err := r.resolve(chart, path)
+	if err != nil {
+		return "", err
+	}
+
+	return join(lines), nil
+}
+
+// includeResolver inlines the included charts, and keeps the files being
+// inlined to detect cycles.
+type includeResolver struct {
+	read  func(path string) ([]byte, error)
+	stack []string
+}
+
+// sourceLine is a line of a resolved chart, with the file and the line it was
+// read from.
+type sourceLine struct {
+	text string
+	file string
+	line int
+}
+
+func (r *includeResolver) resolve(chart, path string) ([]sourceLine, error) {
+	r.stack = append(r.stack, path)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	lines := strings.Split(chart, "\n")
+	resolved := make([]sourceLine, 0, len(lines))
+	composite := false
+
+	for i, line := range lines {
+		fail := func(err error) ([]sourceLine, error) {
+			var includeErr *IncludeError
+			if errors.As(err, &includeErr) {
+				// The error is located in the included chart
+				return nil, err
+			}
+
+			return nil, &IncludeError{File: path, Line: i + 1, Err: err}
+		}
+
+		switch {
+		case compositeStartRegexp.MatchString(line):
+			composite = true
+		case compositeEndRegexp.MatchString(line):
+			composite = false
+		}
+
+		if m := stateIncludeRegexp.FindStringSubmatch(line); m != nil {
+			if composite {
+				return fail(fmt.Errorf("composite state %s cannot be nested", m[1]))
+			}
+
+			included, err := r.include(path, m[2], true)
+			if err != nil {
+				return fail(err)
+			}
+
+			if !slices.ContainsFunc(included, func(line sourceLine) bool { return initialStateRegexp.MatchString(line.text) }) {
+				return fail(fmt.Errorf("%s has no initial state", m[2]))
+			}
+
+			if err := checkStates(included); err != nil {
+				return fail(err)
+			}
+
+			resolved = append(resolved, sourceLine{text: "state " + m[1] + " {", file: path, line: i + 1})
+			resolved = append(resolved, indent(included, "  ")...)
+			resolved = append(resolved, sourceLine{text: "}", file: path, line: i + 1})
+
+			continue
+		}
+
+		if m := includeRegexp.FindStringSubmatch(line); m != nil {
+			included, err := r.include(path, m[2], composite)
+			if err != nil {
+				return fail(err)
+			}
+
+			resolved = append(resolved, indent(included, m[1])...)
+
+			continue
+		}
+
+		resolved = append(resolved, sourceLine{text: line, file: path, line: i + 1})
+	}
+
+	return resolved, nil
+}
+
+// include returns the lines of the chart included by the file at from, nested
+// in a composite state when inComposite is true.
+func (r *includeResolver) include(from, target string, inComposite bool) ([]sourceLine, error) {
+	path := filepath.Join(filepath.Dir(from), target)
+
+	for i, including := range r.stack {
+		if sameFile(including, path) {
+			cycle := append(r.stack[i:len(r.stack):len(r.stack)], path)
+
+			return nil, fmt.Errorf("%w: %s", ErrIncludeCycle, strings.Join(cycle, " -> "))
+		}
+	}
+
+	content, err := r.read(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to include %s: %w", target, err)
+	}
+
+	chart := string(content)
+	if filepath.Ext(path) == ".md" {
+		if chart, err = ExtractFromMarkdown(chart); err != nil {
+			return nil, fmt.Errorf("failed to include %s: %w", target, err)
+		}
+	}
+
+	resolved, err := r.resolve(chart, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []sourceLine
+
+	inNote := false
+
+	for _, line := range resolved {
+		if endumlRegexp.MatchString(line.text) {
+			// PlantUML ignores what follows the chart
+			break
+		}
+
+		if includedHeaderRegexp.MatchString(line.text) {
+			continue
+		}
+
+		switch {
+		case inNote:
+			inNote = !noteEndRegexp.MatchString(line.text)
+			lines = append(lines, line)
+
+			continue
+		case noteStartRegexp.MatchString(line.text):
+			inNote = true
+		}
+
+		if m := compositeStartRegexp.FindStringSubmatch(line.text); m != nil && inComposite {
+			return nil, &IncludeError{File: line.file, Line: line.line, Err: fmt.Errorf("composite state %s cannot be nested", m[1])}
+		}
+
+		if !recognized(line.text) {
+			return nil, &IncludeError{File: line.file, Line: line.line, Err: fmt.Errorf("unrecognized line: %s", strings.TrimSpace(line.text))}
+		}
+
+		lines = append(lines, line)
+	}
+
+	// Leave out the blank lines around the included chart
+	isBlank := func(line sourceLine) bool { return strings.TrimSpace(line.text) == "" }
+
+	for len(lines) > 0 && isBlank(lines[0]) {
+		lines = lines[1:]
+	}
+
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines, nil
+}
+
+// recognized reports whether Parse understands the line of a chart, or the line
+// is left out on purpose, like blank lines, comments and the lines only
+// changing how the chart is drawn.
+func recognized(line string) bool {
+	line = normalizeData(line)
+
+	if strings.TrimSpace(line) == "" || ignoredRegexp.MatchString(line) || includedHeaderRegexp.MatchString(line) ||
+		compositeStartRegexp.MatchString(line) || compositeEndRegexp.MatchString(line) {
+		return true
+	}
+
+	if initialStateRegexp.MatchString(line) {
+		line = strings.ReplaceAll(line, "[*]", InitialState)
+	} else {
+		line = strings.ReplaceAll(line, "[*]", FinalState)
+	}
+
+	f := &FSM{States: make(map[string]*State)}
+
+	return f.IsTitle(line) || f.IsInitialState(line) || f.IsStateTimeout(line) || f.IsCompensation(line) ||
+		f.IsEndState(line) || f.IsConcurrentActions(line) || f.IsAction(line) || f.IsErrorTransition(line) ||
+		f.IsTimeoutTransition(line) || f.IsTimeTransition(line) || f.IsGuardedTransition(line) ||
+		f.IsDefaultTransition(line)
+}
+
+// checkStates returns an IncludeError locating the first transition of a chart
+// included in a composite state to a state that is unknown to the chart. A
+// state is unknown when it has neither actions nor transitions, which happens
+// when the chart moves to a state of the including chart instead of to [*].
+func checkStates(lines []sourceLine) error {
+	fsm := Parse(join(lines))
+
+	for _, line := range lines {
+		m := arrowRegexp.FindStringSubmatch(line.text)
+		if m == nil || m[7] == "[*]" {
+			continue
+		}
+
+		state, ok := fsm.States[m[7]]
+		if ok && (len(state.Actions) > 0 || len(state.Transitions) > 0 || len(state.ErrorTransitions) > 0 ||
+			len(state.TimeTransitions) > 0 || state.TimeoutTarget != "" || state.End) {
+			continue
+		}
+
+		return &IncludeError{File: line.file, Line: line.line, Err: fmt.Errorf("unknown state %s", m[7])}
+	}
+
+	return nil
+}
+
+// join returns the text of the lines.
+func join(lines []sourceLine) string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.text
+	}
+
+	return strings.Join(texts, "\n")
+}
+
+// sameFile reports whether the paths name the same file.
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+
+	return absA == absB
+}
+
+// indent returns the lines with the prefix added to the lines that are not blank.
+func indent(lines []sourceLine, prefix string) []sourceLine {
+	indented := make([]sourceLine, len(lines))
+
+	for i, line := range lines {
+		if strings.TrimSpace(line.text) != "" {
+			line.text = prefix + line.text
+		}
+
+		indented[i] = line
+	}
+
+	return indented
+}
@@ -0,0 +1,230 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package uml_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var includeFiles = fstest.MapFS{
+	"charts/auth.puml": {Data: []byte(`@startuml
+title Auth
+skin rose
+[*] --> Checking
+Checking: do / CheckToken
+Checking --> Refreshing: on error(ErrExpired)
+Checking --> [*]
+Refreshing: do / RefreshToken
+Refreshing --> [*]
+@enduml
+`)},
+	"charts/flows/auth.md": {Data: []byte("# Auth\n\n```plantuml\n@startuml\n[*] --> Checking\n" +
+		"Checking: do / CheckToken\nChecking --> [*]\n@enduml\n```\n")},
+	"charts/steps.puml": {Data: []byte("@startuml\nFetching: do / Fetch\nFetching --> [*]\n@enduml\n")},
+	"charts/nested.puml": {Data: []byte(`@startuml
+[*] --> Outer
+state Outer : include auth.puml
+Outer --> [*]
+@enduml
+`)},
+	"charts/broken.puml": {Data: []byte(`@startuml
+[*] --> Checking
+Checking: do / CheckToken
+Checking -> Done
+Done: do / Finish
+Done --> [*]
+@enduml
+`)},
+	"charts/flows/broken.md": {Data: []byte("# Broken\n\n```plantuml\n@startuml\n[*] --> Checking\n" +
+		"Checking: do / CheckToken(\nChecking --> [*]\n@enduml\n```\n")},
+	"charts/unknown.puml": {Data: []byte(`@startuml
+[*] --> Checking
+Checking: do / CheckToken
+Checking --> Shipping: [IsValid]
+Checking --> [*]
+@enduml
+`)},
+	"charts/loop/a.puml":      {Data: []byte("@startuml\n!include b.puml\n@enduml\n")},
+	"charts/loop/b.puml":      {Data: []byte("@startuml\n[*] --> B\n\n!include a.puml\n@enduml\n")},
+	"charts/noinitial.puml":   {Data: []byte("@startuml\nA --> [*]\n@enduml\n")},
+	"charts/includes/ok.puml": {Data: []byte("@startuml\n!include ../missing.puml\n@enduml\n")},
+}
+
+func readInclude(path string) ([]byte, error) {
+	return fs.ReadFile(includeFiles, path)
+}
+
+func TestResolveIncludes(t *testing.T) {
+	tests := []struct {
+		name    string
+		chart   string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "composite state",
+			chart: `@startuml
+title Order
+[*] --> Authenticating
+state Authenticating : include auth.puml
+Authenticating --> [*]
+@enduml`,
+			want: `@startuml
+title Order
+[*] --> Authenticating
+state Authenticating {
+  [*] --> Checking
+  Checking: do / CheckToken
+  Checking --> Refreshing: on error(ErrExpired)
+  Checking --> [*]
+  Refreshing: do / RefreshToken
+  Refreshing --> [*]
+}
+Authenticating --> [*]
+@enduml`,
+		},
+		{
+			name: "markdown chart in a subdirectory",
+			chart: `[*] --> Authenticating
+state Authenticating : include flows/auth.md`,
+			want: `[*] --> Authenticating
+state Authenticating {
+  [*] --> Checking
+  Checking: do / CheckToken
+  Checking --> [*]
+}`,
+		},
+		{
+			name: "include line",
+			chart: `[*] --> Loading
+state Loading {
+  [*] --> Fetching
+  !include steps.puml
+}`,
+			want: `[*] --> Loading
+state Loading {
+  [*] --> Fetching
+  Fetching: do / Fetch
+  Fetching --> [*]
+}`,
+		},
+		{
+			name:    "missing chart",
+			chart:   "[*] --> A\n\nstate A : include missing.puml",
+			wantErr: "charts/main.puml:3: failed to include missing.puml",
+		},
+		{
+			name:    "missing chart in an included chart",
+			chart:   "!include includes/ok.puml",
+			wantErr: "charts/includes/ok.puml:2: failed to include ../missing.puml",
+		},
+		{
+			name:    "cycle",
+			chart:   "!include loop/a.puml",
+			wantErr: "charts/loop/b.puml:4: include cycle: charts/loop/a.puml -> charts/loop/b.puml -> charts/loop/a.puml",
+		},
+		{
+			name:    "nested composite states",
+			chart:   "[*] --> A\nstate A : include nested.puml",
+			wantErr: "charts/nested.puml:3: composite state Outer cannot be nested",
+		},
+		{
+			name:    "unrecognized line in an included chart",
+			chart:   "[*] --> A\nstate A : include broken.puml",
+			wantErr: "charts/broken.puml:4: unrecognized line: Checking -> Done",
+		},
+		{
+			name:    "unrecognized line in an included markdown chart",
+			chart:   "!include flows/broken.md",
+			wantErr: "charts/flows/broken.md:4: unrecognized line: Checking: do / CheckToken(",
+		},
+		{
+			name:    "unknown state in an included chart",
+			chart:   "[*] --> A\nstate A : include unknown.puml\nShipping: do / Ship",
+			wantErr: "charts/unknown.puml:4: unknown state Shipping",
+		},
+		{
+			name:    "composite state in a composite state",
+			chart:   "state A {\n  state B : include auth.puml\n}",
+			wantErr: "charts/main.puml:2: composite state B cannot be nested",
+		},
+		{
+			name:    "no initial state",
+			chart:   "state A : include noinitial.puml",
+			wantErr: "charts/main.puml:1: noinitial.puml has no initial state",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := uml.ResolveIncludes(tt.chart, "charts/main.puml", readInclude)
+			if tt.wantErr != "" {
+				var includeErr *uml.IncludeError
+
+				require.ErrorAs(t, err, &includeErr)
+				assert.ErrorContains(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolveIncludes_Parse(t *testing.T) {
+	chart, err := uml.ResolveIncludes(`@startuml
+title Order
+[*] --> Authenticating
+state Authenticating : include auth.puml
+Authenticating --> Reauthenticating
+state Reauthenticating : include auth.puml
+Reauthenticating --> [*]
+@enduml`, "charts/main.puml", readInclude)
+	require.NoError(t, err)
+
+	fsm := uml.Parse(chart)
+
+	assert.Equal(t, "Order", fsm.Title)
+	assert.Equal(t, []string{"CheckToken", "RefreshToken"}, fsm.ActionNames)
+	assert.Equal(t, []string{"ErrExpired"}, fsm.ErrorNames)
+
+	for _, state := range []string{"Authenticating", "Reauthenticating"} {
+		composite := fsm.States[state].Composite
+		assert.Equal(t, uml.InitialState, composite.InitialState, state)
+		assert.Equal(t, "Checking", composite.States[uml.InitialState].Transitions[0].Target, state)
+		assert.Contains(t, composite.States, "Refreshing", state)
+	}
+
+	assert.ErrorIs(t, func() error {
+		_, err := uml.ResolveIncludes("!include loop/a.puml", "charts/main.puml", readInclude)
+
+		return err
+	}(), uml.ErrIncludeCycle)
+}
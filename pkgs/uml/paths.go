@@ -0,0 +1,294 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package uml
+
+import (
+	"slices"
+	"time"
+)
+
+// Path is a path through the chart from the InitialState to the FinalState, or
+// to an end state.
+type Path struct {
+	// States holds the visited states in order, including the InitialState,
+	// FinalState and the states inside composite states.
+	States []string
+	// Choices holds the index of the transition taken at each visit of a state
+	// with guards. An index equal to the number of guards of the state means
+	// that all guards fail, and the unguarded transition is taken, or the time
+	// transition when the state has none.
+	Choices map[string][]int
+	// Errors holds the error transition taken at each visit of a state with
+	// actions and error transitions, nil when the actions succeed. It is nil
+	// when no such state is visited.
+	Errors map[string][]*ErrorTransition
+}
+
+type choice struct {
+	state string
+	index int
+}
+
+type errorChoice struct {
+	state      string
+	transition *ErrorTransition
+}
+
+type partialPath struct {
+	states  []string
+	choices []choice
+	errors  []errorChoice
+}
+
+// usedTransition is a transition taken in the current path. The index counts
+// the transitions of the state, followed by its error transitions and its time
+// transitions.
+type usedTransition struct {
+	state string
+	index int
+}
+
+// pathEpoch is the time on the clock of the path tests, midnight. The delays of
+// the time transitions pass at once, and the machine takes the one with the
+// shortest delay at pathEpoch.
+var pathEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Paths returns up to limit paths from the InitialState to the FinalState, or
+// to an end state.
+// Each transition is taken at most once in a path, so every loop is run
+// through at most once. An error transition is followed by failing the first
+// action of the state with an error it matches, and not an earlier one. Of the
+// time transitions of a state, the one taken at midnight is followed.
+func (f *FSM) Paths(limit int) []Path {
+	partials := paths(f.States, limit)
+
+	result := make([]Path, 0, len(partials))
+
+	for _, partial := range partials {
+		path := Path{States: partial.states, Choices: make(map[string][]int)}
+		for _, c := range partial.choices {
+			path.Choices[c.state] = append(path.Choices[c.state], c.index)
+		}
+
+		for _, e := range partial.errors {
+			if path.Errors == nil {
+				path.Errors = make(map[string][]*ErrorTransition)
+			}
+
+			path.Errors[e.state] = append(path.Errors[e.state], e.transition)
+		}
+
+		result = append(result, path)
+	}
+
+	return result
+}
+
+func paths(states map[string]*State, limit int) []partialPath {
+	var (
+		result []partialPath
+		walk   func(state string, current partialPath)
+	)
+
+	used := make(map[usedTransition]bool)
+
+	// follow takes the transition of the state with the given index from each
+	// of the prefixes, unless the path has taken it already.
+	follow := func(state string, index int, target string, prefixes []partialPath, extend func(partialPath) partialPath) {
+		if used[usedTransition{state, index}] {
+			return
+		}
+
+		used[usedTransition{state, index}] = true
+
+		for _, prefix := range prefixes {
+			walk(target, extend(prefix))
+		}
+
+		used[usedTransition{state, index}] = false
+	}
+
+	walk = func(state string, current partialPath) {
+		if len(result) >= limit {
+			return
+		}
+
+		current.states = append(slices.Clip(current.states), state)
+
+		config, ok := states[state]
+
+		if state == FinalState || ok && config.End {
+			result = append(result, current)
+
+			return
+		}
+
+		if !ok {
+			return
+		}
+
+		prefixes := []partialPath{current}
+
+		if config.Composite.States != nil {
+			prefixes = nil
+
+			for _, inner := range paths(config.Composite.States, limit) {
+				prefixes = append(prefixes, partialPath{
+					states:  append(slices.Clip(current.states), inner.states...),
+					choices: append(slices.Clip(current.choices), inner.choices...),
+					errors:  append(slices.Clip(current.errors), inner.errors...),
+				})
+			}
+		}
+
+		failing := len(config.Actions) > 0 && len(config.ErrorTransitions) > 0
+
+		if failing {
+			for i := range config.ErrorTransitions {
+				transition := &config.ErrorTransitions[i]
+				if !config.reachesErrorTransition(i) {
+					continue
+				}
+
+				follow(state, len(config.Transitions)+i, transition.Target, prefixes, func(prefix partialPath) partialPath {
+					prefix.errors = append(slices.Clip(prefix.errors), errorChoice{state, transition})
+
+					return prefix
+				})
+			}
+
+			// The actions succeed on the other paths
+			for i := range prefixes {
+				prefixes[i].errors = append(slices.Clip(prefixes[i].errors), errorChoice{state: state})
+			}
+		}
+
+		guards := 0
+
+		for _, t := range config.Transitions {
+			if t.Guard != "" {
+				guards++
+			}
+		}
+
+		// extend records the choice of the transition among the guards
+		extend := func(index int) func(partialPath) partialPath {
+			return func(prefix partialPath) partialPath {
+				if guards > 0 {
+					prefix.choices = append(slices.Clip(prefix.choices), choice{state, index})
+				}
+
+				return prefix
+			}
+		}
+
+		guardIndex := 0
+		unguarded := false
+
+		for i, t := range config.Transitions {
+			index := guards
+
+			if t.Guard != "" {
+				index = guardIndex
+				guardIndex++
+			} else if unguarded {
+				// Only the first unguarded transition can be taken
+				continue
+			} else {
+				unguarded = true
+			}
+
+			follow(state, i, t.Target, prefixes, extend(index))
+		}
+
+		if !unguarded && len(config.TimeTransitions) > 0 {
+			// The time transition is taken when all guards fail
+			i := config.pathTimeTransition()
+			index := len(config.Transitions) + len(config.ErrorTransitions) + i
+
+			follow(state, index, config.TimeTransitions[i].Target, prefixes, extend(guards))
+		}
+	}
+
+	walk(InitialState, partialPath{})
+
+	return result
+}
+
+// reachesErrorTransition reports whether the error transition with the given
+// index can be taken, as it does not match all the errors matched by an
+// earlier one. An error of the deadline is taken by the timeout transition.
+func (s *State) reachesErrorTransition(index int) bool {
+	transition := s.ErrorTransitions[index]
+
+	if transition.Error == "*DeadlineError" && s.TimeoutTarget != "" {
+		return false
+	}
+
+	for _, earlier := range s.ErrorTransitions[:index] {
+		if earlier.Error == "" || earlier.Error == transition.Error {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pathTimeTransition returns the index of the time transition of the state
+// with the shortest delay at pathEpoch, the first one declared if several have
+// the same delay.
+func (s *State) pathTimeTransition() int {
+	delay := func(t TimeTransition) time.Duration {
+		if t.At == "" {
+			return t.After
+		}
+
+		layout := "15:04"
+		if len(t.At) > len(layout) {
+			layout = "15:04:05"
+		}
+
+		at, err := time.Parse(layout, t.At)
+		if err != nil {
+			return 0
+		}
+
+		// The next time the clock shows the time of day
+		next := time.Date(pathEpoch.Year(), pathEpoch.Month(), pathEpoch.Day(), at.Hour(), at.Minute(), at.Second(), 0,
+			pathEpoch.Location())
+		if !next.After(pathEpoch) {
+			next = next.AddDate(0, 0, 1)
+		}
+
+		return next.Sub(pathEpoch)
+	}
+
+	earliest := 0
+
+	for i, t := range s.TimeTransitions {
+		if delay(t) < delay(s.TimeTransitions[earliest]) {
+			earliest = i
+		}
+	}
+
+	return earliest
+}
@@ -0,0 +1,182 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package uml_test
+
+import (
+	"testing"
+
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFSM_Paths(t *testing.T) {
+	tests := []struct {
+		name  string
+		chart string
+		limit int
+		want  []uml.Path
+	}{
+		{
+			name: "Guards and unguarded transition",
+			chart: `[*] --> Fetching
+Fetching --> Failed : [ IsError ]
+Fetching --> [*]
+Failed --> [*]`,
+			limit: 10,
+			want: []uml.Path{
+				{
+					States:  []string{"InitialState", "Fetching", "Failed", "FinalState"},
+					Choices: map[string][]int{"Fetching": {0}},
+				},
+				{
+					States:  []string{"InitialState", "Fetching", "FinalState"},
+					Choices: map[string][]int{"Fetching": {1}},
+				},
+			},
+		},
+		{
+			name: "End state",
+			chart: `[*] --> Reviewing
+state Rejected <<end>>
+Reviewing --> Rejected : [ IsRejected ]
+Reviewing --> [*]`,
+			limit: 10,
+			want: []uml.Path{
+				{
+					States:  []string{"InitialState", "Reviewing", "Rejected"},
+					Choices: map[string][]int{"Reviewing": {0}},
+				},
+				{
+					States:  []string{"InitialState", "Reviewing", "FinalState"},
+					Choices: map[string][]int{"Reviewing": {1}},
+				},
+			},
+		},
+		{
+			name: "Loops are run through once",
+			chart: `[*] --> Polling
+Polling --> [*] : [ IsDone ]
+Polling --> Polling`,
+			limit: 10,
+			want: []uml.Path{
+				{
+					States:  []string{"InitialState", "Polling", "FinalState"},
+					Choices: map[string][]int{"Polling": {0}},
+				},
+				{
+					States:  []string{"InitialState", "Polling", "Polling", "FinalState"},
+					Choices: map[string][]int{"Polling": {1, 0}},
+				},
+			},
+		},
+		{
+			name: "Composite state",
+			chart: `[*] --> Working
+state Working {
+  [*] --> Inner
+  Inner --> [*]
+}
+Working --> [*]`,
+			limit: 10,
+			want: []uml.Path{
+				{
+					States:  []string{"InitialState", "Working", "InitialState", "Inner", "FinalState", "FinalState"},
+					Choices: map[string][]int{},
+				},
+			},
+		},
+		{
+			name: "Error transitions",
+			chart: `[*] --> Fetching
+Fetching: do / Fetch
+Fetching --> Retrying : on error(ErrTimeout)
+Fetching --> Failed : on error
+Fetching --> Ignored : on error(ErrTimeout)
+Fetching --> [*]
+Retrying --> [*]
+Failed --> [*]
+Ignored --> [*]`,
+			limit: 10,
+			want: []uml.Path{
+				{
+					States:  []string{"InitialState", "Fetching", "Retrying", "FinalState"},
+					Choices: map[string][]int{},
+					Errors:  map[string][]*uml.ErrorTransition{"Fetching": {{Target: "Retrying", Error: "ErrTimeout"}}},
+				},
+				{
+					States:  []string{"InitialState", "Fetching", "Failed", "FinalState"},
+					Choices: map[string][]int{},
+					Errors:  map[string][]*uml.ErrorTransition{"Fetching": {{Target: "Failed"}}},
+				},
+				{
+					States:  []string{"InitialState", "Fetching", "FinalState"},
+					Choices: map[string][]int{},
+					Errors:  map[string][]*uml.ErrorTransition{"Fetching": {nil}},
+				},
+			},
+		},
+		{
+			name: "Time transitions",
+			chart: `[*] --> Waiting
+Waiting --> [*] : [ IsReady ]
+Waiting --> Sleeping : after(5s)
+Sleeping --> Waiting : at(07:30)
+Sleeping --> Reporting : after(10s)
+Reporting --> [*]`,
+			limit: 10,
+			want: []uml.Path{
+				{
+					States:  []string{"InitialState", "Waiting", "FinalState"},
+					Choices: map[string][]int{"Waiting": {0}},
+				},
+				{
+					States:  []string{"InitialState", "Waiting", "Sleeping", "Reporting", "FinalState"},
+					Choices: map[string][]int{"Waiting": {1}},
+				},
+			},
+		},
+		{
+			name: "Limit",
+			chart: `[*] --> Fetching
+Fetching --> Failed : [ IsError ]
+Fetching --> [*]
+Failed --> [*]`,
+			limit: 1,
+			want: []uml.Path{
+				{
+					States:  []string{"InitialState", "Fetching", "Failed", "FinalState"},
+					Choices: map[string][]int{"Fetching": {0}},
+				},
+			},
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, uml.Parse(tt.chart).Paths(tt.limit))
+		})
+	}
+}
@@ -0,0 +1,206 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package uml
+
+import (
+	"slices"
+	"strconv"
+	"time"
+)
+
+// The IDs of the states in the state table that have no row of their own.
+const (
+	NoStateID    = "noState"
+	FinalStateID = "finalState"
+)
+
+// TableState is a row in the state table of a chart, where the states of the
+// composite states are flattened into the same table as the other states.
+type TableState struct {
+	ID     string
+	Name   string
+	Parent string // The ID of the composite state, NoStateID at the top level
+	// Initial is the ID of the initial state of a composite state, and
+	// NoStateID for other states
+	Initial string
+	Actions []Action
+	Guards  []TableGuard
+	// Next is the target of the unguarded transition, NoStateID if the state
+	// has none
+	Next             string
+	ErrorTransitions []TableErrorTransition
+	Timeout          time.Duration
+	// TimeoutTarget is the target of the timeout transition, NoStateID if the
+	// state has none
+	TimeoutTarget   string
+	TimeTransitions []TableTimeTransition
+	Compensation    *Action
+	End             bool
+}
+
+// TableGuard is a guarded transition in the state table.
+type TableGuard struct {
+	Transition
+	// TargetID is the target of the transition, NoStateID if the guard has
+	// no transition of its own
+	TargetID string
+}
+
+// TableErrorTransition is an error transition in the state table.
+type TableErrorTransition struct {
+	ErrorTransition
+	TargetID string
+}
+
+// TableTimeTransition is a time transition in the state table.
+type TableTimeTransition struct {
+	TimeTransition
+	TargetID string
+}
+
+// Table returns the states of the chart as a state table. The states are
+// ordered by name, and the states of a composite state follow right after it.
+// The targets of the transitions are resolved to IDs among the states on the
+// same level, where the FinalState is FinalStateID.
+func (f *FSM) Table() []TableState {
+	var table []TableState
+
+	addStates(&table, f.States, NoStateID)
+
+	return table
+}
+
+// MaxGuards returns the highest number of guards of a single state.
+func (f *FSM) MaxGuards() int {
+	maxGuards := 0
+
+	for _, state := range f.Table() {
+		maxGuards = max(maxGuards, len(state.Guards))
+	}
+
+	return maxGuards
+}
+
+// InitialStateID returns the ID of the InitialState at the top level.
+func (f *FSM) InitialStateID() string {
+	for _, state := range f.Table() {
+		if state.Parent == NoStateID && state.Name == InitialState {
+			return state.ID
+		}
+	}
+
+	return NoStateID
+}
+
+// addStates adds the states to the table, followed by the states of the
+// composite states.
+func addStates(table *[]TableState, states map[string]*State, parent string) {
+	names := make([]string, 0, len(states))
+
+	for name := range states {
+		if name != FinalState {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	// The IDs of the states on this level, to resolve the targets
+	ids := map[string]string{FinalState: FinalStateID}
+
+	first := len(*table)
+	for i, name := range names {
+		ids[name] = strconv.Itoa(first + i)
+		*table = append(*table, TableState{ID: ids[name], Name: name, Parent: parent, Initial: NoStateID})
+	}
+
+	target := func(name string) string {
+		if id, ok := ids[name]; ok {
+			return id
+		}
+
+		return NoStateID
+	}
+
+	for i, name := range names {
+		state := states[name]
+		row := &(*table)[first+i]
+
+		row.Actions = state.Actions
+		row.Compensation = state.Compensation
+		row.End = state.End
+		row.Next = NoStateID
+		row.Timeout = state.Timeout
+		row.TimeoutTarget = NoStateID
+
+		if state.TimeoutTarget != "" {
+			row.TimeoutTarget = target(state.TimeoutTarget)
+		}
+
+		// Guard number i takes transition number i, and the unguarded
+		// transition is the one following the guards.
+		for _, transition := range state.Transitions {
+			if transition.Guard == "" {
+				continue
+			}
+
+			guard := TableGuard{Transition: transition, TargetID: NoStateID}
+			if index := len(row.Guards); index < len(state.Transitions) {
+				guard.TargetID = target(state.Transitions[index].Target)
+			}
+
+			row.Guards = append(row.Guards, guard)
+		}
+
+		if len(row.Guards) < len(state.Transitions) {
+			row.Next = target(state.Transitions[len(row.Guards)].Target)
+		}
+
+		for _, transition := range state.ErrorTransitions {
+			row.ErrorTransitions = append(row.ErrorTransitions,
+				TableErrorTransition{ErrorTransition: transition, TargetID: target(transition.Target)})
+		}
+
+		for _, transition := range state.TimeTransitions {
+			row.TimeTransitions = append(row.TimeTransitions,
+				TableTimeTransition{TimeTransition: transition, TargetID: target(transition.Target)})
+		}
+	}
+
+	for i, name := range names {
+		state := states[name]
+		if state.Composite.InitialState == "" {
+			continue
+		}
+
+		// The row may have moved when the table grew
+		row := len(*table)
+
+		addStates(table, state.Composite.States, (*table)[first+i].ID)
+
+		for _, nested := range (*table)[row:] {
+			if nested.Parent == (*table)[first+i].ID && nested.Name == state.Composite.InitialState {
+				(*table)[first+i].Initial = nested.ID
+			}
+		}
+	}
+}
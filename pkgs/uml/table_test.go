@@ -0,0 +1,168 @@
+/*
+Copyright © 2024-2025 Morten Hersson <mhersson@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package uml_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mhersson/vectorsigma/pkgs/uml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFSM_Table(t *testing.T) {
+	tests := []struct {
+		name          string
+		chart         string
+		want          []uml.TableState
+		wantMaxGuards int
+		wantInitial   string
+	}{
+		{
+			name: "Guards and error transitions",
+			chart: `[*] --> Fetching
+Fetching: do / Fetch
+Fetching --> Failed : on error
+Fetching --> Failed : [ IsError ]
+Fetching --> [*]
+Failed --> [*]`,
+			want: []uml.TableState{
+				{ID: "0", Name: "Failed", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: uml.FinalStateID, TimeoutTarget: uml.NoStateID},
+				{
+					ID: "1", Name: "Fetching", Parent: uml.NoStateID, Initial: uml.NoStateID,
+					Actions: []uml.Action{{Name: "Fetch"}},
+					Guards: []uml.TableGuard{
+						{Transition: uml.Transition{Target: "Failed", Guard: "IsError"}, TargetID: "0"},
+					},
+					Next: uml.FinalStateID,
+					ErrorTransitions: []uml.TableErrorTransition{
+						{ErrorTransition: uml.ErrorTransition{Target: "Failed"}, TargetID: "0"},
+					},
+					TimeoutTarget: uml.NoStateID,
+				},
+				{ID: "2", Name: "InitialState", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: "1", TimeoutTarget: uml.NoStateID},
+			},
+			wantMaxGuards: 1,
+			wantInitial:   "2",
+		},
+		{
+			name: "Composite state",
+			chart: `[*] --> Working
+state Working {
+  [*] --> Inner
+  Inner --> [*]
+}
+Working --> [*]`,
+			want: []uml.TableState{
+				{ID: "0", Name: "InitialState", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: "1", TimeoutTarget: uml.NoStateID},
+				{ID: "1", Name: "Working", Parent: uml.NoStateID, Initial: "2", Next: uml.FinalStateID, TimeoutTarget: uml.NoStateID},
+				{ID: "2", Name: "InitialState", Parent: "1", Initial: uml.NoStateID, Next: "3", TimeoutTarget: uml.NoStateID},
+				{ID: "3", Name: "Inner", Parent: "1", Initial: uml.NoStateID, Next: uml.FinalStateID, TimeoutTarget: uml.NoStateID},
+			},
+			wantMaxGuards: 0,
+			wantInitial:   "0",
+		},
+		{
+			name: "Timeout",
+			chart: `[*] --> Waiting
+Waiting: timeout 30s --> TimedOut
+Waiting --> [*]
+TimedOut --> [*]`,
+			want: []uml.TableState{
+				{ID: "0", Name: "InitialState", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: "2", TimeoutTarget: uml.NoStateID},
+				{ID: "1", Name: "TimedOut", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: uml.FinalStateID, TimeoutTarget: uml.NoStateID},
+				{
+					ID: "2", Name: "Waiting", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: uml.FinalStateID,
+					Timeout: 30 * time.Second, TimeoutTarget: "1",
+				},
+			},
+			wantMaxGuards: 0,
+			wantInitial:   "0",
+		},
+		{
+			name: "Time transitions",
+			chart: `[*] --> Sleeping
+Sleeping --> Polling : after(10s)
+Sleeping --> [*] : at(07:30)
+Polling --> [*]`,
+			want: []uml.TableState{
+				{ID: "0", Name: "InitialState", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: "2", TimeoutTarget: uml.NoStateID},
+				{ID: "1", Name: "Polling", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: uml.FinalStateID, TimeoutTarget: uml.NoStateID},
+				{
+					ID: "2", Name: "Sleeping", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: uml.NoStateID,
+					TimeoutTarget: uml.NoStateID,
+					TimeTransitions: []uml.TableTimeTransition{
+						{TimeTransition: uml.TimeTransition{Target: "Polling", After: 10 * time.Second}, TargetID: "1"},
+						{TimeTransition: uml.TimeTransition{Target: "FinalState", At: "07:30"}, TargetID: uml.FinalStateID},
+					},
+				},
+			},
+			wantMaxGuards: 0,
+			wantInitial:   "0",
+		},
+		{
+			name: "Compensation",
+			chart: `[*] --> Provisioning
+Provisioning: do / Provision
+Provisioning: compensate / Deprovision
+Provisioning --> [*]`,
+			want: []uml.TableState{
+				{ID: "0", Name: "InitialState", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: "1", TimeoutTarget: uml.NoStateID},
+				{
+					ID: "1", Name: "Provisioning", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: uml.FinalStateID,
+					TimeoutTarget: uml.NoStateID,
+					Actions:       []uml.Action{{Name: "Provision"}},
+					Compensation:  &uml.Action{Name: "Deprovision"},
+				},
+			},
+			wantMaxGuards: 0,
+			wantInitial:   "0",
+		},
+		{
+			name: "End state",
+			chart: `[*] --> Reviewing
+state Rejected <<end>>
+Reviewing --> Rejected`,
+			want: []uml.TableState{
+				{ID: "0", Name: "InitialState", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: "2", TimeoutTarget: uml.NoStateID},
+				{ID: "1", Name: "Rejected", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: uml.NoStateID, TimeoutTarget: uml.NoStateID, End: true},
+				{ID: "2", Name: "Reviewing", Parent: uml.NoStateID, Initial: uml.NoStateID, Next: "1", TimeoutTarget: uml.NoStateID},
+			},
+			wantMaxGuards: 0,
+			wantInitial:   "0",
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fsm := uml.Parse(tt.chart)
+
+			assert.Equal(t, tt.want, fsm.Table())
+			assert.Equal(t, tt.wantMaxGuards, fsm.MaxGuards())
+			assert.Equal(t, tt.wantInitial, fsm.InitialStateID())
+		})
+	}
+}
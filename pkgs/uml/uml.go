@@ -22,9 +22,12 @@ THE SOFTWARE.
 package uml
 
 import (
+	"errors"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -35,23 +38,47 @@ const (
 	titlePattern             = `^title\s(.*)$`
 	// InitialState --> StartingConversation.
 	initialStatePattern = `^\s*` + InitialState + `\s*-->\s*(\w+)$`
-	// StartingConversation: do / StartConversation(param) or StartingConversation : do / StartConversation(param).
-	actionPattern = `^\s*(\w+)\s*:\s*(do\s*\/\s*)?(\w+)(\((.*)\))?$`
+	// StartingConversation: do / StartConversation(param) or Fetching: do / Fetch retry(max=5, backoff=exponential, base=200ms) timeout(5s).
+	actionPattern = `^\s*(\w+)\s*:\s*(do\s*\/\s*)?(\w+)(\((.*?)\))?(\s+retry\s*\((.*?)\))?(\s+timeout\s*\(\s*([\w.]+)\s*\))?\s*$`
+	// Fetching: do / FetchUsers & FetchOrders(open) & FetchInvoices retry(max=2).
+	concurrentActionsPattern = `^\s*(\w+)\s*:\s*(do\s*\/\s*)?([^&]+(&[^&]+)+)$`
+	// A single action of concurrent actions: FetchOrders(open) retry(max=2) timeout(1s).
+	concurrentActionPattern = `^\s*(\w+)(\((.*?)\))?(\s+retry\s*\((.*?)\))?(\s+timeout\s*\(\s*([\w.]+)\s*\))?\s*$`
+	// Provisioning: compensate / Deprovision or Provisioning: compensate / Delete(vm).
+	compensationPattern = `^\s*(\w+)\s*:\s*compensate\s*\/\s*(\w+)(\((.*?)\))?\s*$`
+	// Waiting: timeout 30s or Waiting: timeout 30s --> TimedOut.
+	stateTimeoutPattern = `^\s*(\w+)\s*:\s*timeout\s+([\w.]+)\s*(-->\s*(\w+))?\s*$`
 	// StartingConversation --> FinalState : [ isError ] or StartingConversation --> FinalState: [ isError(param) ].
 	guardedTransitionPattern = `^\s*(\w+)\s*-->\s*(\w+)\s*:\s*\[?\s*(\w+)(\((.*?)\))?\s*\]?\s*?(::\s*(\w+)(\((.*)\))?)?$`
+	// StartingConversation --> Retrying : on error(ErrTimeout) or StartingConversation --> FinalState : on error.
+	errorTransitionPattern = `^\s*(\w+)\s*-->\s*(\w+)\s*:\s*on\s+error\s*(\(\s*(\*?\w+)\s*\))?\s*$`
+	// Sleeping --> Polling : after(10s) or Idle --> Reporting : at(07:30).
+	timeTransitionPattern = `^\s*(\w+)\s*-->\s*(\w+)\s*:\s*(after|at)\s*\(\s*([\w.:]+)\s*\)\s*$`
+	// Waiting --> TimedOut : on timeout.
+	timeoutTransitionPattern = `^\s*(\w+)\s*-->\s*(\w+)\s*:\s*on\s+timeout\s*$`
 	// StartingConversation --> FinalState.
 	defaultTransitionPattern = `^\s*(\w+)\s*-->\s*(\w+)$`
 	// CompositeState: state compositestate {.
 	compositeStateStartPattern = `^\s*state\s*(\w+)\s*{$`
+	// EndState: state Rejected <<end>>.
+	endStatePattern = `^\s*state\s+(\w+)\s*<<\s*end\s*>>\s*$`
 
 	compositeStateEndPattern = `^\s*}$`
 )
 
 type State struct {
-	Name        string
-	Actions     []Action
-	Transitions []Transition
-	Composite   Composite
+	Name             string
+	Actions          []Action
+	Transitions      []Transition
+	ErrorTransitions []ErrorTransition
+	Composite        Composite
+	Timeout          time.Duration // The time the machine may stay in the state, 0 means no limit
+	TimeoutTarget    string        // The state entered when the state or one of its actions times out
+	TimeTransitions  []TimeTransition
+	// Compensation undoes the actions of the state, when the machine ends on an
+	// error path after the state was completed
+	Compensation *Action
+	End          bool // The machine ends when it enters the state, like in the FinalState
 }
 
 type Composite struct {
@@ -66,9 +93,54 @@ type Transition struct {
 	Action      *Action
 }
 
+// ErrorTransition is taken when an action of the state fails with an error
+// matching Error. Error is either the name of a sentinel error, a pointer to an
+// error type like *TimeoutError, or empty to match any error.
+type ErrorTransition struct {
+	Target string
+	Error  string
+}
+
+// TimeTransition is taken when no other transition of the state can be taken,
+// a delay after the state was entered, or at a time of day.
+type TimeTransition struct {
+	Target string
+	After  time.Duration // The delay after entering the state
+	At     string        // The time of day, like 07:30, empty for a delay
+}
+
+// Event returns the time event of the transition as written in the chart, like
+// after(10s) or at(07:30).
+func (t TimeTransition) Event() string {
+	if t.At != "" {
+		return "at(" + t.At + ")"
+	}
+
+	return "after(" + t.After.String() + ")"
+}
+
 type Action struct {
-	Name   string
-	Params string
+	Name    string
+	Params  string
+	Retry   *RetryPolicy
+	Group   int           // Actions of a state with the same non-zero group run concurrently
+	Timeout time.Duration // Cancels each attempt of the action after the duration, 0 means no limit
+}
+
+// Supported backoff strategies for retry policies.
+const (
+	BackoffConstant    = "constant"
+	BackoffLinear      = "linear"
+	BackoffExponential = "exponential"
+)
+
+// RetryPolicy describes how a failing action is retried before the error is
+// handled by the state machine. Max is the number of retries after the first
+// attempt, and Base is the delay before the first retry.
+type RetryPolicy struct {
+	Max     int
+	Backoff string
+	Base    time.Duration
 }
 
 type FSM struct {
@@ -77,9 +149,47 @@ type FSM struct {
 	InitialState string
 	ActionNames  []string
 	GuardNames   []string
+	ErrorNames   []string
 	AllStates    []string
 }
 
+// The kinds of names in a chart.
+const (
+	KindTitle  = "title"
+	KindState  = "state"
+	KindAction = "action"
+	KindGuard  = "guard"
+	KindError  = "error"
+)
+
+// Name is a name in the chart that becomes an identifier in the generated code.
+type Name struct {
+	Kind  string
+	Value string
+}
+
+// Names returns all the names in the chart that become identifiers in the
+// generated code, ordered by kind.
+func (f *FSM) Names() []Name {
+	names := []Name{{Kind: KindTitle, Value: f.Title}}
+
+	for _, kind := range []struct {
+		kind   string
+		values []string
+	}{
+		{KindState, f.AllStates},
+		{KindAction, f.ActionNames},
+		{KindGuard, f.GuardNames},
+		{KindError, f.ErrorNames},
+	} {
+		for _, value := range kind.values {
+			names = append(names, Name{Kind: kind.kind, Value: value})
+		}
+	}
+
+	return names
+}
+
 func (f *FSM) Action(action string) {
 	if !slices.Contains(f.ActionNames, action) {
 		f.ActionNames = append(f.ActionNames, action)
@@ -92,6 +202,77 @@ func (f *FSM) Guard(guard string) {
 	}
 }
 
+func (f *FSM) ErrorName(name string) {
+	// Error types are declared by the user, only sentinel errors are generated
+	if name != "" && !strings.HasPrefix(name, "*") && !slices.Contains(f.ErrorNames, name) {
+		f.ErrorNames = append(f.ErrorNames, name)
+	}
+}
+
+// HasRetries reports if any action, including the actions of the composite
+// states, has a retry policy.
+func (f *FSM) HasRetries() bool {
+	return hasRetries(f.States)
+}
+
+func hasRetries(states map[string]*State) bool {
+	for _, state := range states {
+		for _, action := range state.Actions {
+			if action.Retry != nil {
+				return true
+			}
+		}
+
+		if hasRetries(state.Composite.States) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasTimeouts reports if any state or action, including the states of the
+// composite states, has a timeout.
+func (f *FSM) HasTimeouts() bool {
+	return hasTimeouts(f.States)
+}
+
+func hasTimeouts(states map[string]*State) bool {
+	for _, state := range states {
+		if state.Timeout > 0 {
+			return true
+		}
+
+		for _, action := range state.Actions {
+			if action.Timeout > 0 {
+				return true
+			}
+		}
+
+		if hasTimeouts(state.Composite.States) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasTimeTransitions reports if any state, including the states of the
+// composite states, has a time transition.
+func (f *FSM) HasTimeTransitions() bool {
+	return hasTimeTransitions(f.States)
+}
+
+func hasTimeTransitions(states map[string]*State) bool {
+	for _, state := range states {
+		if len(state.TimeTransitions) > 0 || hasTimeTransitions(state.Composite.States) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (f *FSM) IsTitle(line string) bool {
 	re := regexp.MustCompile(titlePattern)
 
@@ -135,36 +316,291 @@ func (f *FSM) IsAction(line string) bool {
 
 	m := re.FindStringSubmatch(line)
 	if m != nil {
-		state := m[1]
-		action := Action{}
-		action.Name = m[3]
+		action, ok := newAction(m[3], m[5], m[7], m[9])
+		if !ok {
+			return false
+		}
 
-		if len(m) == 6 && m[5] != "" {
-			params := strings.TrimSpace(m[5])
+		f.addActions(m[1], action)
 
-			paramList := strings.Split(params, ",")
-			for i, param := range paramList {
-				paramList[i] = strings.Trim(strings.TrimSpace(param), `"`)
-			}
+		return true
+	}
+
+	return false
+}
+
+// IsEndState recognizes a state declared with the <<end>> stereotype. The
+// state ends the state machine like the FinalState, and names how it ended.
+func (f *FSM) IsEndState(line string) bool {
+	re := regexp.MustCompile(endStatePattern)
+
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+
+	f.state(m[1]).End = true
+
+	return true
+}
+
+// IsCompensation recognizes the compensating action of a state.
+func (f *FSM) IsCompensation(line string) bool {
+	re := regexp.MustCompile(compensationPattern)
+
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+
+	action, _ := newAction(m[2], m[4], "", "")
+
+	f.Action(action.Name)
+	f.state(m[1]).Compensation = &action
+
+	return true
+}
+
+// IsConcurrentActions recognizes actions separated by &, which are run
+// concurrently. Each action can have parameters and a retry policy.
+func (f *FSM) IsConcurrentActions(line string) bool {
+	re := regexp.MustCompile(concurrentActionsPattern)
+
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
 
-			action.Params = `"` + strings.Join(paramList, `","`) + `"`
+	group := 1
+
+	if state, ok := f.States[m[1]]; ok {
+		for _, action := range state.Actions {
+			group = max(group, action.Group+1)
+		}
+	}
+
+	actionRe := regexp.MustCompile(concurrentActionPattern)
+
+	var actions []Action
+
+	for _, part := range strings.Split(m[3], "&") {
+		am := actionRe.FindStringSubmatch(part)
+		if am == nil {
+			return false
+		}
+
+		action, ok := newAction(am[1], am[3], am[5], am[7])
+		if !ok {
+			return false
 		}
 
+		action.Group = group
+		actions = append(actions, action)
+	}
+
+	f.addActions(m[1], actions...)
+
+	return true
+}
+
+// newAction returns the action with the given name, parameters, retry policy
+// and timeout, as written in the chart.
+func newAction(name, params, retry, timeout string) (Action, bool) {
+	action := Action{Name: name}
+
+	if timeout != "" {
+		d, ok := parseTimeout(timeout)
+		if !ok {
+			return action, false
+		}
+
+		action.Timeout = d
+	}
+
+	if retry != "" {
+		policy, ok := parseRetryPolicy(retry)
+		if !ok {
+			return action, false
+		}
+
+		action.Retry = policy
+	}
+
+	if params != "" {
+		paramList := strings.Split(strings.TrimSpace(params), ",")
+		for i, param := range paramList {
+			paramList[i] = strings.Trim(strings.TrimSpace(param), `"`)
+		}
+
+		action.Params = `"` + strings.Join(paramList, `","`) + `"`
+	}
+
+	return action, true
+}
+
+// addActions adds the actions to the state, and creates the state if needed.
+func (f *FSM) addActions(state string, actions ...Action) {
+	for _, action := range actions {
 		f.Action(action.Name)
+	}
 
-		if _, ok := f.States[state]; !ok {
-			f.States[state] = &State{
-				Name:    state,
-				Actions: []Action{action},
+	if _, ok := f.States[state]; !ok {
+		f.States[state] = &State{Name: state}
+	}
+
+	f.States[state].Actions = append(f.States[state].Actions, actions...)
+}
+
+// parseRetryPolicy parses the arguments of a retry policy, e.g.
+// "max=5, backoff=exponential, base=200ms".
+func parseRetryPolicy(args string) (*RetryPolicy, bool) {
+	retry := &RetryPolicy{Max: 1, Backoff: BackoffConstant}
+
+	for _, arg := range strings.Split(args, ",") {
+		key, value, found := strings.Cut(arg, "=")
+		if !found {
+			return nil, false
+		}
+
+		value = strings.TrimSpace(value)
+
+		var err error
+
+		switch strings.TrimSpace(key) {
+		case "max":
+			retry.Max, err = strconv.Atoi(value)
+			if retry.Max < 0 {
+				return nil, false
 			}
-		} else {
-			f.States[state].Actions = append(f.States[state].Actions, action)
+		case "backoff":
+			if !slices.Contains([]string{BackoffConstant, BackoffLinear, BackoffExponential}, value) {
+				return nil, false
+			}
+
+			retry.Backoff = value
+		case "base":
+			retry.Base, err = time.ParseDuration(value)
+			if retry.Base < 0 {
+				return nil, false
+			}
+		default:
+			return nil, false
 		}
 
-		return true
+		if err != nil {
+			return nil, false
+		}
 	}
 
-	return false
+	return retry, true
+}
+
+// parseTimeout parses a timeout, which must be a positive duration.
+func parseTimeout(value string) (time.Duration, bool) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+
+	return d, true
+}
+
+// IsStateTimeout recognizes the timeout of a state, and the optional state
+// entered when it expires.
+func (f *FSM) IsStateTimeout(line string) bool {
+	re := regexp.MustCompile(stateTimeoutPattern)
+
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+
+	timeout, ok := parseTimeout(m[2])
+	if !ok {
+		return false
+	}
+
+	state := f.state(m[1])
+	state.Timeout = timeout
+
+	if m[4] != "" {
+		state.TimeoutTarget = m[4]
+		f.state(m[4])
+	}
+
+	return true
+}
+
+// IsTimeoutTransition recognizes the transition taken when the state, or one of
+// its actions, times out.
+func (f *FSM) IsTimeoutTransition(line string) bool {
+	re := regexp.MustCompile(timeoutTransitionPattern)
+
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+
+	f.state(m[1]).TimeoutTarget = m[2]
+	f.state(m[2])
+
+	return true
+}
+
+// IsTimeTransition recognizes a transition taken after a delay, or at a time
+// of day.
+func (f *FSM) IsTimeTransition(line string) bool {
+	re := regexp.MustCompile(timeTransitionPattern)
+
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+
+	transition := TimeTransition{Target: m[2]}
+
+	if m[3] == "after" {
+		after, ok := parseTimeout(m[4])
+		if !ok {
+			return false
+		}
+
+		transition.After = after
+	} else {
+		at, ok := parseTimeOfDay(m[4])
+		if !ok {
+			return false
+		}
+
+		transition.At = at
+	}
+
+	state := f.state(m[1])
+	state.TimeTransitions = append(state.TimeTransitions, transition)
+	f.state(m[2])
+
+	return true
+}
+
+// parseTimeOfDay parses a time of day like 7:30 or 07:30:15, and returns it
+// with two digits for the hour.
+func parseTimeOfDay(value string) (string, bool) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format(layout), true
+		}
+	}
+
+	return "", false
+}
+
+// state returns the state with the given name, and creates it if needed.
+func (f *FSM) state(name string) *State {
+	if _, ok := f.States[name]; !ok {
+		f.States[name] = &State{Name: name}
+	}
+
+	return f.States[name]
 }
 
 func (f *FSM) IsGuardedTransition(line string) bool {
@@ -242,6 +678,38 @@ func (f *FSM) IsGuardedTransition(line string) bool {
 	return false
 }
 
+func (f *FSM) IsErrorTransition(line string) bool {
+	re := regexp.MustCompile(errorTransitionPattern)
+
+	m := re.FindStringSubmatch(line)
+	if m != nil {
+		state := m[1]
+		transition := ErrorTransition{Target: m[2], Error: m[4]}
+
+		f.ErrorName(transition.Error)
+
+		if _, ok := f.States[state]; !ok {
+			f.States[state] = &State{
+				Name:             state,
+				ErrorTransitions: []ErrorTransition{transition},
+			}
+		} else {
+			f.States[state].ErrorTransitions = append(f.States[state].ErrorTransitions, transition)
+		}
+
+		// Make sure the target state exists.
+		if _, ok := f.States[transition.Target]; !ok {
+			f.States[transition.Target] = &State{
+				Name: transition.Target,
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
 func (f *FSM) IsDefaultTransition(line string) bool {
 	re := regexp.MustCompile(defaultTransitionPattern)
 
@@ -329,6 +797,10 @@ func (f *FSM) IsCompositeStateStart(ind int, line, data string) (int, bool) {
 			}
 		}
 
+		for _, v := range compState.ErrorNames {
+			f.ErrorName(v)
+		}
+
 		return end - start, true
 	}
 
@@ -343,6 +815,28 @@ func (f *FSM) IsCompositeStateEnd(line string) bool {
 	return m != nil
 }
 
+// ExtractFromMarkdown returns the content of the first plantuml code block in
+// markdown.
+func ExtractFromMarkdown(markdown string) (string, error) {
+	const startDelimiter = "```plantuml"
+
+	const endDelimiter = "```"
+
+	lenStartDelimiter := len(startDelimiter)
+
+	startIndex := strings.Index(markdown, startDelimiter)
+	if startIndex == -1 {
+		return "", errors.New("no plantuml found in markdown")
+	}
+
+	endIndex := strings.Index(markdown[startIndex+lenStartDelimiter:], endDelimiter)
+	if endIndex == -1 {
+		return "", errors.New("missing end of plantuml code block in markdown")
+	}
+
+	return markdown[startIndex+lenStartDelimiter : endIndex+startIndex+lenStartDelimiter], nil
+}
+
 func normalizeData(data string) string {
 	data = strings.ReplaceAll(data, "\\n", "")
 
@@ -383,10 +877,38 @@ func Parse(data string) *FSM {
 			continue
 		}
 
+		if fsm.IsStateTimeout(lines[ind]) {
+			continue
+		}
+
+		if fsm.IsCompensation(lines[ind]) {
+			continue
+		}
+
+		if fsm.IsEndState(lines[ind]) {
+			continue
+		}
+
+		if fsm.IsConcurrentActions(lines[ind]) {
+			continue
+		}
+
 		if fsm.IsAction(lines[ind]) {
 			continue
 		}
 
+		if fsm.IsErrorTransition(lines[ind]) {
+			continue
+		}
+
+		if fsm.IsTimeoutTransition(lines[ind]) {
+			continue
+		}
+
+		if fsm.IsTimeTransition(lines[ind]) {
+			continue
+		}
+
 		if fsm.IsGuardedTransition(lines[ind]) {
 			continue
 		}
@@ -411,6 +933,7 @@ func Parse(data string) *FSM {
 	slices.Sort(fsm.AllStates)
 	slices.Sort(fsm.ActionNames)
 	slices.Sort(fsm.GuardNames)
+	slices.Sort(fsm.ErrorNames)
 
 	return fsm
 }
@@ -24,6 +24,7 @@ package uml_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/mhersson/vectorsigma/pkgs/uml"
@@ -172,6 +173,45 @@ func TestFSM_IsAction(t *testing.T) {
 			name: "Ok params with leading spaces", args: args{line: "State: do / action(param1,     param2,param3, param4)"}, expect: uml.Action{Name: "action", Params: `"param1","param2","param3","param4"`},
 			want: true,
 		},
+		{
+			name: "Ok retry", args: args{line: "State: do / action retry(max=5, backoff=exponential, base=200ms)"},
+			expect: uml.Action{Name: "action", Retry: &uml.RetryPolicy{Max: 5, Backoff: uml.BackoffExponential, Base: 200 * time.Millisecond}},
+			want:   true,
+		},
+		{
+			name: "Ok retry with params", args: args{line: "State: do / action(param1) retry(max=2)"},
+			expect: uml.Action{Name: "action", Params: `"param1"`, Retry: &uml.RetryPolicy{Max: 2, Backoff: uml.BackoffConstant}},
+			want:   true,
+		},
+		{
+			name: "Not OK unknown backoff", args: args{line: "State: do / action retry(max=2, backoff=random)"}, expect: uml.Action{},
+			want: false,
+		},
+		{
+			name: "Not OK invalid duration", args: args{line: "State: do / action retry(base=soon)"}, expect: uml.Action{},
+			want: false,
+		},
+		{
+			name: "Ok timeout", args: args{line: "State: do / action timeout(5s)"},
+			expect: uml.Action{Name: "action", Timeout: 5 * time.Second},
+			want:   true,
+		},
+		{
+			name: "Ok retry and timeout", args: args{line: "State: do / action(param1) retry(max=2) timeout( 1.5s )"},
+			expect: uml.Action{
+				Name: "action", Params: `"param1"`, Retry: &uml.RetryPolicy{Max: 2, Backoff: uml.BackoffConstant},
+				Timeout: 1500 * time.Millisecond,
+			},
+			want: true,
+		},
+		{
+			name: "Not OK negative timeout", args: args{line: "State: do / action timeout(-1s)"}, expect: uml.Action{},
+			want: false,
+		},
+		{
+			name: "Not OK invalid timeout", args: args{line: "State: do / action timeout(soon)"}, expect: uml.Action{},
+			want: false,
+		},
 	}
 
 	t.Parallel()
@@ -189,6 +229,92 @@ func TestFSM_IsAction(t *testing.T) {
 				if len(tt.expect.Params) > 0 {
 					assert.Equal(t, tt.expect.Params, f.States["State"].Actions[0].Params)
 				}
+
+				assert.Equal(t, tt.expect.Retry, f.States["State"].Actions[0].Retry)
+				assert.Equal(t, tt.expect.Timeout, f.States["State"].Actions[0].Timeout)
+			}
+		})
+	}
+}
+
+func TestFSM_IsConcurrentActions(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		want   bool
+		expect []uml.Action
+	}{
+		{
+			name:  "Ok",
+			lines: []string{"State: do / FetchUsers & FetchOrders(open, closed) & FetchInvoices retry(max=2)"},
+			want:  true,
+			expect: []uml.Action{
+				{Name: "FetchUsers", Group: 1},
+				{Name: "FetchOrders", Params: `"open","closed"`, Group: 1},
+				{Name: "FetchInvoices", Retry: &uml.RetryPolicy{Max: 2, Backoff: uml.BackoffConstant}, Group: 1},
+			},
+		},
+		{
+			name:  "Ok no spaces",
+			lines: []string{"State:do/A&B"},
+			want:  true,
+			expect: []uml.Action{
+				{Name: "A", Group: 1},
+				{Name: "B", Group: 1},
+			},
+		},
+		{
+			name:  "Ok several groups",
+			lines: []string{"State: do / A & B", "State: do / C & D"},
+			want:  true,
+			expect: []uml.Action{
+				{Name: "A", Group: 1},
+				{Name: "B", Group: 1},
+				{Name: "C", Group: 2},
+				{Name: "D", Group: 2},
+			},
+		},
+		{
+			name:  "Not OK single action",
+			lines: []string{"State: do / A"},
+			want:  false,
+		},
+		{
+			name:  "Not OK empty action",
+			lines: []string{"State: do / A & "},
+			want:  false,
+		},
+		{
+			name:  "Ok timeout",
+			lines: []string{"State: do / A timeout(2s) & B retry(max=1) timeout(1s)"},
+			want:  true,
+			expect: []uml.Action{
+				{Name: "A", Group: 1, Timeout: 2 * time.Second},
+				{Name: "B", Retry: &uml.RetryPolicy{Max: 1, Backoff: uml.BackoffConstant}, Group: 1, Timeout: time.Second},
+			},
+		},
+		{
+			name:  "Not OK invalid retry",
+			lines: []string{"State: do / A & B retry(backoff=random)"},
+			want:  false,
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := &uml.FSM{ActionNames: []string{}, States: make(map[string]*uml.State)}
+			for _, line := range tt.lines {
+				if got := f.IsConcurrentActions(line); got != tt.want {
+					t.Errorf("FSM.IsConcurrentActions() = %v, want %v", got, tt.want)
+				}
+			}
+
+			if tt.want {
+				assert.Equal(t, tt.expect, f.States["State"].Actions)
 			}
 		})
 	}
@@ -319,6 +445,250 @@ func TestFSM_IsDefaultTransition(t *testing.T) {
 	}
 }
 
+func TestFSM_IsErrorTransition(t *testing.T) {
+	type args struct {
+		line string
+	}
+
+	tests := []struct {
+		name   string
+		args   args
+		want   bool
+		expect uml.ErrorTransition
+	}{
+		{
+			name: "Sentinel error", args: args{line: "state --> Retrying : on error(ErrTimeout)"},
+			expect: uml.ErrorTransition{Target: "Retrying", Error: "ErrTimeout"}, want: true,
+		},
+		{
+			name: "Error type", args: args{line: "state --> Retrying: on error( *TimeoutError )"},
+			expect: uml.ErrorTransition{Target: "Retrying", Error: "*TimeoutError"}, want: true,
+		},
+		{
+			name: "Catch all", args: args{line: "state-->FinalState:on error"},
+			expect: uml.ErrorTransition{Target: "FinalState"}, want: true,
+		},
+		{
+			name: "Guarded transition", args: args{line: "state --> state2: IsError"},
+			want: false,
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := &uml.FSM{States: make(map[string]*uml.State)}
+			if got := f.IsErrorTransition(tt.args.line); got != tt.want {
+				t.Errorf("FSM.IsErrorTransition() = %v, want %v", got, tt.want)
+			} else if tt.want {
+				assert.Contains(t, f.States["state"].ErrorTransitions, tt.expect)
+				assert.Contains(t, f.States, tt.expect.Target)
+
+				if tt.expect.Error == "ErrTimeout" {
+					assert.Equal(t, []string{"ErrTimeout"}, f.ErrorNames)
+				} else {
+					assert.Empty(t, f.ErrorNames)
+				}
+			}
+		})
+	}
+}
+
+func TestFSM_IsStateTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   bool
+		expect uml.State
+	}{
+		{
+			name: "Ok", line: "Waiting: timeout 30s",
+			want: true, expect: uml.State{Name: "Waiting", Timeout: 30 * time.Second},
+		},
+		{
+			name: "Ok with target", line: "Waiting : timeout 1m30s --> TimedOut",
+			want: true, expect: uml.State{Name: "Waiting", Timeout: 90 * time.Second, TimeoutTarget: "TimedOut"},
+		},
+		{
+			name: "Not OK invalid duration", line: "Waiting: timeout soon --> TimedOut",
+			want: false,
+		},
+		{
+			name: "Not OK zero duration", line: "Waiting: timeout 0s",
+			want: false,
+		},
+		{
+			name: "Not OK action", line: "Waiting: do / Wait timeout(1s)",
+			want: false,
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := &uml.FSM{States: make(map[string]*uml.State)}
+			if got := f.IsStateTimeout(tt.line); got != tt.want {
+				t.Errorf("FSM.IsStateTimeout() = %v, want %v", got, tt.want)
+			} else if tt.want {
+				assert.Equal(t, &tt.expect, f.States["Waiting"])
+
+				if tt.expect.TimeoutTarget != "" {
+					assert.Contains(t, f.States, tt.expect.TimeoutTarget)
+				}
+			}
+		})
+	}
+}
+
+func TestFSM_IsTimeoutTransition(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   bool
+		expect string
+	}{
+		{name: "Ok", line: "Waiting --> TimedOut : on timeout", want: true, expect: "TimedOut"},
+		{name: "Ok no spaces", line: "Waiting-->TimedOut:on timeout", want: true, expect: "TimedOut"},
+		{name: "Error transition", line: "Waiting --> TimedOut : on error", want: false},
+		{name: "Guarded transition", line: "Waiting --> TimedOut : IsTimeout", want: false},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := &uml.FSM{States: make(map[string]*uml.State)}
+			if got := f.IsTimeoutTransition(tt.line); got != tt.want {
+				t.Errorf("FSM.IsTimeoutTransition() = %v, want %v", got, tt.want)
+			} else if tt.want {
+				assert.Equal(t, tt.expect, f.States["Waiting"].TimeoutTarget)
+				assert.Contains(t, f.States, tt.expect)
+			}
+		})
+	}
+}
+
+func TestFSM_IsTimeTransition(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   bool
+		expect uml.TimeTransition
+	}{
+		{
+			name: "After", line: "Sleeping --> Polling : after(10s)",
+			want: true, expect: uml.TimeTransition{Target: "Polling", After: 10 * time.Second},
+		},
+		{
+			name: "After no spaces", line: "Sleeping-->Polling:after( 1m30s )",
+			want: true, expect: uml.TimeTransition{Target: "Polling", After: 90 * time.Second},
+		},
+		{
+			name: "At", line: "Sleeping --> Polling : at(7:30)",
+			want: true, expect: uml.TimeTransition{Target: "Polling", At: "07:30"},
+		},
+		{
+			name: "At with seconds", line: "Sleeping --> Polling : at(23:59:30)",
+			want: true, expect: uml.TimeTransition{Target: "Polling", At: "23:59:30"},
+		},
+		{name: "Invalid delay", line: "Sleeping --> Polling : after(10)", want: false},
+		{name: "Invalid time of day", line: "Sleeping --> Polling : at(25:00)", want: false},
+		{name: "Guarded transition", line: "Sleeping --> Polling : IsAwake", want: false},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := &uml.FSM{States: make(map[string]*uml.State)}
+			if got := f.IsTimeTransition(tt.line); got != tt.want {
+				t.Errorf("FSM.IsTimeTransition() = %v, want %v", got, tt.want)
+			} else if tt.want {
+				assert.Equal(t, []uml.TimeTransition{tt.expect}, f.States["Sleeping"].TimeTransitions)
+				assert.Contains(t, f.States, tt.expect.Target)
+			}
+		})
+	}
+}
+
+func TestTimeTransition_Event(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "after(1m30s)", uml.TimeTransition{After: 90 * time.Second}.Event())
+	assert.Equal(t, "at(07:30)", uml.TimeTransition{At: "07:30"}.Event())
+}
+
+func TestFSM_IsCompensation(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   bool
+		expect *uml.Action
+	}{
+		{name: "Ok", line: "Provisioning: compensate / Deprovision", want: true, expect: &uml.Action{Name: "Deprovision"}},
+		{
+			name: "With params", line: "Provisioning:compensate/Delete( vm, disk )",
+			want: true, expect: &uml.Action{Name: "Delete", Params: `"vm","disk"`},
+		},
+		{name: "Action", line: "Provisioning: do / Provision", want: false},
+		{name: "Missing action", line: "Provisioning: compensate /", want: false},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := &uml.FSM{States: make(map[string]*uml.State)}
+			if got := f.IsCompensation(tt.line); got != tt.want {
+				t.Errorf("FSM.IsCompensation() = %v, want %v", got, tt.want)
+			} else if tt.want {
+				assert.Equal(t, tt.expect, f.States["Provisioning"].Compensation)
+				assert.Equal(t, []string{tt.expect.Name}, f.ActionNames)
+			}
+		})
+	}
+}
+
+func TestFSM_IsEndState(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want bool
+	}{
+		{name: "Ok", line: "state Rejected <<end>>", want: true},
+		{name: "Spaces", line: "  state Rejected << end >>  ", want: true},
+		{name: "Other stereotype", line: "state Rejected <<choice>>", want: false},
+		{name: "Composite state", line: "state Rejected {", want: false},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := &uml.FSM{States: make(map[string]*uml.State)}
+			if got := f.IsEndState(tt.line); got != tt.want {
+				t.Errorf("FSM.IsEndState() = %v, want %v", got, tt.want)
+			} else if tt.want {
+				assert.True(t, f.States["Rejected"].End)
+			}
+		})
+	}
+}
+
 func TestFSM_IsCompositeStateStart(t *testing.T) {
 	type fields struct {
 		States       map[string]*uml.State
@@ -960,3 +1330,129 @@ Waiting --> [*]
 		})
 	}
 }
+
+func TestFSM_HasRetries(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{
+			name: "No retries",
+			data: "@startuml\n[*] --> Fetching\nFetching: do / Fetch\nFetching --> [*]\n@enduml\n",
+			want: false,
+		},
+		{
+			name: "Retry",
+			data: "@startuml\n[*] --> Fetching\nFetching: do / Fetch retry(max=3)\nFetching --> [*]\n@enduml\n",
+			want: true,
+		},
+		{
+			name: "Retry in composite state",
+			data: `@startuml
+[*] --> Loading
+state Loading {
+  [*] --> Fetching
+  Fetching: do / Fetch retry(max=3)
+  Fetching --> [*]
+}
+Loading --> [*]
+@enduml
+`,
+			want: true,
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, uml.Parse(tt.data).HasRetries())
+		})
+	}
+}
+
+func TestFSM_HasTimeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{
+			name: "No timeouts",
+			data: "@startuml\n[*] --> Fetching\nFetching: do / Fetch retry(max=3)\nFetching --> [*]\n@enduml\n",
+			want: false,
+		},
+		{
+			name: "Action timeout",
+			data: "@startuml\n[*] --> Fetching\nFetching: do / Fetch timeout(1s)\nFetching --> [*]\n@enduml\n",
+			want: true,
+		},
+		{
+			name: "State timeout in composite state",
+			data: `@startuml
+[*] --> Loading
+state Loading {
+  [*] --> Waiting
+  Waiting: timeout 10s
+  Waiting --> [*]
+}
+Loading --> [*]
+@enduml
+`,
+			want: true,
+		},
+	}
+
+	t.Parallel()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, uml.Parse(tt.data).HasTimeouts())
+		})
+	}
+}
+
+func TestFSM_HasTimeTransitions(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, uml.Parse("@startuml\n[*] --> Polling\nPolling --> [*] : IsDone\n@enduml\n").HasTimeTransitions())
+	assert.True(t, uml.Parse(`@startuml
+[*] --> Polling
+state Polling {
+  [*] --> Sleeping
+  Sleeping --> [*] : after(10s)
+}
+Polling --> [*]
+@enduml
+`).HasTimeTransitions())
+}
+
+func TestFSM_Names(t *testing.T) {
+	data := `@startuml
+title Loader
+[*] --> Loading
+Loading: do / Load
+Loading --> Retrying: on error(ErrTimeout)
+Loading --> [*]: IsDone
+Retrying --> Loading
+@enduml
+`
+
+	want := []uml.Name{
+		{Kind: uml.KindTitle, Value: "Loader"},
+		{Kind: uml.KindState, Value: "FinalState"},
+		{Kind: uml.KindState, Value: "InitialState"},
+		{Kind: uml.KindState, Value: "Loading"},
+		{Kind: uml.KindState, Value: "Retrying"},
+		{Kind: uml.KindAction, Value: "Load"},
+		{Kind: uml.KindGuard, Value: "IsDone"},
+		{Kind: uml.KindError, Value: "ErrTimeout"},
+	}
+
+	assert.Equal(t, want, uml.Parse(data).Names())
+}